@@ -34,7 +34,7 @@ func backupDatabase(cmd *cobra.Command, args []string) {
 	dockerErr := docker.EvaluateDockerComposeStatus()
 	if dockerErr == nil {
 		if dev {
-			docker.SetDevMode()
+			selectMode()
 			if lst {
 				fmt.Println("[+] Getting a list of available backup files in the development environment")
 				docker.RunDockerComposeBackups("local.yml")
@@ -45,7 +45,7 @@ func backupDatabase(cmd *cobra.Command, args []string) {
 				docker.RunDockerComposeMediaBackup("local.yml")
 			}
 		} else {
-			docker.SetProductionMode()
+			selectMode()
 			if lst {
 				fmt.Println("[+] Getting a list of available backup files in the production environment")
 				docker.RunDockerComposeBackups("production.yml")
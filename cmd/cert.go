@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// certCmd represents the cert command
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Inspect and manage the TLS certificate for the Nginx web server",
+	Long: `Inspect and manage the TLS certificate for the Nginx web server with subcommands.
+
+The certificate and key are stored in ssl/ghostwriter.crt and ssl/ghostwriter.key. Use
+the "gencert" command to create a new certificate.`,
+}
+
+func init() {
+	rootCmd.AddCommand(certCmd)
+}
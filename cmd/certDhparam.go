@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var dhOpts = env.DefaultDHOptions()
+
+// certDhparamCmd represents the cert dhparam command
+var certDhparamCmd = &cobra.Command{
+	Use:   "dhparam",
+	Short: "Replace the Diffie-Helman parameters used by the Nginx web server",
+	Long: `Replace the Diffie-Helman parameters in ssl/dhparam.pem. The command writes the well-known RFC 7919
+ffdhe2048 group by default. Use the "--bits" flag to choose the ffdhe3072 or ffdhe4096 group.
+
+Use the "--generate" flag to generate new parameters with the "--bits" size (2048 to 8192) instead.
+Generation can take several minutes on small servers and shows its progress. Press Ctrl+C to cancel,
+which writes the closest RFC 7919 group instead.
+
+Restart the Nginx container to use the new parameters.`,
+	Example: `  ghostwriter-cli cert dhparam --bits 4096
+  ghostwriter-cli cert dhparam --generate --bits 3072`,
+	Args: cobra.NoArgs,
+	Run:  certDhparam,
+}
+
+func init() {
+	certCmd.AddCommand(certDhparamCmd)
+
+	certDhparamCmd.Flags().IntVar(&dhOpts.Bits, "bits", dhOpts.Bits, "Bit size of the DH params (2048, 3072, or 4096 unless generating new ones)")
+	certDhparamCmd.Flags().BoolVar(&dhOpts.Generate, "generate", false, "Generate new DH params instead of using an RFC 7919 group")
+}
+
+func certDhparam(cmd *cobra.Command, args []string) {
+	if err := env.ReplaceDHParams(dhOpts); err != nil {
+		log.Fatalf("Failed to write the DH parameters: %v", err)
+	}
+	fmt.Println("[+] DH parameters written to ssl/dhparam.pem")
+	fmt.Println("[*] Restart the Nginx container to use the new parameters")
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var importOpts env.CertImportOptions
+var importPasswordFile string
+
+// certImportCmd represents the cert import command
+var certImportCmd = &cobra.Command{
+	Use:   "import <certificate file>",
+	Short: "Import a certificate and key issued by another certificate authority",
+	Long: `Import a certificate and private key issued by another certificate authority (e.g., a corporate PKI)
+for the Nginx web server. The files can be PEM, DER, or PKCS #12 (PFX). Use the "--key" flag if the private
+key is in a separate file and the "--chain" flag for intermediate certificates in separate files.
+
+The command finds the certificate that matches the private key, orders the chain from the certificate to
+the root, and checks that the certificate covers the allowed hosts. The certificate is not imported if it
+is expired or does not cover any of the allowed hosts unless you use the "--force" flag.
+
+The current ssl/ghostwriter.crt and ssl/ghostwriter.key files are backed up to ssl/backups before they
+are replaced. Nginx is reloaded to use the new certificate if it is running.`,
+	Example: `  ghostwriter-cli cert import ghostwriter.pfx --password-file pfx-password.txt
+  ghostwriter-cli cert import ghostwriter.crt --key ghostwriter.key --chain issuing-ca.crt`,
+	Args: cobra.ExactArgs(1),
+	Run:  certImport,
+}
+
+func init() {
+	certCmd.AddCommand(certImportCmd)
+
+	certImportCmd.Flags().StringVar(&importOpts.KeyFile, "key", "", "File with the private key if it is not in the certificate file")
+	certImportCmd.Flags().StringArrayVar(&importOpts.ChainFiles, "chain", nil, "File with intermediate certificates (can be repeated)")
+	certImportCmd.Flags().StringVar(&importOpts.Password, "password", "", "Password for a PKCS #12 bundle or encrypted private key")
+	certImportCmd.Flags().StringVar(&importPasswordFile, "password-file", "", "File with the password for a PKCS #12 bundle or encrypted private key")
+	certImportCmd.Flags().BoolVar(&importOpts.Force, "force", false, "Import the certificate even if it is expired or does not cover the allowed hosts")
+}
+
+func certImport(cmd *cobra.Command, args []string) {
+	if importPasswordFile != "" {
+		if importOpts.Password != "" {
+			log.Fatalln("Use either `--password` or `--password-file`, not both")
+		}
+		password, err := os.ReadFile(importPasswordFile)
+		if err != nil {
+			log.Fatalf("Failed to read the password file: %v", err)
+		}
+		importOpts.Password = strings.TrimRight(string(password), "\r\n")
+	}
+	importOpts.CertFile = args[0]
+
+	imported, err := env.PrepareCertificateImport(importOpts)
+	if err != nil {
+		log.Fatalf("Failed to import the certificate: %v", err)
+	}
+
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	leaf := imported.Chain[0]
+	fmt.Println("[+] Found a certificate that matches the private key:")
+	fmt.Fprintf(writer, "\n %s\t%s", "Subject", leaf.Subject.String())
+	fmt.Fprintf(writer, "\n %s\t%s", "Issuer", leaf.Issuer.String())
+	fmt.Fprintf(writer, "\n %s\t%s", "Expires", leaf.NotAfter.Local().Format("2006-01-02"))
+	for i, cert := range imported.Chain[1:] {
+		fmt.Fprintf(writer, "\n %s\t%s", fmt.Sprintf("Chain #%d", i+1), cert.Subject.String())
+	}
+	if imported.Root != nil {
+		fmt.Fprintf(writer, "\n %s\t%s (not sent by Nginx)", "Root", imported.Root.Subject.String())
+	}
+	fmt.Fprintln(writer, "")
+	writer.Flush()
+	fmt.Println()
+
+	if !imported.Complete {
+		last := imported.Chain[len(imported.Chain)-1]
+		fmt.Printf("[!] The chain is incomplete because the issuer is missing: %s\n", last.Issuer.String())
+		fmt.Println("[*] Add the intermediate certificates with the `--chain` flag or clients may not trust the certificate")
+	}
+	for _, cert := range imported.Unused {
+		fmt.Printf("[*] Ignoring a certificate that is not part of the chain: %s\n", cert.Subject.String())
+	}
+	if len(imported.Covered) > 0 {
+		fmt.Printf("[+] The certificate covers these allowed hosts: %s\n", strings.Join(imported.Covered, ", "))
+	}
+	if len(imported.Uncovered) > 0 {
+		fmt.Printf("[!] The certificate does not cover these allowed hosts: %s\n", strings.Join(imported.Uncovered, ", "))
+	}
+
+	backupDir, err := env.InstallCertificate(imported)
+	if err != nil {
+		log.Fatalf("Failed to install the certificate: %v", err)
+	}
+	if backupDir != "" {
+		fmt.Printf("[+] Backed up the previous certificate and key to %s\n", backupDir)
+	}
+	fmt.Println("[+] Successfully imported the certificate to ssl/ghostwriter.crt and ssl/ghostwriter.key")
+
+	if err := env.CheckDockerComposeStatus(); err != nil {
+		fmt.Printf("[*] Could not check for a running Nginx container, so reload Nginx to use the new certificate: %v\n", err)
+		return
+	}
+	if err := env.ReloadNginx(env.ModeComposeFile()); err != nil {
+		log.Fatalf("Failed to reload Nginx: %v", err)
+	}
+}
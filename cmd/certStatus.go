@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// certStatusCmd represents the cert status command
+var certStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Display details about the TLS certificate and check if Nginx is serving it",
+	Long: `Display details about the TLS certificate in ssl/ghostwriter.crt, including its subject,
+Subject Alternative Names, issuer, key type, and the days left until it expires. The command checks
+that the certificate matches ssl/ghostwriter.key and compares it with the certificate Nginx is
+serving on the NGINX_PORT. A different certificate means Nginx needs to be reloaded or restarted.`,
+	Args: cobra.NoArgs,
+	Run:  certStatus,
+}
+
+func init() {
+	certCmd.AddCommand(certStatusCmd)
+}
+
+func certStatus(cmd *cobra.Command, args []string) {
+	info, err := env.InspectCertificate()
+	if err != nil {
+		log.Fatalf("Failed to read the certificate: %v", err)
+	}
+
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	sans := append(append([]string{}, info.DNSNames...), info.IPAddresses...)
+	if len(sans) == 0 {
+		sans = []string{"–"}
+	}
+	issuer := info.Issuer
+	if info.SelfSigned {
+		issuer += " (self-signed)"
+	}
+	keyStatus := "Matches ghostwriter.key"
+	if !info.KeyMatches {
+		keyStatus = "Does NOT match ghostwriter.key: " + info.KeyError
+	}
+	expiry := fmt.Sprintf("%s (%d days left)", info.NotAfter.Local().Format("2006-01-02"), info.DaysLeft())
+	if time.Now().After(info.NotAfter) {
+		expiry = fmt.Sprintf("%s (EXPIRED)", info.NotAfter.Local().Format("2006-01-02"))
+	}
+
+	fmt.Println("[+] Certificate details for ssl/ghostwriter.crt:")
+	fmt.Fprintf(writer, "\n %s\t%s", "Subject", info.Subject)
+	fmt.Fprintf(writer, "\n %s\t%s", "SANs", strings.Join(sans, ", "))
+	fmt.Fprintf(writer, "\n %s\t%s", "Issuer", issuer)
+	fmt.Fprintf(writer, "\n %s\t%s", "Key Type", info.KeyType)
+	fmt.Fprintf(writer, "\n %s\t%s", "Valid From", info.NotBefore.Local().Format("2006-01-02"))
+	fmt.Fprintf(writer, "\n %s\t%s", "Expires", expiry)
+	fmt.Fprintf(writer, "\n %s\t%d", "Chain Length", info.ChainLength)
+	fmt.Fprintf(writer, "\n %s\t%s", "Private Key", keyStatus)
+	fmt.Fprintf(writer, "\n %s\t%s", "Fingerprint", info.Fingerprint)
+	fmt.Fprintln(writer, "")
+	writer.Flush()
+
+	address := env.GetNginxAddress()
+	served, err := env.FetchServedCertificate(address, 3*time.Second)
+	switch {
+	case err != nil:
+		fmt.Printf("\n[*] Could not get the certificate served on %s: %v\n", address, err)
+	case served.Fingerprint == info.Fingerprint:
+		fmt.Printf("\n[+] Nginx is serving this certificate on %s\n", address)
+	default:
+		fmt.Printf("\n[!] Nginx is serving a different certificate on %s (%s, expires %s)\n", address, served.Subject, served.NotAfter.Local().Format("2006-01-02"))
+		fmt.Println("[*] Restart the Nginx container or reload it to use the new certificate")
+	}
+}
@@ -4,8 +4,19 @@ import (
 	"fmt"
 	certs "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"strings"
 )
 
+// Vars for the ACME and CA flags
+var acme bool
+var useCA bool
+var acmeOpts certs.ACMEOptions
+
+// Vars for the certificate parameters
+var certOpts = certs.DefaultCertOptions()
+
 // backupCmd represents the backup command
 var certificatesCmd = &cobra.Command{
 	Use:   "gencert",
@@ -14,16 +25,78 @@ var certificatesCmd = &cobra.Command{
 will not create a new certificate if the ssl/ghostwriter.key and ssl/ghostwriter.crt files already exist. Likewise, it
 will not generate a new DH params file if the ssl/dhparam.pem file already exist.
 
-Delete, move, or rename the files if you want to generate new ones.`,
+Delete, move, or rename the files or use the "--force" flag if you want to generate new ones. The "--force" flag
+only replaces the certificate and key. Use "cert dhparam" to replace ssl/dhparam.pem.
+
+The DH params file uses the well-known RFC 7919 ffdhe2048 group by default, which is written instantly. Use the
+"--dh-bits" flag to choose the ffdhe3072 or ffdhe4096 group. Use the "--dh-generate" flag to generate new DH params
+with the "--dh-bits" size instead. Generation can take several minutes and can be cancelled with Ctrl+C, which
+falls back to the closest RFC 7919 group.
+
+The certificate includes the allowed hosts (except "*") as Subject Alternative Names, so browsers accept it once
+it's trusted. Update the allowed hosts with "config allowhost" before generating the certificate.
+
+Use the "--acme" flag to obtain a trusted certificate from Let's Encrypt (or another ACME server) for the
+public domain names in the allowed hosts list. This replaces the existing certificate files. The HTTP-01
+challenge starts a temporary web server on port 80. If the Nginx container is using the port, Nginx is stopped
+while the domains are validated and started again afterward. The DNS-01
+challenge runs the "--acme-dns-hook" program with "present" or "cleanup", the record name, and the record
+value, or asks you to create the TXT records by hand. Renew the certificate with "gencert renew".
+
+Use the "--ca" flag to issue the certificate from a local certificate authority for internal deployments. The
+first run creates the CA in the ca/ directory, which is kept separate from the ssl/ directory mounted in the
+Nginx container. Distribute ca/ghostwriter-ca.crt (PEM) or ca/ghostwriter-ca.der (DER) to operators' browsers
+and systems. Later runs issue a new certificate from the same CA with the current allowed hosts.
+
+For example:
+	ghostwriter-cli gencert --ca
+	ghostwriter-cli gencert --acme --acme-email admin@example.com
+	ghostwriter-cli gencert --acme --acme-challenge dns-01 --acme-dns-hook ./update-dns.sh
+	ghostwriter-cli gencert --acme --acme-directory https://localhost:14000/dir --acme-ca-file pebble.minica.pem`,
 	Run: createCertificates,
 }
 
 func init() {
 	rootCmd.AddCommand(certificatesCmd)
+
+	certificatesCmd.Flags().IntVar(&certOpts.ValidDays, "days", certOpts.ValidDays, "Number of days the certificate is valid")
+	certificatesCmd.Flags().StringVar(&certOpts.KeyType, "key-type", certOpts.KeyType, "Private key type ("+strings.Join(certs.KeyTypes, ", ")+")")
+	certificatesCmd.Flags().StringVar(&certOpts.Organization, "org", certOpts.Organization, "Organization in the certificate's subject")
+	certificatesCmd.Flags().StringVar(&certOpts.CommonName, "cn", certOpts.CommonName, "Common name in the certificate's subject")
+	certificatesCmd.Flags().BoolVar(&certOpts.Force, "force", false, "Replace the existing certificate and key")
+	certificatesCmd.Flags().IntVar(&certOpts.DH.Bits, "dh-bits", certOpts.DH.Bits, "Bit size of the DH params (2048, 3072, or 4096 unless generating new ones)")
+	certificatesCmd.Flags().BoolVar(&certOpts.DH.Generate, "dh-generate", false, "Generate new DH params instead of using an RFC 7919 group")
+	certificatesCmd.Flags().BoolVar(&useCA, "ca", false, "Issue the certificate from a local certificate authority")
+	certificatesCmd.Flags().BoolVar(&acme, "acme", false, "Obtain the certificate from an ACME server like Let's Encrypt")
+	certificatesCmd.Flags().StringVar(&acmeOpts.Directory, "acme-directory", certs.DefaultACMEDirectory, "Directory URL of the ACME server")
+	certificatesCmd.Flags().StringVar(&acmeOpts.Email, "acme-email", "", "Contact email address for the ACME account")
+	certificatesCmd.Flags().StringVar(&acmeOpts.Challenge, "acme-challenge", certs.ChallengeHTTP, "Challenge type to prove control of the domains (http-01 or dns-01)")
+	certificatesCmd.Flags().StringVar(&acmeOpts.CAFile, "acme-ca-file", "", "PEM file with an additional CA to trust for the ACME server (e.g., for Pebble)")
+	certificatesCmd.Flags().IntVar(&acmeOpts.HTTPPort, "acme-http-port", 80, "Port for the temporary HTTP-01 challenge server")
+	certificatesCmd.Flags().StringVar(&acmeOpts.DNSHook, "acme-dns-hook", "", "Program that creates and removes the DNS-01 TXT records")
+	certificatesCmd.Flags().StringSliceVar(&acmeOpts.Domains, "domain", nil, "Domain to include in the certificate in place of the allowed hosts (repeatable)")
 }
 
 func createCertificates(cmd *cobra.Command, args []string) {
-	certErr := certs.GenerateCertificatePackage()
+	if acme && useCA {
+		log.Fatalln("The `--acme` and `--ca` flags can't be used together")
+	}
+	if useCA {
+		if err := certs.GenerateCACertificatePackage(certOpts); err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("[+] Certificate generation complete!")
+		return
+	}
+	if acme {
+		acmeOpts.KeyType = certOpts.KeyType
+		if err := certs.GenerateACMECertificatePackage(acmeOpts, certOpts.DH); err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("[+] Certificate generation complete!")
+		return
+	}
+	certErr := certs.GenerateCertificatePackage(certOpts)
 	if certErr == nil {
 		fmt.Println("[+] Certificate generation complete!")
 	}
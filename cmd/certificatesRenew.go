@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	certs "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var renewForce bool
+
+// certificatesRenewCmd represents the gencert renew command
+var certificatesRenewCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "Renew the certificate obtained from an ACME server",
+	Long: `Renew the certificate obtained with "gencert --acme" using the same settings. The certificate is
+only renewed if it expires within 30 days unless you use the "--force" flag. Nginx is reloaded to use the
+new certificate if it is running. With the HTTP-01 challenge, Nginx is stopped while the domains are validated
+if it is using the challenge port and started again afterward.
+
+Run this command on a schedule (e.g., daily with cron) to keep the certificate up to date.`,
+	Args: cobra.NoArgs,
+	Run:  renewCertificates,
+}
+
+func init() {
+	certificatesCmd.AddCommand(certificatesRenewCmd)
+
+	certificatesRenewCmd.Flags().BoolVar(&renewForce, "force", false, "Renew the certificate even if it is not close to expiring")
+}
+
+func renewCertificates(cmd *cobra.Command, args []string) {
+	renewed, err := certs.RenewACMECertificate(renewForce)
+	if err != nil {
+		log.Fatalf("Failed to renew the certificate: %v", err)
+	}
+	if !renewed {
+		return
+	}
+	fmt.Println("[+] Certificate renewal complete!")
+
+	// The certificate is already written, so a missing Docker daemon shouldn't fail a scheduled renewal
+	if err := certs.CheckDockerComposeStatus(); err != nil {
+		fmt.Printf("[*] Could not check for a running Nginx container, so reload Nginx to use the new certificate: %v\n", err)
+		return
+	}
+	if err := certs.ReloadNginx(certs.ModeComposeFile()); err != nil {
+		log.Fatalf("Failed to reload Nginx: %v", err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"text/tabwriter"
 )
 
+var recreate bool
+
 // configCmd represents the config command
 var configCmd = &cobra.Command{
 	Use:   "config",
@@ -20,6 +22,58 @@ adjust the configuration or retrieve individual values.`,
 
 func init() {
 	rootCmd.AddCommand(configCmd)
+
+	configCmd.PersistentFlags().BoolVar(&recreate, "recreate", false, "Recreate the affected running services after a change without asking")
+}
+
+// Determine if the CLI is attached to an interactive terminal that can answer prompts.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Report the services affected by changes to the configuration ("keys" parameter) and offer to
+// recreate the ones that are running so the changes take effect.
+func applyConfigChanges(keys []string) {
+	fmt.Println("[+] Configuration successfully updated.")
+
+	affected := env.ServicesForKeys(keys)
+	if len(affected) == 0 {
+		fmt.Println("[+] Bring containers down and up for changes to take effect.")
+		return
+	}
+
+	var targets []string
+	running, err := env.GetRunningServices()
+	if err == nil {
+		for _, service := range affected {
+			if env.Contains(running, service) {
+				targets = append(targets, service)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Printf("[+] Recreate these services for changes to take effect: %s\n", strings.Join(affected, ", "))
+		return
+	}
+
+	if !recreate {
+		if !isInteractive() {
+			fmt.Printf("[+] Recreate these running services for changes to take effect: %s (or use the `--recreate` flag)\n", strings.Join(targets, ", "))
+			return
+		}
+		question := fmt.Sprintf("[?] Recreate the affected running services (%s) now?", strings.Join(targets, ", "))
+		if !env.AskForConfirmation(question) {
+			fmt.Println("[+] Changes will take effect the next time the services are recreated. Use `config pending` to review them.")
+			return
+		}
+	}
+
+	env.EvaluateDockerComposeStatus()
+	env.RunDockerComposeRecreate(env.ModeComposeFile(), targets)
 }
 
 func configDisplay(cmd *cobra.Command, args []string) {
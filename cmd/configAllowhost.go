@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"fmt"
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 )
 
 // configAllowhostCmd represents the configAllowhost command
@@ -16,13 +16,18 @@ wildcards in a hostname or address will not work.
 
 Using "*" is NOT recommended! It should only be used for testing purposes.
 
+Use a leading "." to allow a domain and all of its subdomains. CIDR ranges, ports,
+and URLs are rejected.
+
 Good examples:
 	ghostwriter-cli config allowhost 192.168.1.100
 	ghostwriter-cli config allowhost ghostwriter.local
+	ghostwriter-cli config allowhost .example.com
 	ghostwriter-cli config allowhost *
 Bad examples:
 	ghostwriter-cli config allowhost *.example.com
-	ghostwriter-cli config allowhost 192.168.1.*`,
+	ghostwriter-cli config allowhost 192.168.1.*
+	ghostwriter-cli config allowhost 192.168.1.0/24`,
 	Args: cobra.ExactArgs(1),
 	Run:  configAllowHost,
 }
@@ -32,6 +37,8 @@ func init() {
 }
 
 func configAllowHost(cmd *cobra.Command, args []string) {
-	env.AllowHost(args[0])
-	fmt.Println("[+] Configuration successfully updated. Bring containers down and up for changes to take effect.")
+	if err := env.AllowHost(args[0]); err != nil {
+		log.Fatalf("Failed to update the configuration: %v", err)
+	}
+	applyConfigChanges([]string{"django_allowed_hosts"})
 }
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// configDiffCmd represents the config diff command
+var configDiffCmd = &cobra.Command{
+	Use:   "diff <file>",
+	Short: "Compare an exported configuration with the current configuration",
+	Long: `Compare a configuration document created by "config export" with the current configuration.
+
+Secret values are masked in the output. Encrypted secrets are only compared if the passphrase is
+provided with the "--passphrase" flag or the GHOSTWRITER_CONFIG_PASSPHRASE environment variable.
+
+For example:
+	ghostwriter-cli config diff staging.yml`,
+	Args: cobra.ExactArgs(1),
+	Run:  configDiff,
+}
+
+func init() {
+	configCmd.AddCommand(configDiffCmd)
+
+	configDiffCmd.Flags().StringVar(&configPassphrase, "passphrase", "", "Passphrase for decrypting secrets")
+}
+
+// Read and parse the configuration document at "path".
+func readConfigDocument(path string) env.ConfigDocument {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	doc, err := env.ParseConfigDocument(path, data, getConfigPassphrase())
+	if err != nil {
+		log.Fatalf("Failed to read the configuration document: %v", err)
+	}
+	return doc
+}
+
+// Print a table of configuration changes with secrets masked.
+func printConfigChanges(changes env.ConfigChanges) {
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	defer writer.Flush()
+
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "Setting", "Change", "Current", "Incoming")
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "–––––––", "–––––––", "–––––––", "–––––––")
+	for _, change := range changes {
+		current := env.MaskSecret(change.Key, change.Current)
+		incoming := env.MaskSecret(change.Key, change.Incoming)
+		if change.Kind == env.ChangeEncrypted {
+			incoming = "(encrypted)"
+		}
+		if current == "" {
+			current = "–"
+		}
+		if incoming == "" {
+			incoming = "–"
+		}
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", change.Key, change.Kind, current, incoming)
+	}
+	fmt.Fprintln(writer, "")
+}
+
+func configDiff(cmd *cobra.Command, args []string) {
+	doc := readConfigDocument(args[0])
+	changes := env.DiffConfig(doc)
+	if len(changes) == 0 {
+		fmt.Printf("[+] The current configuration matches %s\n", args[0])
+		return
+	}
+	fmt.Printf("[+] Found %d differences between the current configuration and %s:\n", len(changes), args[0])
+	printConfigChanges(changes)
+}
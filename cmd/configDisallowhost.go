@@ -1,18 +1,19 @@
 package cmd
 
 import (
-	"fmt"
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 )
 
 // configDisallowhostCmd represents the configDisallowhost command
 var configDisallowHostCmd = &cobra.Command{
 	Use:   "disallowhost <host>",
 	Short: "Remove a hostname or IP address to the allowed hosts list",
-	Long:  "Remove a hostname or IP address to the allowed hosts list.",
-	Args:  cobra.ExactArgs(1),
-	Run:   configDisallowHost,
+	Long: `Remove a hostname or IP address to the allowed hosts list. Any trusted origins
+for the same host are also removed to keep the lists consistent.`,
+	Args: cobra.ExactArgs(1),
+	Run:  configDisallowHost,
 }
 
 func init() {
@@ -20,6 +21,8 @@ func init() {
 }
 
 func configDisallowHost(cmd *cobra.Command, args []string) {
-	env.DisallowHost(args[0])
-	fmt.Println("[+] Configuration successfully updated. Bring containers down and up for changes to take effect.")
+	if err := env.DisallowHost(args[0]); err != nil {
+		log.Fatalf("Failed to update the configuration: %v", err)
+	}
+	applyConfigChanges([]string{"django_allowed_hosts", "django_csrf_trusted_origins"})
 }
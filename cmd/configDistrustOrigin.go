@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"fmt"
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 )
 
 // configDistrustOriginCmd represents the configDistrustOrigin command
@@ -22,6 +22,8 @@ func init() {
 }
 
 func configDistrustOrigin(cmd *cobra.Command, args []string) {
-	env.DistrustOrigin(args[0])
-	fmt.Println("[+] Configuration successfully updated. Bring containers down and up for changes to take effect.")
+	if err := env.DistrustOrigin(args[0]); err != nil {
+		log.Fatalf("Failed to update the configuration: %v", err)
+	}
+	applyConfigChanges([]string{"django_csrf_trusted_origins"})
 }
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportSecrets string
+var exportOutput string
+var configPassphrase string
+
+// configExportCmd represents the config export command
+var configExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the configuration as a portable YAML or JSON document",
+	Long: `Export the configuration as a portable YAML or JSON document that can be compared with
+or imported into another Ghostwriter environment.
+
+Secrets (passwords, secret keys, and API keys) are excluded by default. Use "--secrets include"
+to export them in plaintext or "--secrets encrypt" to encrypt them with a passphrase. The
+passphrase can be provided with the "--passphrase" flag or the GHOSTWRITER_CONFIG_PASSPHRASE
+environment variable.
+
+For example:
+	ghostwriter-cli config export --output staging.yml
+	ghostwriter-cli config export --format json --secrets encrypt --output staging.json`,
+	Args: cobra.NoArgs,
+	Run:  configExport,
+}
+
+func init() {
+	configCmd.AddCommand(configExportCmd)
+
+	configExportCmd.Flags().StringVar(&exportFormat, "format", "yaml", "Output format (yaml or json)")
+	configExportCmd.Flags().StringVar(&exportSecrets, "secrets", env.SecretsExclude, "How to handle secrets (include, exclude, or encrypt)")
+	configExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the document to this file instead of stdout")
+	configExportCmd.Flags().StringVar(&configPassphrase, "passphrase", "", "Passphrase for encrypting secrets")
+}
+
+// Get the passphrase for encrypted secrets from the flag or the environment.
+func getConfigPassphrase() string {
+	if configPassphrase != "" {
+		return configPassphrase
+	}
+	return os.Getenv("GHOSTWRITER_CONFIG_PASSPHRASE")
+}
+
+func configExport(cmd *cobra.Command, args []string) {
+	doc, err := env.ExportConfig(exportSecrets, getConfigPassphrase())
+	if err != nil {
+		log.Fatalf("Failed to export the configuration: %v", err)
+	}
+	data, err := env.MarshalConfigDocument(doc, exportFormat)
+	if err != nil {
+		log.Fatalf("Failed to export the configuration: %v", err)
+	}
+
+	if exportOutput == "" {
+		fmt.Print(string(data))
+		return
+	}
+	if err := os.WriteFile(exportOutput, data, 0600); err != nil {
+		log.Fatalf("Failed to write %s: %v", exportOutput, err)
+	}
+	fmt.Printf("[+] Exported %d settings to %s (secrets: %s)\n", len(doc.Settings), exportOutput, doc.Secrets)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+// configHistoryCmd represents the config history command
+var configHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Display the history of configuration changes",
+	Long: `Display the history of configuration changes made with the CLI. Every change made by
+the "config" subcommands and by switching between production and development modes is
+recorded with a timestamp in an append-only journal next to the ".env" file.
+
+Secrets are never stored in the journal. Only hashes of their values are recorded, so changes
+to secrets are listed but can't be undone.
+
+Use "config undo <id>" to revert a change.`,
+	Args: cobra.NoArgs,
+	Run:  configHistory,
+}
+
+func init() {
+	configCmd.AddCommand(configHistoryCmd)
+
+	configHistoryCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of recent changes to display (0 for all)")
+}
+
+func configHistory(cmd *cobra.Command, args []string) {
+	history, err := env.ReadHistory()
+	if err != nil {
+		log.Fatalf("Failed to read the configuration history: %v", err)
+	}
+	if len(history) == 0 {
+		fmt.Println("[+] No configuration changes have been recorded")
+		return
+	}
+
+	entries := history
+	if historyLimit > 0 && len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	defer writer.Flush()
+
+	fmt.Printf("[+] Showing %d of %d configuration changes:\n", len(entries), len(history))
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s", "ID", "Time", "Action", "Setting", "Old", "New")
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s", "––", "–––––––", "–––––––", "–––––––", "–––––––", "–––––––")
+	for _, entry := range entries {
+		action := entry.Action
+		if entry.Reverts != 0 {
+			action = fmt.Sprintf("%s #%d", action, entry.Reverts)
+		}
+		if history.IsReverted(entry.ID) {
+			action += " (undone)"
+		}
+		for i, change := range entry.Changes {
+			oldVal, newVal := change.Old, change.New
+			if change.Hashed {
+				oldVal, newVal = "(secret)", "(secret)"
+			}
+			if oldVal == "" {
+				oldVal = "–"
+			}
+			if newVal == "" {
+				newVal = "–"
+			}
+			if i == 0 {
+				fmt.Fprintf(writer, "\n %d\t%s\t%s\t%s\t%s\t%s", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"), action, change.Key, oldVal, newVal)
+			} else {
+				fmt.Fprintf(writer, "\n \t\t\t%s\t%s\t%s", change.Key, oldVal, newVal)
+			}
+		}
+	}
+	fmt.Fprintln(writer, "")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// configHostsCmd represents the config hosts command
+var configHostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Inspect the allowed hosts list",
+	Long: `Inspect the allowed hosts list with subcommands. Use the "allowhost" and "disallowhost"
+commands to change the list.`,
+}
+
+// configHostsListCmd represents the config hosts list command
+var configHostsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the allowed hosts",
+	Long: `List the allowed hosts and whether each host has a matching trusted origin.
+
+A host without a trusted origin still works for direct requests, but requests from other
+origins (e.g., behind a reverse proxy with a different hostname) may fail CSRF checks.`,
+	Args: cobra.NoArgs,
+	Run:  listAllowedHosts,
+}
+
+func init() {
+	configCmd.AddCommand(configHostsCmd)
+	configHostsCmd.AddCommand(configHostsListCmd)
+}
+
+// Print a table of allowed hosts or trusted origins with the "matchHeader" column showing if the entry
+// has a match in the other list.
+func printHostEntries(entries env.HostEntries, valueHeader string, matchHeader string) {
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	defer writer.Flush()
+
+	fmt.Fprintf(writer, "\n %s\t%s\t%s", valueHeader, "Type", matchHeader)
+	fmt.Fprintf(writer, "\n %s\t%s\t%s", "–––––––", "–––––––", "–––––––")
+	for _, entry := range entries {
+		matched := "no"
+		if entry.Matched {
+			matched = "yes"
+		}
+		fmt.Fprintf(writer, "\n %s\t%s\t%s", entry.Value, entry.Kind, matched)
+	}
+	fmt.Fprintln(writer, "")
+}
+
+func listAllowedHosts(cmd *cobra.Command, args []string) {
+	entries := env.ListAllowedHosts()
+	if len(entries) == 0 {
+		fmt.Println("[!] The allowed hosts list is empty, so Django will reject all requests")
+		return
+	}
+	fmt.Printf("[+] Found %d allowed hosts:\n", len(entries))
+	printHostEntries(entries, "Host", "Trusted Origin")
+}
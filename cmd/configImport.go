@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var importStrategy string
+var importDryRun bool
+
+// configImportCmd represents the config import command
+var configImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import an exported configuration into the current configuration",
+	Long: `Import a configuration document created by "config export" into the current configuration.
+
+Settings that only exist in the document are added. Settings with different values are conflicts
+and are handled with the "--strategy" flag:
+
+* prompt: ask before replacing each value (default)
+* theirs: replace every value with the imported value
+* ours: keep every current value
+
+Settings missing from the document are never removed. Encrypted secrets are skipped unless the
+passphrase is provided with the "--passphrase" flag or the GHOSTWRITER_CONFIG_PASSPHRASE
+environment variable.
+
+Use "--dry-run" to preview the changes and the services that need to be restarted.
+
+For example:
+	ghostwriter-cli config import staging.yml --strategy theirs`,
+	Args: cobra.ExactArgs(1),
+	Run:  configImport,
+}
+
+func init() {
+	configCmd.AddCommand(configImportCmd)
+
+	configImportCmd.Flags().StringVar(&importStrategy, "strategy", "prompt", "How to handle conflicting values (prompt, theirs, or ours)")
+	configImportCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Preview the changes without updating the configuration")
+	configImportCmd.Flags().StringVar(&configPassphrase, "passphrase", "", "Passphrase for decrypting secrets")
+}
+
+func configImport(cmd *cobra.Command, args []string) {
+	if importStrategy != "prompt" && importStrategy != "theirs" && importStrategy != "ours" {
+		log.Fatalf("Unknown strategy `%s` (use prompt, theirs, or ours)", importStrategy)
+	}
+
+	doc := readConfigDocument(args[0])
+	var selected env.ConfigChanges
+	var keys []string
+	for _, change := range env.DiffConfig(doc) {
+		switch change.Kind {
+		case env.ChangeEncrypted:
+			fmt.Printf("[!] Skipping %s because it is encrypted and no passphrase was provided\n", change.Key)
+			continue
+		case env.ChangeRemoved:
+			continue
+		case env.ChangeModified:
+			if importStrategy == "ours" {
+				continue
+			}
+			if importStrategy == "prompt" && !importDryRun {
+				question := fmt.Sprintf(
+					"[?] Replace %s (%s » %s)?",
+					change.Key, env.MaskSecret(change.Key, change.Current), env.MaskSecret(change.Key, change.Incoming),
+				)
+				if !env.AskForConfirmation(question) {
+					continue
+				}
+			}
+		}
+		selected = append(selected, change)
+		keys = append(keys, change.Key)
+	}
+
+	if len(selected) == 0 {
+		fmt.Println("[+] There are no changes to import")
+		return
+	}
+
+	if importDryRun {
+		fmt.Printf("[+] Importing %s would make %d changes:\n", args[0], len(selected))
+		printConfigChanges(selected)
+		services := env.ServicesForKeys(keys)
+		if len(services) > 0 {
+			fmt.Printf("[+] These services would need to be recreated for the changes to take effect: %s\n", strings.Join(services, ", "))
+		}
+		return
+	}
+
+	updated := env.ImportConfig(selected)
+	fmt.Printf("[+] Imported %d settings from %s\n", len(updated), args[0])
+	applyConfigChanges(updated)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// configOriginsCmd represents the config origins command
+var configOriginsCmd = &cobra.Command{
+	Use:   "origins",
+	Short: "Inspect the trusted origins list",
+	Long: `Inspect the trusted origins list with subcommands. Use the "trustorigin" and "distrustorigin"
+commands to change the list.`,
+}
+
+// configOriginsListCmd represents the config origins list command
+var configOriginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the trusted origins",
+	Long: `List the trusted origins and whether each origin's host is in the allowed hosts list.
+
+Django rejects requests for hosts that are not allowed, so an origin without an allowed host
+will not work.`,
+	Args: cobra.NoArgs,
+	Run:  listTrustedOrigins,
+}
+
+func init() {
+	configCmd.AddCommand(configOriginsCmd)
+	configOriginsCmd.AddCommand(configOriginsListCmd)
+}
+
+func listTrustedOrigins(cmd *cobra.Command, args []string) {
+	entries := env.ListTrustedOrigins()
+	if len(entries) == 0 {
+		fmt.Println("[+] The trusted origins list is empty")
+		return
+	}
+	fmt.Printf("[+] Found %d trusted origins:\n", len(entries))
+	printHostEntries(entries, "Origin", "Host Allowed")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// configPendingCmd represents the config pending command
+var configPendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "Show configuration changes that running containers have not picked up",
+	Long: `Show configuration changes made since the containers last started. This compares the
+environment of each running Ghostwriter container with the current configuration.
+
+Secret values are masked in the output. Recreate the listed services (e.g., with the
+"containers up" command) to apply the changes.`,
+	Args: cobra.NoArgs,
+	Run:  configPending,
+}
+
+func init() {
+	configCmd.AddCommand(configPendingCmd)
+}
+
+func configPending(cmd *cobra.Command, args []string) {
+	pending, err := env.GetPendingChanges()
+	if err != nil {
+		log.Fatalf("Failed to get container information from Docker: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("[+] All running containers match the current configuration")
+		return
+	}
+
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	defer writer.Flush()
+
+	fmt.Printf("[+] Found %d pending changes:\n", len(pending))
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "Service", "Setting", "Running", "Configured")
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "–––––––", "–––––––", "–––––––", "–––––––")
+	for _, change := range pending {
+		running := env.MaskSecret(change.Key, change.Running)
+		configured := env.MaskSecret(change.Key, change.Configured)
+		if running == "" {
+			running = "–"
+		}
+		if configured == "" {
+			configured = "–"
+		}
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", change.Service, change.Key, running, configured)
+	}
+	fmt.Fprintln(writer, "")
+}
@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"fmt"
+	"log"
+
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,9 @@ var configSetCmd = &cobra.Command{
 	Long: `Set the specified configuration value. Use quotations around the value
 if it contains spaces.
 
+The security settings that depend on the environment mode (e.g., DJANGO_SECURE_SSL_REDIRECT)
+can't be set because they are derived from GHOSTWRITER_MODE for every command.
+
 For example: ghostwriter-cli config set DATE_FORMAT "d M Y"`,
 	Args: cobra.ExactArgs(2),
 	Run:  configSet,
@@ -23,6 +27,8 @@ func init() {
 }
 
 func configSet(cmd *cobra.Command, args []string) {
-	env.SetConfig(args[0], args[1])
-	fmt.Println("[+] Configuration successfully updated. Bring containers down and up for changes to take effect.")
+	if err := env.SetConfig(args[0], args[1]); err != nil {
+		log.Fatalf("Failed to set the value: %v", err)
+	}
+	applyConfigChanges([]string{args[0]})
 }
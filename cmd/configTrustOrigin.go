@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"fmt"
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 )
 
 // configTrustOriginCmd represents the configTrustOrigin command
@@ -14,11 +14,14 @@ var configTrustOriginCmd = &cobra.Command{
 Ghostwriter will allow requests where the host appears in the "Origin" or "Referer"
 headers of requests and does not match the "Host" header.
 
-Use a "*" as a wildcard to trust all subdomains.
+Origins must include the scheme (e.g., "https://"). If the scheme is missing, "https://" is
+added for you. Use a "*." prefix as a wildcard to trust all subdomains. The origin's host is
+also added to the allowed hosts list if it is not already allowed.
 
 Good examples:
-	ghostwriter-cli config trustorigin ghostwriter.local
-	ghostwriter-cli config trustorigin *.ghostwriter.local
+	ghostwriter-cli config trustorigin https://ghostwriter.local
+	ghostwriter-cli config trustorigin https://*.ghostwriter.local
+	ghostwriter-cli config trustorigin https://ghostwriter.local:8443
 Bad examples:
 	ghostwriter-cli config trustorigin *`,
 	Args: cobra.ExactArgs(1),
@@ -30,6 +33,8 @@ func init() {
 }
 
 func configTrustOrigin(cmd *cobra.Command, args []string) {
-	env.TrustOrigin(args[0])
-	fmt.Println("[+] Configuration successfully updated. Bring containers down and up for changes to take effect.")
+	if err := env.TrustOrigin(args[0]); err != nil {
+		log.Fatalf("Failed to update the configuration: %v", err)
+	}
+	applyConfigChanges([]string{"django_csrf_trusted_origins", "django_allowed_hosts"})
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var undoForce bool
+
+// configUndoCmd represents the config undo command
+var configUndoCmd = &cobra.Command{
+	Use:   "undo [id]",
+	Short: "Revert a configuration change",
+	Long: `Revert a configuration change recorded in the history. Provide the change's ID from the
+"config history" command or leave it out to revert the most recent change that hasn't been
+undone. The undo is recorded in the history, too.
+
+If a value changed again after the change, the undo stops unless you use "--force". Changes
+to secrets can't be undone because the history only stores hashes of secret values.
+
+For example:
+	ghostwriter-cli config undo
+	ghostwriter-cli config undo 12`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  configUndo,
+}
+
+func init() {
+	configCmd.AddCommand(configUndoCmd)
+
+	configUndoCmd.Flags().BoolVar(&undoForce, "force", false, "Revert values even if they changed again after the change")
+}
+
+func configUndo(cmd *cobra.Command, args []string) {
+	id := 0
+	if len(args) == 1 {
+		var err error
+		id, err = strconv.Atoi(args[0])
+		if err != nil || id < 1 {
+			log.Fatalf("The change ID must be a positive number: %s", args[0])
+		}
+	}
+
+	reverted, err := env.UndoConfigChange(id, undoForce)
+	if err != nil {
+		log.Fatalf("Failed to undo the change: %v", err)
+	}
+
+	var keys []string
+	for _, change := range reverted.Changes {
+		keys = append(keys, change.Key)
+		fmt.Printf("[+] Reverted %s to `%s`\n", change.Key, change.Old)
+	}
+	fmt.Printf("[+] Undid change %d (%s)\n", reverted.ID, reverted.Action)
+	applyConfigChanges(keys)
+}
@@ -36,11 +36,11 @@ func buildContainers(cmd *cobra.Command, args []string) {
 	docker.EvaluateDockerComposeStatus()
 	if dev {
 		fmt.Println("[+] Starting development environment build")
-		docker.SetDevMode()
+		selectMode()
 		docker.RunDockerComposeUpgrade("local.yml", skipseed)
 	} else {
 		fmt.Println("[+] Starting production environment build")
-		docker.SetProductionMode()
+		selectMode()
 		docker.RunDockerComposeUpgrade("production.yml", skipseed)
 	}
 
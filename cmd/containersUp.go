@@ -26,11 +26,11 @@ func containersUp(cmd *cobra.Command, args []string) {
 	docker.EvaluateDockerComposeStatus()
 	if dev {
 		fmt.Println("[+] Bringing up the development environment")
-		docker.SetDevMode()
+		selectMode()
 		docker.RunDockerComposeUp("local.yml")
 	} else {
 		fmt.Println("[+] Bringing up the production environment")
-		docker.SetProductionMode()
+		selectMode()
 		docker.RunDockerComposeUp("production.yml")
 	}
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exporterListen        string
+	exporterProbeInterval time.Duration
+)
+
+// exporterCmd represents the exporter command
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Expose Ghostwriter's health as Prometheus metrics",
+	Long: `Run an HTTP server that exposes Ghostwriter's health as Prometheus metrics
+on the "/metrics" path. The metrics are collected when Prometheus scrapes the
+exporter and include:
+
+  * The state, health, restart count, and uptime of every container
+  * The number of issues found by the healthcheck by type and service
+  * The results and latency of the PostgreSQL, Redis, Hasura, and collab server probes
+  * The age and size of the latest database and media backups
+  * The expiration time of the Nginx certificate (production only)
+  * The disk space used by the instance's Docker volumes
+  * The version of the CLI ("ghostwriter_cli_build_info")
+
+The service probes run at most once every "--probe-interval" (default: 1m) and
+the last results are reported in between. The exporter listens on localhost by
+default, so use "--listen :9810" to expose it to a Prometheus server on another host.
+
+A collector that fails is reported with "ghostwriter_exporter_collector_success"
+instead of failing the whole scrape. The exporter runs until it is stopped with Ctrl+C.`,
+	Example: `  ghostwriter-cli exporter
+  ghostwriter-cli exporter --listen :9810 --probe-interval 5m`,
+	Run: runExporter,
+}
+
+func init() {
+	rootCmd.AddCommand(exporterCmd)
+
+	exporterCmd.Flags().StringVar(&exporterListen, "listen", "127.0.0.1:9810", "Address for the exporter's HTTP server")
+	exporterCmd.Flags().DurationVar(&exporterProbeInterval, "probe-interval", time.Minute, "Minimum time between runs of the service probes")
+}
+
+func runExporter(cmd *cobra.Command, args []string) {
+	if exporterProbeInterval < time.Second {
+		log.Fatalln("The `--probe-interval` must be at least one second")
+	}
+	docker.EvaluateDockerComposeStatus()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("[+] Serving Prometheus metrics on http://%s/metrics (press Ctrl+C to stop)...\n", exporterListen)
+	if err := docker.ServeMetrics(ctx, exporterListen, exporterProbeInterval); err != nil {
+		log.Fatalf("Failed to run the exporter: %v", err)
+	}
+	fmt.Println("[+] Stopped the exporter")
+}
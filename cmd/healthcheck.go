@@ -1,31 +1,123 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	utils "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"text/tabwriter"
+	"time"
 )
 
+var watchHealth bool
+var watchInterval time.Duration
+var webhookURLs []string
+var statusOpts docker.StatusCheckOptions
+
 // healthcheckCmd represents the healthcheck command
 var healthcheckCmd = &cobra.Command{
 	Use:   "healthcheck",
 	Short: "Check the health of Ghostwriter's services",
 	Long: `Check the health of Ghostwriter's services.
 
-This command validates all containers are running and passing
-their respective health checks`,
+This command inspects every container and reports its state, Docker health
+check status, the output of the last health check, restart count, and uptime.
+Containers that are stopped, crash-looping, killed for running out of memory,
+or failing their health checks are reported as issues.
+
+It then probes the backing services directly from inside their containers:
+"pg_isready" and the connection count for PostgreSQL, "PING" and memory
+usage for Redis, Hasura's /healthz endpoint and metadata consistency, and
+a WebSocket handshake with the collab server. Each probe reports its
+latency. The disk usage of the Docker data root and every Ghostwriter volume
+and the host's available memory are checked against the HEALTHCHECK_DISK_USAGE_MAX
+percentage and HEALTHCHECK_MEM_MIN megabytes. In production mode, it also checks that
+the TLS certificate matches its key and does not expire within the number
+of days set in HEALTHCHECK_CERT_EXPIRY_DAYS.
+
+The /status/ endpoint is checked on this host on the NGINX_PORT (or the
+DJANGO_PORT in development mode) by default. Use the "--url" flag to check
+another URL, such as a Ghostwriter server from a remote monitoring host. For
+remote URLs, only the /status/ endpoint is checked. TLS certificates are
+verified against the system's CAs, the certificate installed for Nginx (for
+this host), and the certificates in the "--ca-file".
+
+Use the "--watch" flag to monitor Ghostwriter. The containers and the
+/status/ endpoint are checked every "--interval" and only changes are
+reported: new issues when they first appear and recoveries when they are
+resolved. Use the "--webhook" flag to post these alerts to a URL as JSON
+or prefix the URL with "slack=" or "teams=" to post Slack or Microsoft
+Teams messages.`,
+	Example: `  ghostwriter-cli healthcheck --url https://ghostwriter.example.com/status/ --ca-file corporate-ca.crt
+  ghostwriter-cli healthcheck --watch --interval 60s
+  ghostwriter-cli healthcheck --watch --webhook slack=https://hooks.slack.com/services/... --webhook https://alerts.example.com/ghostwriter`,
 	Run: runHealthcheck,
 }
 
 func init() {
 	rootCmd.AddCommand(healthcheckCmd)
+
+	healthcheckCmd.Flags().BoolVar(&watchHealth, "watch", false, "Check the health continuously and report changes")
+	healthcheckCmd.Flags().DurationVar(&watchInterval, "interval", time.Minute, "Time between checks with `--watch`")
+	healthcheckCmd.Flags().StringArrayVar(&webhookURLs, "webhook", nil, "URL to post alerts to with `--watch`, optionally prefixed with `slack=` or `teams=` (can be repeated)")
+	healthcheckCmd.Flags().StringVar(&statusOpts.URL, "url", "", "URL of Ghostwriter's /status/ endpoint (default: this host on NGINX_PORT or DJANGO_PORT)")
+	healthcheckCmd.Flags().StringVar(&statusOpts.CAFile, "ca-file", "", "File with the CA certificates to verify the server's certificate")
+	healthcheckCmd.Flags().DurationVar(&statusOpts.Timeout, "timeout", 10*time.Second, "Timeout for the request to the /status/ endpoint")
+}
+
+// Determine if the checks use the development environment. The recorded mode is used instead of the "--dev" flag,
+// so the expected services come from the Compose file of the containers that are checked.
+func healthDevMode() bool {
+	return docker.GetMode() != docker.ModeProduction
+}
+
+// Check if the "--url" flag targets another host. Remote checks only use the /status/ endpoint
+// because the other checks need access to Docker.
+func isRemoteHealthcheck() bool {
+	return statusOpts.URL != "" && !docker.IsLocalURL(statusOpts.URL)
+}
+
+// Check Ghostwriter's /status/ endpoint and print the issues.
+func checkStatusEndpoint(writer *tabwriter.Writer) {
+	opts := statusOpts
+	if opts.URL == "" {
+		opts.URL = docker.DefaultStatusURL(healthDevMode())
+	}
+	serviceIssues, svcErr := utils.CheckGhostwriterHealth(opts)
+	if svcErr != nil {
+		log.Fatalf("Failed to check Ghostwriter's /status/ endpoint: %v", svcErr)
+	}
+	if len(serviceIssues) > 0 {
+		fmt.Printf("[*] Identified %d issues with one or more services at %s:\n\n", len(serviceIssues), opts.URL)
+
+		fmt.Fprintf(writer, "\n %s\t%s\t%s", "Type", "Service", "Message")
+		fmt.Fprintf(writer, "\n %s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––")
+
+		for _, issue := range serviceIssues {
+			fmt.Fprintf(writer, "\n %s\t%s\t%s", issue.Type, issue.Service, issue.Message)
+		}
+		fmt.Fprintln(writer, "")
+		writer.Flush()
+		fmt.Println()
+	} else {
+		fmt.Printf("[*] Identified zero issues with core services at %s\n", opts.URL)
+	}
 }
 
 func runHealthcheck(cmd *cobra.Command, args []string) {
-	docker.EvaluateDockerComposeStatus()
+	if watchHealth {
+		runHealthWatch()
+		return
+	}
+	if len(webhookURLs) > 0 {
+		log.Fatalln("The `--webhook` flag can only be used with `--watch`")
+	}
+
 	// initialize tabwriter
 	writer := new(tabwriter.Writer)
 	// Set minwidth, tabwidth, padding, padchar, and flags
@@ -33,13 +125,40 @@ func runHealthcheck(cmd *cobra.Command, args []string) {
 
 	defer writer.Flush()
 
+	if isRemoteHealthcheck() {
+		fmt.Printf("[+] Checking the remote Ghostwriter server's /status/ endpoint...\n")
+		checkStatusEndpoint(writer)
+		return
+	}
+
+	docker.EvaluateDockerComposeStatus()
+
 	fmt.Println("[+] Checking Ghostwriter containers and their respective health checks...")
 
-	containerIssues, dockerErr := docker.CheckDockerHealth(dev)
+	healths, dockerErr := docker.InspectContainers()
 
 	if dockerErr != nil {
 		fmt.Printf("[!] Failed to get container information from Docker: %s\n", dockerErr)
 	} else {
+		if len(healths) > 0 {
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s", "Container", "State", "Health", "Restarts", "Uptime", "Last Health Check")
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+			for _, health := range healths {
+				lastCheck := health.LastHealthOutput
+				if lastCheck == "" {
+					lastCheck = "–"
+				}
+				fmt.Fprintf(
+					writer, "\n %s\t%s\t%s\t%d\t%s\t%s",
+					health.Name, health.State, health.Health, health.RestartCount, docker.FormatUptime(health.Uptime), lastCheck,
+				)
+			}
+			fmt.Fprintln(writer, "")
+			writer.Flush()
+			fmt.Println()
+		}
+
+		containerIssues := docker.ContainerIssues(healths, healthDevMode())
 		if len(containerIssues) > 0 {
 			fmt.Printf("[*] Identified %d issues with one or more containers:\n\n", len(containerIssues))
 
@@ -49,25 +168,188 @@ func runHealthcheck(cmd *cobra.Command, args []string) {
 			for _, issue := range containerIssues {
 				fmt.Fprintf(writer, "\n %s\t%s\t%s", issue.Type, issue.Service, issue.Message)
 			}
+			fmt.Fprintln(writer, "")
+			writer.Flush()
+			fmt.Println()
 		} else {
 			fmt.Println("[*] Identified zero container issues, now testing services...")
-			serviceIssues, svcErr := utils.CheckGhostwriterHealth(dev)
-			if svcErr != nil {
-				fmt.Printf("[!] Failed to get health status from Ghostwriter's /status/ endpoint: %s\n", svcErr)
+			checkStatusEndpoint(writer)
+		}
+
+		fmt.Println("[+] Probing PostgreSQL, Redis, Hasura, and the collab server...")
+		probes, probeErr := docker.ProbeServices(healths)
+		if probeErr != nil {
+			fmt.Printf("[!] Failed to probe services: %s\n", probeErr)
+		} else if len(probes) > 0 {
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "Service", "Probe", "Latency", "Result")
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+			for _, probe := range probes {
+				fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", probe.Service, probe.Probe, probe.Latency.Round(time.Millisecond), probe.Details)
+			}
+			fmt.Fprintln(writer, "")
+			writer.Flush()
+			fmt.Println()
+
+			probeIssues := probes.Issues()
+			if len(probeIssues) > 0 {
+				fmt.Printf("[*] Identified %d issues with one or more services:\n\n", len(probeIssues))
+
+				fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "Type", "Service", "Latency", "Message")
+				fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+
+				for _, issue := range probeIssues {
+					fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s", issue.Type, issue.Service, issue.Latency.Round(time.Millisecond), issue.Message)
+				}
+				fmt.Fprintln(writer, "")
+				writer.Flush()
+				fmt.Println()
 			} else {
-				if len(serviceIssues) > 0 {
-					fmt.Printf("[*] Identified %d issues with one or more services:\n\n", len(serviceIssues))
+				fmt.Println("[*] Identified zero issues with the probed services")
+			}
+		}
 
-					fmt.Fprintf(writer, "\n %s\t%s\t%s", "Type", "Service", "Message")
-					fmt.Fprintf(writer, "\n %s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––")
+		fmt.Println("[+] Checking the host's disk space and memory...")
+		resources, resourceErr := docker.CheckHostResources(healths)
+		if resourceErr != nil {
+			fmt.Printf("[!] Failed to check the host's resources: %s\n", resourceErr)
+		} else {
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "Resource", "Size", "Used", "Available", "Use%")
+			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+			for _, disk := range resources.Disks {
+				fmt.Fprintf(
+					writer, "\n %s\t%s\t%s\t%s\t%.0f%%",
+					disk.Name, docker.FormatBytes(disk.Total), docker.FormatBytes(disk.Used), docker.FormatBytes(disk.Available), disk.UsedPercent(),
+				)
+			}
+			memory := resources.Memory
+			fmt.Fprintf(
+				writer, "\n %s\t%s\t%s\t%s\t%.0f%%",
+				"Memory", docker.FormatBytes(memory.Total), docker.FormatBytes(memory.Total-memory.Available), docker.FormatBytes(memory.Available),
+				float64(memory.Total-memory.Available)*100/float64(memory.Total),
+			)
+			fmt.Fprintln(writer, "")
+			writer.Flush()
+			fmt.Println()
+
+			resourceIssues := resources.Issues()
+			if len(resourceIssues) > 0 {
+				fmt.Printf("[*] Identified %d issues with the host's resources:\n\n", len(resourceIssues))
+
+				fmt.Fprintf(writer, "\n %s\t%s\t%s", "Type", "Service", "Message")
+				fmt.Fprintf(writer, "\n %s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––")
 
-					for _, issue := range serviceIssues {
-						fmt.Fprintf(writer, "\n %s\t%s\t%s", issue.Type, issue.Service, issue.Message)
-					}
-				} else {
-					fmt.Println("[*] Identified zero issues with core services")
+				for _, issue := range resourceIssues {
+					fmt.Fprintf(writer, "\n %s\t%s\t%s", issue.Type, issue.Service, issue.Message)
 				}
+				fmt.Fprintln(writer, "")
+				writer.Flush()
+				fmt.Println()
+			} else {
+				fmt.Println("[*] Identified zero issues with the host's resources")
+			}
+		}
+	}
+
+	// Nginx only serves the certificate in production
+	if !healthDevMode() {
+		certIssues := docker.CheckCertificateHealth()
+		if len(certIssues) > 0 {
+			fmt.Fprintln(writer, "")
+			writer.Flush()
+			fmt.Printf("\n[*] Identified %d issues with the TLS certificate:\n\n", len(certIssues))
+
+			fmt.Fprintf(writer, "\n %s\t%s\t%s", "Type", "Service", "Message")
+			fmt.Fprintf(writer, "\n %s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––")
+
+			for _, issue := range certIssues {
+				fmt.Fprintf(writer, "\n %s\t%s\t%s", issue.Type, issue.Service, issue.Message)
 			}
+		} else {
+			fmt.Println("[*] Identified zero issues with the TLS certificate")
 		}
 	}
 }
+
+// Get the options for checking the /status/ endpoint in the watch mode.
+func watchStatusOptions() docker.StatusCheckOptions {
+	opts := statusOpts
+	if opts.URL == "" {
+		opts.URL = docker.DefaultStatusURL(healthDevMode())
+	}
+	return opts
+}
+
+// Check the /status/ endpoint for the watch mode and turn an error into an issue.
+func watchStatusCheck() docker.HealthIssues {
+	issues, err := utils.CheckGhostwriterHealth(watchStatusOptions())
+	if err != nil {
+		return docker.HealthIssues{{Type: "Status", Service: "ALL", Message: fmt.Sprintf("Failed to check Ghostwriter's /status/ endpoint: %s", err)}}
+	}
+	return issues
+}
+
+// Check the containers and the /status/ endpoint for the watch mode. Errors are turned into issues,
+// so they are alerted like any other issue.
+func watchCheck() docker.HealthIssues {
+	if isRemoteHealthcheck() {
+		return watchStatusCheck()
+	}
+
+	issues, err := docker.CheckDockerHealth(healthDevMode())
+	if err != nil {
+		return docker.HealthIssues{{Type: "Docker", Service: "ALL", Message: fmt.Sprintf("Failed to get container information from Docker: %s", err)}}
+	}
+	// The /status/ endpoint is unavailable while any container has issues
+	if len(issues) > 0 {
+		return issues
+	}
+	return watchStatusCheck()
+}
+
+func runHealthWatch() {
+	if watchInterval < time.Second {
+		log.Fatalln("The `--interval` must be at least one second")
+	}
+	var webhooks []docker.Webhook
+	for _, value := range webhookURLs {
+		webhook, err := docker.ParseWebhook(value)
+		if err != nil {
+			log.Fatalf("Invalid webhook: %v", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := docker.ValidateStatusCheckOptions(watchStatusOptions()); err != nil {
+		log.Fatalf("Invalid options for the /status/ endpoint: %v", err)
+	}
+	if !isRemoteHealthcheck() {
+		docker.EvaluateDockerComposeStatus()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("[+] Checking Ghostwriter's health every %s (press Ctrl+C to stop)...\n", watchInterval)
+	if len(webhooks) > 0 {
+		fmt.Printf("[+] Posting alerts to %d webhooks\n", len(webhooks))
+	}
+	first := true
+	monitor := docker.NewHealthMonitor(webhooks)
+	monitor.Watch(ctx, watchInterval, watchCheck, func(alerts []docker.HealthAlert, err error) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		if first && len(alerts) == 0 {
+			fmt.Printf("[*] %s Identified zero issues\n", timestamp)
+		}
+		first = false
+		for _, alert := range alerts {
+			prefix := "[!]"
+			if alert.Status == docker.AlertRecovered {
+				prefix = "[+]"
+			}
+			fmt.Printf("%s %s %s\n", prefix, timestamp, alert)
+		}
+		if err != nil {
+			fmt.Printf("[!] %s %s\n", timestamp, err)
+		}
+	})
+	fmt.Println("[+] Stopped watching Ghostwriter's health")
+}
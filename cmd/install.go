@@ -33,12 +33,12 @@ func installGhostwriter(cmd *cobra.Command, args []string) {
 	docker.EvaluateDockerComposeStatus()
 	if dev {
 		fmt.Println("[+] Starting development environment installation")
-		docker.SetDevMode()
+		selectMode()
 		docker.RunDockerComposeInstall("local.yml")
 	} else {
 		fmt.Println("[+] Starting production environment installation")
-		docker.SetProductionMode()
-		docker.GenerateCertificatePackage()
+		selectMode()
+		docker.GenerateCertificatePackage(docker.DefaultCertOptions())
 		docker.RunDockerComposeInstall("production.yml")
 	}
 
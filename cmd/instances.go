@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// instancesCmd represents the instances command
+var instancesCmd = &cobra.Command{
+	Use:   "instances",
+	Short: "Manage multiple named Ghostwriter instances on this host",
+	Long: `Manage multiple named Ghostwriter instances on this host with subcommands.
+
+Every named instance has its own environment file (in the "instances/" directory),
+Docker Compose project name, volume names, and port assignments. Target an instance
+with the global "--instance" flag. Commands target the "default" instance (the
+".env" file) when the flag is not set.
+
+For example:
+	ghostwriter-cli instances create training
+	ghostwriter-cli install --instance training`,
+}
+
+func init() {
+	rootCmd.AddCommand(instancesCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var nginxPort string
+var djangoPort string
+
+// instancesCreateCmd represents the instances create command
+var instancesCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new named Ghostwriter instance",
+	Long: `Create a new named Ghostwriter instance with its own environment file. The instance
+uses the "ghostwriter_<name>" Docker Compose project name, so its containers and volumes do
+not collide with other instances.
+
+Ports that are not provided are assigned automatically to avoid conflicts with the other
+instances. New passwords and secrets are generated for the instance.
+
+Names may contain lowercase letters, numbers, dashes, and underscores.
+
+For example:
+	ghostwriter-cli instances create training --nginx-port 8443 --django-port 8001`,
+	Args: cobra.ExactArgs(1),
+	Run:  createInstance,
+}
+
+func init() {
+	instancesCmd.AddCommand(instancesCreateCmd)
+
+	instancesCreateCmd.Flags().StringVar(&nginxPort, "nginx-port", "", "HTTPS port for the instance's Nginx service")
+	instancesCreateCmd.Flags().StringVar(&djangoPort, "django-port", "", "Port for the instance's Django service")
+}
+
+func createInstance(cmd *cobra.Command, args []string) {
+	created, err := env.CreateInstance(args[0], nginxPort, djangoPort)
+	if err != nil {
+		log.Fatalf("Failed to create the instance: %v", err)
+	}
+
+	// Load the new instance once to populate its environment file with defaults and fresh secrets
+	if err := env.SetActiveInstance(created.Name); err != nil {
+		log.Fatalln(err)
+	}
+	env.ParseGhostwriterEnvironmentVariables()
+
+	fmt.Printf("[+] Created the `%s` instance with the `%s` project name\n", created.Name, created.Project)
+	fmt.Printf("[+] Nginx will use port %s and Django will use port %s\n", created.NginxPort, created.DjangoPort)
+	fmt.Printf("[+] Target the instance with `--instance %s` (e.g., `ghostwriter-cli install --instance %s`)\n", created.Name, created.Name)
+}
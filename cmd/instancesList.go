@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+// instancesListCmd represents the instances list command
+var instancesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all Ghostwriter instances on this host",
+	Long: `List all Ghostwriter instances on this host with their Docker Compose project names,
+environment files, and port assignments. The active instance is marked with a "*".`,
+	Args: cobra.NoArgs,
+	Run:  listInstances,
+}
+
+func init() {
+	instancesCmd.AddCommand(instancesListCmd)
+}
+
+func listInstances(cmd *cobra.Command, args []string) {
+	instances, err := env.ListInstances()
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	// initialize tabwriter
+	writer := new(tabwriter.Writer)
+	// Set minwidth, tabwidth, padding, padchar, and flags
+	writer.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	defer writer.Flush()
+
+	fmt.Printf("[+] Found %d Ghostwriter instances\n", len(instances))
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "Name", "Project", "Nginx Port", "Django Port", "Environment File")
+	fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+	active := env.GetActiveInstance()
+	for _, i := range instances {
+		name := i.Name
+		if i.Name == active.Name {
+			name = "* " + name
+		}
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", name, i.Project, i.NginxPort, i.DjangoPort, i.EnvFile)
+	}
+	fmt.Fprintln(writer, "")
+}
@@ -0,0 +1,482 @@
+package internal
+
+// Functions for obtaining certificates for the Nginx container from an ACME server (RFC 8555) like
+// Let's Encrypt with the "golang.org/x/crypto/acme" client. The HTTP-01 and DNS-01 challenges are supported,
+// and the client can be pointed at a local test server like Pebble with a custom directory URL and CA file.
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	// Let's Encrypt's production directory
+	DefaultACMEDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+
+	// Supported ACME challenge types
+	ChallengeHTTP = "http-01"
+	ChallengeDNS  = "dns-01"
+
+	// Certificates are renewed when they expire within this window
+	acmeRenewBefore = 30 * 24 * time.Hour
+	// Maximum time to wait for the ACME server to validate a domain or issue the certificate
+	acmeWaitTimeout = 5 * time.Minute
+)
+
+// Top-level domains that public ACME servers can never validate
+var reservedTLDs = []string{"local", "localhost", "internal", "test", "invalid", "example", "lan", "home"}
+
+// ACMEOptions is a custom type for storing the settings used to obtain a certificate. The settings
+// are saved after the first successful request so "gencert renew" can repeat it.
+type ACMEOptions struct {
+	Directory string   `json:"directory"`
+	Email     string   `json:"email,omitempty"`
+	Challenge string   `json:"challenge"`
+	Domains   []string `json:"domains"`
+	CAFile    string   `json:"ca_file,omitempty"`
+	HTTPPort  int      `json:"http_port"`
+	DNSHook   string   `json:"dns_hook,omitempty"`
+	KeyType   string   `json:"key_type,omitempty"`
+}
+
+// Responses served for HTTP-01 challenges keyed by their paths
+type challengeResponses struct {
+	mu        sync.Mutex
+	responses map[string]string
+}
+
+func (c *challengeResponses) set(path string, response string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses[path] = response
+}
+
+func (c *challengeResponses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	response, ok := c.responses[r.URL.Path]
+	c.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(response))
+}
+
+// Get the directory holding the ACME account key and renewal settings.
+func acmeDir() string {
+	return filepath.Join(GetCwdFromExe(), "ssl", "acme")
+}
+
+// Determine if "name" is a hostname that a public ACME server could validate.
+func isPublicHostname(name string) bool {
+	if net.ParseIP(strings.Trim(name, "[]")) != nil || !strings.Contains(name, ".") || !isHostname(name) {
+		return false
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	return !Contains(reservedTLDs, labels[len(labels)-1])
+}
+
+// ACMEDomains returns the names from the allowed hosts that an ACME server can issue a certificate for with
+// the "challenge" type. Entries that match a domain and its subdomains (e.g., ".example.com") request a
+// wildcard certificate with DNS-01. The entries that can't be used are returned separately.
+func ACMEDomains(challenge string) ([]string, []string) {
+	var domains, skipped []string
+	for _, host := range splitVariable("django_allowed_hosts") {
+		name := strings.TrimPrefix(host, ".")
+		if host == "*" || !isPublicHostname(name) {
+			skipped = append(skipped, host)
+			continue
+		}
+		if !Contains(domains, name) {
+			domains = append(domains, name)
+		}
+		if strings.HasPrefix(host, ".") {
+			if challenge == ChallengeDNS {
+				domains = append(domains, "*."+name)
+			} else {
+				skipped = append(skipped, host)
+			}
+		}
+	}
+	return domains, skipped
+}
+
+// Load the ACME account key from disk or create a new one.
+func loadACMEAccountKey() (*ecdsa.PrivateKey, error) {
+	keyPath := filepath.Join(acmeDir(), "account.key")
+	if FileExists(keyPath) {
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("could not decode %s", keyPath)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(acmeDir(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Create a client for the ACME server in the "opts". The CA file is trusted in addition to the system
+// roots, which is required for test servers like Pebble.
+func newACMEClient(opts ACMEOptions, key crypto.Signer) (*acme.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &acme.Client{
+		Key:          key,
+		DirectoryURL: opts.Directory,
+		HTTPClient:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		UserAgent:    "ghostwriter-cli",
+	}, nil
+}
+
+// Register the account or look up the existing account for the key.
+func registerACMEAccount(ctx context.Context, client *acme.Client, email string) error {
+	account := &acme.Account{}
+	if email != "" {
+		account.Contact = []string{"mailto:" + email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("could not register the ACME account: %v", err)
+	}
+	return nil
+}
+
+// Determine if the Nginx container of the active instance is running. Docker being unavailable counts as not
+// running.
+func isNginxRunning() bool {
+	if CheckDockerComposeStatus() != nil {
+		return false
+	}
+	services, err := GetRunningServices()
+	return err == nil && Contains(services, "nginx")
+}
+
+// Listen on the "port" for HTTP-01 challenges. The Nginx container publishes port 80, so if the port is in use
+// while Nginx is running, Nginx is stopped until the domains are validated. The returned function closes the
+// listener and starts Nginx again if it was stopped.
+func listenForChallenges(port int) (net.Listener, func(), error) {
+	address := fmt.Sprintf(":%d", port)
+	listener, err := net.Listen("tcp", address)
+	if err == nil {
+		return listener, func() { listener.Close() }, nil
+	}
+	if !isNginxRunning() {
+		return nil, nil, fmt.Errorf("could not listen on port %d for HTTP-01 challenges (is another server using it?): %v", port, err)
+	}
+
+	yaml := ModeComposeFile()
+	fmt.Printf("[*] Nginx is using port %d, so it will be stopped while the domains are validated\n", port)
+	if stopErr := RunCmd(dockerCmd, []string{"-f", yaml, "stop", "nginx"}); stopErr != nil {
+		return nil, nil, fmt.Errorf("could not stop Nginx to answer HTTP-01 challenges on port %d: %v", port, stopErr)
+	}
+	restart := func() {
+		fmt.Println("[+] Starting Nginx again...")
+		if startErr := RunCmd(dockerCmd, []string{"-f", yaml, "start", "nginx"}); startErr != nil {
+			fmt.Printf("[!] Failed to start Nginx again (start it with `containers start`): %v\n", startErr)
+		}
+	}
+	// Docker can take a moment to release the published port
+	for i := 0; i < 10; i++ {
+		if listener, err = net.Listen("tcp", address); err == nil {
+			return listener, func() {
+				listener.Close()
+				restart()
+			}, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	restart()
+	return nil, nil, fmt.Errorf("could not listen on port %d for HTTP-01 challenges after stopping Nginx: %v", port, err)
+}
+
+// Complete the authorization at "authzURL" with the challenge type from the "opts". HTTP-01 responses are
+// added to the "responses" served by the challenge server.
+func authorizeDomain(ctx context.Context, client *acme.Client, authzURL string, opts ACMEOptions, responses *challengeResponses) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	name := authz.Identifier.Value
+
+	var challenge *acme.Challenge
+	for _, offered := range authz.Challenges {
+		if offered.Type == opts.Challenge {
+			challenge = offered
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("the ACME server did not offer the %s challenge for %s", opts.Challenge, name)
+	}
+
+	switch opts.Challenge {
+	case ChallengeHTTP:
+		response, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return err
+		}
+		responses.set(client.HTTP01ChallengePath(challenge.Token), response)
+	case ChallengeDNS:
+		value, err := client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return err
+		}
+		record := "_acme-challenge." + strings.TrimPrefix(name, "*.")
+		if err := runDNSHook(opts.DNSHook, "present", record, value); err != nil {
+			return err
+		}
+		defer runDNSHook(opts.DNSHook, "cleanup", record, value)
+	}
+
+	fmt.Printf("[*] Validating %s with the %s challenge...\n", name, opts.Challenge)
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, acmeWaitTimeout)
+	defer cancel()
+	if _, err := client.WaitAuthorization(waitCtx, authz.URI); err != nil {
+		return fmt.Errorf("validation failed for %s: %v", name, err)
+	}
+	fmt.Printf("[+] Validated %s\n", name)
+	return nil
+}
+
+// Order a certificate for the "opts" domains and return the PEM-encoded chain and private key.
+func obtainCertificate(ctx context.Context, client *acme.Client, opts ACMEOptions) ([]byte, crypto.Signer, error) {
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(opts.Domains...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the certificate order: %v", err)
+	}
+
+	responses := &challengeResponses{responses: make(map[string]string)}
+	if opts.Challenge == ChallengeHTTP && order.Status != acme.StatusReady {
+		listener, closeListener, err := listenForChallenges(opts.HTTPPort)
+		if err != nil {
+			return nil, nil, err
+		}
+		server := &http.Server{Handler: responses, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		defer func() {
+			server.Close()
+			closeListener()
+		}()
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := authorizeDomain(ctx, client, authzURL, opts, responses); err != nil {
+			return nil, nil, err
+		}
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, acmeWaitTimeout)
+	defer cancel()
+	if order, err = client.WaitOrder(waitCtx, order.URI); err != nil {
+		return nil, nil, fmt.Errorf("the ACME server did not accept the certificate order: %v", err)
+	}
+
+	// Create the certificate's key and request
+	priv, err := generatePrivateKey(opts.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	commonName := opts.Domains[0]
+	for _, domain := range opts.Domains {
+		if !strings.HasPrefix(domain, "*.") {
+			commonName = domain
+			break
+		}
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: opts.Domains,
+	}, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	ders, _, err := client.CreateOrderCert(waitCtx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not obtain the certificate: %v", err)
+	}
+	var chain []byte
+	for _, der := range ders {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return chain, priv, nil
+}
+
+// Run the DNS hook with the "action" ("present" or "cleanup"), record name, and record value. Without a hook,
+// ask the user to create the TXT record and wait until they confirm it.
+func runDNSHook(hook string, action string, record string, value string) error {
+	if hook == "" {
+		if action == "present" {
+			fmt.Printf("[*] Create a TXT record for `%s` with this value: %s\n", record, value)
+			fmt.Print("[*] Press enter once the record is published...")
+			if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+				return fmt.Errorf("could not read confirmation: %v", err)
+			}
+		} else {
+			fmt.Printf("[*] You can now remove the TXT record for `%s`\n", record)
+		}
+		return nil
+	}
+	command := exec.Command(hook, action, record, value)
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("DNS hook `%s %s` failed: %v", hook, action, err)
+	}
+	return nil
+}
+
+// ObtainACMECertificate requests a certificate from the ACME server and writes it to "ssl/ghostwriter.crt"
+// and "ssl/ghostwriter.key". The settings are saved so "RenewACMECertificate()" can repeat the request.
+func ObtainACMECertificate(opts ACMEOptions) error {
+	if opts.Directory == "" {
+		opts.Directory = DefaultACMEDirectory
+	}
+	if opts.Challenge != ChallengeHTTP && opts.Challenge != ChallengeDNS {
+		return fmt.Errorf("unknown challenge type `%s` (use %s or %s)", opts.Challenge, ChallengeHTTP, ChallengeDNS)
+	}
+	if opts.HTTPPort == 0 {
+		opts.HTTPPort = 80
+	}
+	if opts.KeyType == "" {
+		opts.KeyType = KeyECDSAP384
+	}
+	if opts.KeyType == KeyEd25519 {
+		return fmt.Errorf("ACME servers like Let's Encrypt don't issue certificates for %s keys", KeyEd25519)
+	}
+	if len(opts.Domains) == 0 {
+		var skipped []string
+		opts.Domains, skipped = ACMEDomains(opts.Challenge)
+		for _, host := range skipped {
+			fmt.Printf("[*] Skipping `%s` because the ACME server can't validate it with %s\n", host, opts.Challenge)
+		}
+	}
+	if len(opts.Domains) == 0 {
+		return errors.New("none of the allowed hosts are public domain names (add one with `config allowhost` or use `--domain`)")
+	}
+	for _, domain := range opts.Domains {
+		if strings.HasPrefix(domain, "*.") && opts.Challenge != ChallengeDNS {
+			return fmt.Errorf("the wildcard `%s` requires the %s challenge", domain, ChallengeDNS)
+		}
+	}
+
+	key, err := loadACMEAccountKey()
+	if err != nil {
+		return fmt.Errorf("could not load the ACME account key: %v", err)
+	}
+	client, err := newACMEClient(opts, key)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := registerACMEAccount(ctx, client, opts.Email); err != nil {
+		return err
+	}
+	fmt.Printf("[*] Requesting a certificate for %s from %s\n", strings.Join(opts.Domains, ", "), opts.Directory)
+	chain, priv, err := obtainCertificate(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	certPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt")
+	keyPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key")
+	if err := writeKeyPair(certPath, keyPath, chain, priv); err != nil {
+		return err
+	}
+	fmt.Printf("[+] Wrote the certificate to %s and the key to %s\n", certPath, keyPath)
+
+	settings, err := json.MarshalIndent(opts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(acmeDir(), "renewal.json"), settings, 0600)
+}
+
+// RenewACMECertificate repeats the last successful ACME request if the current certificate expires within
+// 30 days or "force" is true. Returns true if a new certificate was written.
+func RenewACMECertificate(force bool) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(acmeDir(), "renewal.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, errors.New("no ACME settings found (run `gencert --acme` first)")
+		}
+		return false, err
+	}
+	var opts ACMEOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return false, fmt.Errorf("could not parse the ACME settings: %v", err)
+	}
+
+	if !force {
+		certData, err := os.ReadFile(filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt"))
+		if err == nil {
+			if block, _ := pem.Decode(certData); block != nil {
+				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+					remaining := time.Until(cert.NotAfter)
+					if remaining > acmeRenewBefore {
+						fmt.Printf("[+] The certificate expires in %d days, so it doesn't need to be renewed yet\n", int(remaining.Hours()/24))
+						return false, nil
+					}
+				}
+			}
+		}
+	}
+
+	if err := ObtainACMECertificate(opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
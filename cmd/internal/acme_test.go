@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Minimal ACME server that validates HTTP-01 challenges against the client's challenge server
+type fakeACME struct {
+	t        *testing.T
+	server   *httptest.Server
+	mu       sync.Mutex
+	account  *ecdsa.PublicKey
+	thumb    string
+	tokens   map[string]string
+	valid    map[string]bool
+	httpPort int
+	chain    []byte
+	caKey    *ecdsa.PrivateKey
+	caCert   *x509.Certificate
+}
+
+// Verify a flattened JWS and return its decoded payload.
+func (f *fakeACME) verify(r *http.Request) []byte {
+	var jws map[string]string
+	body, _ := io.ReadAll(r.Body)
+	assert.NoError(f.t, json.Unmarshal(body, &jws), "Expected a JSON JWS")
+	header, _ := base64.RawURLEncoding.DecodeString(jws["protected"])
+	var protected struct {
+		Alg string            `json:"alg"`
+		URL string            `json:"url"`
+		JWK map[string]string `json:"jwk"`
+		KID string            `json:"kid"`
+	}
+	assert.NoError(f.t, json.Unmarshal(header, &protected), "Expected a JSON protected header")
+	assert.Equal(f.t, "ES256", protected.Alg)
+	assert.Equal(f.t, f.server.URL+r.URL.Path, protected.URL)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if protected.JWK != nil {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.JWK["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.JWK["y"])
+		f.account = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		canonical := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, protected.JWK["x"], protected.JWK["y"])
+		sum := sha256.Sum256([]byte(canonical))
+		f.thumb = base64.RawURLEncoding.EncodeToString(sum[:])
+	} else {
+		assert.Equal(f.t, f.server.URL+"/acct/1", protected.KID)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws["signature"])
+	digest := sha256.Sum256([]byte(jws["protected"] + "." + jws["payload"]))
+	assert.True(
+		f.t,
+		ecdsa.Verify(f.account, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])),
+		"Expected a valid JWS signature",
+	)
+	payload, _ := base64.RawURLEncoding.DecodeString(jws["payload"])
+	return payload
+}
+
+func (f *fakeACME) handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	base := f.server.URL
+	switch {
+	case r.URL.Path == "/dir":
+		json.NewEncoder(w).Encode(map[string]string{"newNonce": base + "/nonce", "newAccount": base + "/account", "newOrder": base + "/order"})
+	case r.URL.Path == "/nonce":
+	case r.URL.Path == "/account":
+		f.verify(r)
+		w.Header().Set("Location", base+"/acct/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case r.URL.Path == "/order":
+		var order struct {
+			Identifiers []map[string]string `json:"identifiers"`
+		}
+		json.Unmarshal(f.verify(r), &order)
+		var authz []string
+		for _, id := range order.Identifiers {
+			f.tokens[id["value"]] = base64.RawURLEncoding.EncodeToString([]byte(id["value"]))
+			authz = append(authz, base+"/authz/"+id["value"])
+		}
+		w.Header().Set("Location", base+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "pending", "authorizations": authz, "finalize": base + "/finalize"})
+	case strings.HasPrefix(r.URL.Path, "/authz/"):
+		f.verify(r)
+		name := strings.TrimPrefix(r.URL.Path, "/authz/")
+		status := "pending"
+		if f.valid[name] {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": name},
+			"challenges": []map[string]string{
+				{"type": ChallengeHTTP, "url": base + "/chal/" + name, "token": f.tokens[name]},
+			},
+		})
+	case strings.HasPrefix(r.URL.Path, "/chal/"):
+		f.verify(r)
+		name := strings.TrimPrefix(r.URL.Path, "/chal/")
+		res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/.well-known/acme-challenge/%s", f.httpPort, f.tokens[name]))
+		if assert.NoError(f.t, err, "Expected the challenge server to respond") {
+			keyAuth, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			f.valid[name] = string(keyAuth) == f.tokens[name]+"."+f.thumb
+		}
+		w.Write([]byte(`{"status":"processing"}`))
+	case r.URL.Path == "/finalize":
+		var finalize map[string]string
+		json.Unmarshal(f.verify(r), &finalize)
+		der, _ := base64.RawURLEncoding.DecodeString(finalize["csr"])
+		csr, err := x509.ParseCertificateRequest(der)
+		assert.NoError(f.t, err, "Expected a valid CSR")
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		leaf, _ := x509.CreateCertificate(rand.Reader, template, f.caCert, csr.PublicKey, f.caKey)
+		f.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.caCert.Raw})...)
+		w.Header().Set("Location", base+"/order/1")
+		w.Write([]byte(`{"status":"processing"}`))
+	case r.URL.Path == "/order/1":
+		f.verify(r)
+		// The order is ready once the domains are validated and valid once it's finalized
+		order := map[string]string{"status": "ready", "finalize": base + "/finalize"}
+		if f.chain != nil {
+			order["status"], order["certificate"] = "valid", base+"/cert"
+		}
+		json.NewEncoder(w).Encode(order)
+	case r.URL.Path == "/cert":
+		f.verify(r)
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(f.chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestACMEDomains(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+
+	ghostEnv.Set("django_allowed_hosts", "localhost 127.0.0.1 django ghostwriter.local gw.example.com .specterops.io *")
+	domains, skipped := ACMEDomains(ChallengeHTTP)
+	assert.Equal(t, []string{"gw.example.com", "specterops.io"}, domains)
+	assert.Equal(t, []string{"localhost", "127.0.0.1", "django", "ghostwriter.local", ".specterops.io", "*"}, skipped)
+	domains, _ = ACMEDomains(ChallengeDNS)
+	assert.Equal(t, []string{"gw.example.com", "specterops.io", "*.specterops.io"}, domains)
+
+	assert.Error(t, ObtainACMECertificate(ACMEOptions{Challenge: "tls-alpn-01"}), "Expected an error for an unsupported challenge")
+	assert.Error(t, ObtainACMECertificate(ACMEOptions{Challenge: ChallengeHTTP, Domains: []string{"*.example.com"}}), "Expected an error for a wildcard with HTTP-01")
+}
+
+func TestObtainACMECertificate(t *testing.T) {
+	defer quietTests()()
+
+	// Test CA that signs the issued certificates
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake ACME Root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	// Reserve a free port for the HTTP-01 challenge server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err, "Expected to reserve a port")
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	fake := &fakeACME{t: t, tokens: map[string]string{}, valid: map[string]bool{}, httpPort: port, caKey: caKey, caCert: caCert}
+	fake.server = httptest.NewTLSServer(http.HandlerFunc(fake.handler))
+	defer fake.server.Close()
+
+	// Trust the test server's certificate like Pebble's "pebble.minica.pem"
+	caFile := filepath.Join(t.TempDir(), "acme-ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: fake.server.Certificate().Raw}), 0644)
+	os.MkdirAll(filepath.Join(GetCwdFromExe(), "ssl"), 0755)
+
+	opts := ACMEOptions{
+		Directory: fake.server.URL + "/dir",
+		Challenge: ChallengeHTTP,
+		Domains:   []string{"ghostwriter.example.com", "www.example.com"},
+		CAFile:    caFile,
+		HTTPPort:  port,
+	}
+	assert.NoError(t, ObtainACMECertificate(opts), "Expected `ObtainACMECertificate()` to return no error")
+
+	// The chain and key are written for Nginx
+	certData, err := os.ReadFile(filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt"))
+	assert.NoError(t, err, "Expected `ghostwriter.crt` to exist")
+	block, rest := pem.Decode(certData)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err, "Expected a valid leaf certificate")
+	assert.Equal(t, opts.Domains, leaf.DNSNames)
+	assert.NotEmpty(t, rest, "Expected the chain to include the issuer")
+	info, err := os.Stat(filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key"))
+	assert.NoError(t, err, "Expected `ghostwriter.key` to exist")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Renewal is skipped while the certificate is valid for more than 30 days
+	renewed, err := RenewACMECertificate(false)
+	assert.NoError(t, err, "Expected `RenewACMECertificate()` to return no error")
+	assert.False(t, renewed, "Expected the certificate to not be renewed yet")
+	renewed, err = RenewACMECertificate(true)
+	assert.NoError(t, err, "Expected `RenewACMECertificate()` to return no error with `force`")
+	assert.True(t, renewed, "Expected the certificate to be renewed")
+
+	// A port that is in use is reported when Nginx isn't the server using it
+	busy, err := net.Listen("tcp", ":0")
+	if assert.NoError(t, err, "Expected to reserve a port") {
+		defer busy.Close()
+		_, _, err = listenForChallenges(busy.Addr().(*net.TCPAddr).Port)
+		assert.Error(t, err, "Expected an error for a port that is in use")
+	}
+}
@@ -0,0 +1,144 @@
+package internal
+
+// Functions for converting BER-encoded ASN.1 (e.g., PKCS #12 bundles exported by Windows or Java) to DER because
+// "encoding/asn1" only reads DER
+
+import (
+	"errors"
+)
+
+const (
+	// Tags of the ASN.1 OCTET STRING in its primitive and constructed forms
+	asn1OctetString            = 0x04
+	asn1ConstructedOctetString = 0x24
+	// Maximum nesting of ASN.1 elements to prevent malicious files from exhausting the stack
+	maxBERDepth = 32
+)
+
+var errTruncatedBER = errors.New("truncated ASN.1 element")
+
+// Convert a BER-encoded value ("data" parameter) to DER by resolving indefinite lengths and joining the segments
+// of constructed OCTET STRINGs. A value that is already DER is returned unchanged.
+func berToDER(data []byte) ([]byte, error) {
+	tag, content, rest, err := parseBERElement(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after the ASN.1 element")
+	}
+	return encodeDERElement(tag, content), nil
+}
+
+// Read the length of a BER element from the start of "data" and return it with the number of bytes it used.
+func readBERLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errTruncatedBER
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+	size := int(data[0] & 0x7f)
+	if size == 0 || size > 4 {
+		return 0, 0, errors.New("unsupported ASN.1 length")
+	}
+	if len(data) < 1+size {
+		return 0, 0, errTruncatedBER
+	}
+	length := 0
+	for _, b := range data[1 : 1+size] {
+		length = length<<8 | int(b)
+	}
+	if length < 0 {
+		return 0, 0, errors.New("unsupported ASN.1 length")
+	}
+	return length, 1 + size, nil
+}
+
+// Parse the BER element at the start of "data" and return its tag, its DER-encoded content, and the remaining
+// bytes after the element.
+func parseBERElement(data []byte, depth int) (byte, []byte, []byte, error) {
+	if depth > maxBERDepth {
+		return 0, nil, nil, errors.New("ASN.1 elements are nested too deeply")
+	}
+	if len(data) < 2 {
+		return 0, nil, nil, errTruncatedBER
+	}
+	tag := data[0]
+	if tag&0x1f == 0x1f {
+		return 0, nil, nil, errors.New("unsupported ASN.1 tag")
+	}
+	constructed := tag&0x20 != 0
+	data = data[1:]
+
+	var body, rest []byte
+	indefinite := data[0] == 0x80
+	if indefinite {
+		if !constructed {
+			return 0, nil, nil, errors.New("indefinite length on a primitive ASN.1 element")
+		}
+		body = data[1:]
+	} else {
+		length, n, err := readBERLength(data)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		data = data[n:]
+		if length > len(data) {
+			return 0, nil, nil, errTruncatedBER
+		}
+		body, rest = data[:length], data[length:]
+	}
+	if !constructed {
+		return tag, body, rest, nil
+	}
+
+	var content []byte
+	for {
+		if indefinite {
+			if len(body) >= 2 && body[0] == 0 && body[1] == 0 {
+				rest = body[2:]
+				break
+			}
+			if len(body) == 0 {
+				return 0, nil, nil, errTruncatedBER
+			}
+		} else if len(body) == 0 {
+			break
+		}
+		childTag, childContent, remaining, err := parseBERElement(body, depth+1)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		body = remaining
+		if tag == asn1ConstructedOctetString {
+			// Segments of a constructed OCTET STRING are joined into a single primitive OCTET STRING
+			if childTag != asn1OctetString {
+				return 0, nil, nil, errors.New("invalid segment in a constructed OCTET STRING")
+			}
+			content = append(content, childContent...)
+			continue
+		}
+		content = append(content, encodeDERElement(childTag, childContent)...)
+	}
+	if tag == asn1ConstructedOctetString {
+		tag = asn1OctetString
+	}
+	return tag, content, rest, nil
+}
+
+// Encode an element with the tag and content using the DER definite length form.
+func encodeDERElement(tag byte, content []byte) []byte {
+	element := []byte{tag}
+	if len(content) < 0x80 {
+		element = append(element, byte(len(content)))
+	} else {
+		var length []byte
+		for n := len(content); n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		element = append(element, 0x80|byte(len(length)))
+		element = append(element, length...)
+	}
+	return append(element, content...)
+}
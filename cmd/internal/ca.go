@@ -0,0 +1,203 @@
+package internal
+
+// Functions for managing a local certificate authority that issues the Nginx container's certificate.
+// The CA's files are kept in the "ca" directory instead of "ssl" so the CA key is never mounted in a container.
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Base name of the CA's files
+	caName = "ghostwriter-ca"
+	// Lifetime of the root certificate
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Lifetime of certificates issued by the CA
+	caLeafValidity = 365 * 24 * time.Hour
+)
+
+// CAFiles is a custom type for storing the paths to the CA's files.
+type CAFiles struct {
+	Key  string
+	PEM  string
+	DER  string
+	Leaf string
+}
+
+// GetCAFiles returns the paths to the CA's key, the root certificate in PEM and DER formats, and the issued certificate.
+func GetCAFiles() CAFiles {
+	dir := filepath.Join(GetCwdFromExe(), "ca")
+	return CAFiles{
+		Key:  filepath.Join(dir, caName+".key"),
+		PEM:  filepath.Join(dir, caName+".crt"),
+		DER:  filepath.Join(dir, caName+".der"),
+		Leaf: filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt"),
+	}
+}
+
+// Load the CA's root certificate and key from disk.
+func loadCA(files CAFiles) (*x509.Certificate, crypto.Signer, error) {
+	certData, err := os.ReadFile(files.PEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, nil, fmt.Errorf("could not decode %s", files.PEM)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyData, err := os.ReadFile(files.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ = pem.Decode(keyData)
+	if block == nil {
+		return nil, nil, fmt.Errorf("could not decode %s", files.Key)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// Create a new CA and write its key and root certificate (PEM and DER) to disk.
+func createCA(files CAFiles) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Ghostwriter"},
+			CommonName:   fmt.Sprintf("Ghostwriter Local CA (%s)", now.Format("2006-01-02")),
+		},
+		NotBefore:             now,
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	marshalKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(files.Key), 0700); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(files.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: marshalKey}), 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(files.PEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(files.DER, der, 0644); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// IssueCACertificate creates the local CA if it doesn't exist and uses it to issue a new certificate for Nginx
+// with the "opts" and the allowed hosts as SANs. The certificate and key replace "ssl/ghostwriter.crt" and
+// "ssl/ghostwriter.key".
+func IssueCACertificate(opts CertOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	files := GetCAFiles()
+	var caCert *x509.Certificate
+	var caKey crypto.Signer
+	var err error
+	if FileExists(files.PEM) && FileExists(files.Key) {
+		caCert, caKey, err = loadCA(files)
+		if err != nil {
+			return fmt.Errorf("could not load the CA: %v", err)
+		}
+		if time.Now().After(caCert.NotAfter) {
+			return fmt.Errorf("the CA expired on %s (move the %s directory to create a new CA)", caCert.NotAfter.Format("2006-01-02"), filepath.Dir(files.Key))
+		}
+		fmt.Printf("[*] Using the existing CA in %s\n", filepath.Dir(files.Key))
+	} else {
+		caCert, caKey, err = createCA(files)
+		if err != nil {
+			return fmt.Errorf("could not create the CA: %v", err)
+		}
+		fmt.Printf("[+] Created a new CA in %s\n", filepath.Dir(files.Key))
+	}
+
+	key, err := generatePrivateKey(opts.KeyType)
+	if err != nil {
+		return err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return err
+	}
+	notBefore := time.Now()
+	notAfter := notBefore.Add(time.Duration(opts.ValidDays) * 24 * time.Hour)
+	// Certificates can't outlive their issuer
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	template := opts.template(serialNumber, notBefore, notAfter, key)
+	der, err := x509.CreateCertificate(rand.Reader, &template, caCert, key.Public(), caKey)
+	if err != nil {
+		return err
+	}
+
+	keyPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key")
+	if err := writeKeyPair(files.Leaf, keyPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key); err != nil {
+		return err
+	}
+	fmt.Printf("[+] Issued a new certificate for Nginx valid until %s\n", notAfter.Format("2006-01-02"))
+	fmt.Printf("[*] Distribute the CA certificate to browsers and operating systems: %s (PEM) or %s (DER)\n", files.PEM, files.DER)
+	return nil
+}
+
+// GenerateCACertificatePackage issues a certificate from the local CA (see "IssueCACertificate()") and writes
+// the Diffie-Helman parameters file if it doesn't exist.
+func GenerateCACertificatePackage(opts CertOptions) error {
+	sslPath := ensureSSLDir()
+
+	if err := IssueCACertificate(opts); err != nil {
+		fmt.Printf("[!] Failed to issue a certificate from the local CA: %s\n", err)
+		return err
+	}
+
+	dhErr := writeDHParams(sslPath, "dhparam", opts.DH)
+	if dhErr != nil {
+		fmt.Printf("[!] Failed to generate Diffie-Helman parameters: %s\n", dhErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Read and parse the first certificate in a PEM file.
+func readTestCertificate(t *testing.T, path string) *x509.Certificate {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err, "Expected %s to exist", path)
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err, "Expected %s to contain a certificate", path)
+	return cert
+}
+
+func TestIssueCACertificate(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	ensureSSLDir()
+	files := GetCAFiles()
+	os.RemoveAll(filepath.Dir(files.Key))
+	defer os.RemoveAll(filepath.Dir(files.Key))
+
+	ghostEnv.Set("django_allowed_hosts", "localhost 127.0.0.1 ::1 nginx .ghostwriter.local *")
+	dnsNames, ips := CertificateSANs()
+	assert.Equal(t, []string{"localhost", "nginx", "ghostwriter.local", "*.ghostwriter.local"}, dnsNames)
+	assert.Len(t, ips, 2)
+
+	// The first run creates the CA and issues a certificate
+	assert.NoError(t, IssueCACertificate(DefaultCertOptions()), "Expected `IssueCACertificate()` to return no error")
+	root := readTestCertificate(t, files.PEM)
+	assert.True(t, root.IsCA, "Expected the root certificate to be a CA")
+	der, err := os.ReadFile(files.DER)
+	assert.NoError(t, err, "Expected the DER export to exist")
+	assert.Equal(t, root.Raw, der, "Expected the DER export to match the PEM root")
+	assert.False(t, strings.HasPrefix(files.Key, filepath.Join(GetCwdFromExe(), "ssl")), "Expected the CA key to be kept outside the `ssl` directory")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	leaf := readTestCertificate(t, files.Leaf)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "gw.ghostwriter.local"})
+	assert.NoError(t, err, "Expected the certificate to be trusted by the CA for a subdomain")
+	assert.True(t, leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+
+	// Later runs reuse the CA and issue a new certificate
+	assert.NoError(t, IssueCACertificate(DefaultCertOptions()), "Expected `IssueCACertificate()` to return no error")
+	assert.Equal(t, root.Raw, readTestCertificate(t, files.PEM).Raw, "Expected the CA to be reused")
+	reissued := readTestCertificate(t, files.Leaf)
+	assert.NotEqual(t, leaf.SerialNumber, reissued.SerialNumber, "Expected a new certificate")
+	_, err = reissued.Verify(x509.VerifyOptions{Roots: roots, DNSName: "localhost"})
+	assert.NoError(t, err, "Expected the new certificate to be trusted by the same CA")
+}
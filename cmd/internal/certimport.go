@@ -0,0 +1,390 @@
+package internal
+
+// Functions for importing a certificate issued by another CA (e.g., a corporate PKI) for the Nginx container
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Allowed hosts that only resolve inside the Docker network or on the server, so certificates aren't expected to cover them
+var internalHosts = []string{"localhost", "django", "nginx", "host.docker.internal"}
+
+var errIncorrectPFX = errors.New("incorrect password for the PKCS #12 bundle")
+
+// CertImportOptions is a custom type for storing the files and settings used by "PrepareCertificateImport()".
+type CertImportOptions struct {
+	CertFile   string
+	KeyFile    string
+	ChainFiles []string
+	Password   string
+	Force      bool
+}
+
+// CertImport is a custom type for storing a validated certificate before it is installed.
+type CertImport struct {
+	Key       crypto.Signer
+	Chain     []*x509.Certificate
+	Root      *x509.Certificate
+	Complete  bool
+	Unused    []*x509.Certificate
+	Covered   []string
+	Uncovered []string
+}
+
+// Convert a parsed private key to a "crypto.Signer" if it is one of the supported key types.
+func signerFromKey(key interface{}) (crypto.Signer, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return k.(crypto.Signer), nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// Parse a DER-encoded private key in the PKCS #8, PKCS #1, or SEC 1 formats.
+func parsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return signerFromKey(key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("could not parse the private key (expected PKCS #8, PKCS #1, or SEC 1)")
+}
+
+// Decrypt an encrypted PKCS #8 private key ("der" parameter) with the "password".
+func decryptPrivateKeyDER(der []byte, password string) (crypto.Signer, error) {
+	if password == "" {
+		return nil, errors.New("a password is required for the encrypted private key")
+	}
+	key, err := pkcs8.ParsePKCS8PrivateKey(der, []byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt the private key (is the password correct?): %v", err)
+	}
+	return signerFromKey(key)
+}
+
+// DecodePKCS12 decodes a PKCS #12 bundle ("data") with the "password" and returns the private key and all
+// certificates in the bundle. BER-encoded bundles (e.g., exported by Windows or Java) and the legacy RC2 and
+// 3DES encryption are supported.
+func DecodePKCS12(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	if der, err := berToDER(data); err == nil {
+		data = der
+	}
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) || errors.Is(err, pkcs12.ErrDecryption) {
+		return nil, nil, errIncorrectPFX
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, err := signerFromKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, append([]*x509.Certificate{cert}, caCerts...), nil
+}
+
+// Read the certificates and private key from a PEM, DER, or PKCS #12 file. Encrypted PKCS #8 keys and PKCS #12
+// bundles are decrypted with the "password".
+func readCertificateFile(path string, password string) ([]*x509.Certificate, crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		var certs []*x509.Certificate
+		var key crypto.Signer
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if _, ok := block.Headers["Proc-Type"]; ok {
+				return nil, nil, fmt.Errorf("%s uses legacy PEM encryption (convert the key to PKCS #8 with `openssl pkcs8 -topk8`)", path)
+			}
+			var blockKey crypto.Signer
+			switch block.Type {
+			case "CERTIFICATE", "TRUSTED CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not parse a certificate in %s: %v", path, err)
+				}
+				certs = append(certs, cert)
+				continue
+			case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+				blockKey, err = parsePrivateKeyDER(block.Bytes)
+			case "ENCRYPTED PRIVATE KEY":
+				blockKey, err = decryptPrivateKeyDER(block.Bytes, password)
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not read the private key in %s: %v", path, err)
+			}
+			if key != nil {
+				return nil, nil, fmt.Errorf("%s contains more than one private key", path)
+			}
+			key = blockKey
+		}
+		if len(certs) == 0 && key == nil {
+			return nil, nil, fmt.Errorf("no certificates or private keys found in %s", path)
+		}
+		return certs, key, nil
+	}
+
+	// DER-encoded certificates (one or more concatenated), a DER-encoded key, or a PKCS #12 bundle
+	if certs, err := x509.ParseCertificates(data); err == nil && len(certs) > 0 {
+		return certs, nil, nil
+	}
+	if key, err := parsePrivateKeyDER(data); err == nil {
+		return nil, key, nil
+	}
+	key, certs, err := DecodePKCS12(data, password)
+	if errors.Is(err, errIncorrectPFX) {
+		return nil, nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %s as PEM, DER, or PKCS #12: %v", path, err)
+	}
+	return certs, key, nil
+}
+
+// Determine if the certificate's public key belongs to the private key.
+func keyMatchesCertificate(cert *x509.Certificate, key crypto.Signer) bool {
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(key.Public())
+}
+
+// Order the certificate chain starting at the "leaf" with the other certificates ("pool" parameter). The
+// self-signed root is returned separately because Nginx should not send it. The chain is complete if it ends
+// with a root from the pool or a root trusted by the system.
+func orderChain(leaf *x509.Certificate, pool []*x509.Certificate) ([]*x509.Certificate, *x509.Certificate, bool, []*x509.Certificate) {
+	chain := []*x509.Certificate{leaf}
+	if isSelfSigned(leaf) {
+		return chain, nil, true, pool
+	}
+	remaining := append([]*x509.Certificate{}, pool...)
+	var root *x509.Certificate
+	for current := leaf; ; {
+		next := -1
+		for i, candidate := range remaining {
+			if bytes.Equal(current.RawIssuer, candidate.RawSubject) && current.CheckSignatureFrom(candidate) == nil {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		current = remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+		if isSelfSigned(current) {
+			root = current
+			break
+		}
+		chain = append(chain, current)
+	}
+	if root != nil {
+		return chain, root, true, remaining
+	}
+
+	// The chain may end with an intermediate signed by a root in the system's trust store
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	return chain, nil, err == nil, remaining
+}
+
+// Check which allowed hosts are covered by the certificate. Wildcard entries (e.g., ".example.com") are checked
+// against their domain, and the "*" entry, loopback addresses, and internal hostnames are skipped.
+func certificateCoverage(cert *x509.Certificate) ([]string, []string) {
+	var covered, uncovered []string
+	for _, host := range splitVariable("django_allowed_hosts") {
+		name := strings.Trim(strings.TrimPrefix(host, "."), "[]")
+		if host == "*" || Contains(internalHosts, strings.ToLower(name)) {
+			continue
+		}
+		if ip := net.ParseIP(name); ip != nil && ip.IsLoopback() {
+			continue
+		}
+		if cert.VerifyHostname(name) == nil {
+			covered = append(covered, host)
+		} else {
+			uncovered = append(uncovered, host)
+		}
+	}
+	return covered, uncovered
+}
+
+// PrepareCertificateImport reads the certificate, key, and chain files in the "opts" and validates them without
+// changing the installed certificate. The leaf certificate is the one that matches the private key. An error is
+// returned if the leaf is expired or does not cover any of the allowed hosts unless "opts.Force" is true.
+func PrepareCertificateImport(opts CertImportOptions) (CertImport, error) {
+	var result CertImport
+	certs, key, err := readCertificateFile(opts.CertFile, opts.Password)
+	if err != nil {
+		return result, err
+	}
+	if opts.KeyFile != "" {
+		keyCerts, fileKey, err := readCertificateFile(opts.KeyFile, opts.Password)
+		if err != nil {
+			return result, err
+		}
+		if fileKey == nil {
+			return result, fmt.Errorf("no private key found in %s", opts.KeyFile)
+		}
+		if key != nil && !bytes.Equal(publicKeyDER(key), publicKeyDER(fileKey)) {
+			return result, fmt.Errorf("%s and %s contain different private keys", opts.CertFile, opts.KeyFile)
+		}
+		key = fileKey
+		certs = append(certs, keyCerts...)
+	}
+	for _, path := range opts.ChainFiles {
+		chainCerts, _, err := readCertificateFile(path, opts.Password)
+		if err != nil {
+			return result, err
+		}
+		certs = append(certs, chainCerts...)
+	}
+	if key == nil {
+		return result, errors.New("no private key found (use the `--key` flag if it is in a separate file)")
+	}
+	if len(certs) == 0 {
+		return result, errors.New("no certificates found")
+	}
+	result.Key = key
+
+	// The leaf is the certificate for the private key and the rest are chain candidates
+	var leaf *x509.Certificate
+	var seen, pool []*x509.Certificate
+	for _, cert := range certs {
+		if containsCertificate(seen, cert) {
+			continue
+		}
+		seen = append(seen, cert)
+		if leaf == nil && keyMatchesCertificate(cert, key) {
+			leaf = cert
+			continue
+		}
+		pool = append(pool, cert)
+	}
+	if leaf == nil {
+		return result, errors.New("the private key does not match any of the certificates")
+	}
+
+	result.Chain, result.Root, result.Complete, result.Unused = orderChain(leaf, pool)
+	result.Covered, result.Uncovered = certificateCoverage(leaf)
+
+	now := time.Now()
+	var problems []string
+	if now.After(leaf.NotAfter) {
+		problems = append(problems, fmt.Sprintf("the certificate expired on %s", leaf.NotAfter.Format("2006-01-02")))
+	}
+	if now.Before(leaf.NotBefore) {
+		problems = append(problems, fmt.Sprintf("the certificate is not valid until %s", leaf.NotBefore.Format("2006-01-02")))
+	}
+	if len(leaf.ExtKeyUsage) > 0 && !containsExtKeyUsage(leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
+		problems = append(problems, "the certificate is not valid for server authentication")
+	}
+	if len(result.Covered) == 0 {
+		problems = append(problems, "the certificate does not cover any of the allowed hosts")
+	}
+	if len(problems) > 0 && !opts.Force {
+		return result, fmt.Errorf("%s (use the `--force` flag to import it anyway)", strings.Join(problems, "; "))
+	}
+	return result, nil
+}
+
+// Encode the public key of a private key for comparisons.
+func publicKeyDER(key crypto.Signer) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil
+	}
+	return der
+}
+
+// Determine if the "certs" include the "target" certificate.
+func containsCertificate(certs []*x509.Certificate, target *x509.Certificate) bool {
+	for _, cert := range certs {
+		if cert.Equal(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine if the "usages" include the "target" extended key usage or allow any usage.
+func containsExtKeyUsage(usages []x509.ExtKeyUsage, target x509.ExtKeyUsage) bool {
+	for _, usage := range usages {
+		if usage == target || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// Copy the installed certificate and key to a timestamped directory in "ssl/backups" and return its path. An
+// empty path is returned if there is nothing to back up.
+func backupCertificates() (string, error) {
+	certPath, keyPath := certificatePaths()
+	if checkCerts(certPath, keyPath) != nil {
+		return "", nil
+	}
+	backupDir := filepath.Join(GetCwdFromExe(), "ssl", "backups", time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+	for path, perm := range map[string]os.FileMode{certPath: 0644, keyPath: 0600} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(backupDir, filepath.Base(path)), data, perm); err != nil {
+			return "", err
+		}
+	}
+	return backupDir, nil
+}
+
+// InstallCertificate backs up the installed certificate and key and replaces them with the validated "imported"
+// certificate chain and key. The path to the backup is returned.
+func InstallCertificate(imported CertImport) (string, error) {
+	ensureSSLDir()
+	backupDir, err := backupCertificates()
+	if err != nil {
+		return "", fmt.Errorf("could not back up the current certificate: %v", err)
+	}
+	var chainPEM []byte
+	for _, cert := range imported.Chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	certPath, keyPath := certificatePaths()
+	if err := writeKeyPair(certPath, keyPath, chainPEM, imported.Key); err != nil {
+		return backupDir, err
+	}
+	return backupDir, nil
+}
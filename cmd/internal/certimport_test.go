@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The fixtures in "testdata/certimport" were created with OpenSSL 3: a root and intermediate CA, a leaf for
+// "ghostwriter.example.com" and "*.example.com", and PKCS #12 bundles with the default and "-legacy" encryption
+// (password: "ghostwriter").
+func certImportFixture(name string) string {
+	return filepath.Join("testdata", "certimport", name)
+}
+
+// Concatenate the fixtures into a temporary file.
+func writeCertImportFile(t *testing.T, name string, fixtures ...string) string {
+	var data []byte
+	for _, fixture := range fixtures {
+		content, err := os.ReadFile(certImportFixture(fixture))
+		assert.NoError(t, err, "Expected to read the fixture")
+		data = append(data, content...)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, data, 0600), "Expected to write the test file")
+	return path
+}
+
+// Convert a PEM fixture to DER.
+func writeDERFile(t *testing.T, fixture string) string {
+	content, err := os.ReadFile(certImportFixture(fixture))
+	assert.NoError(t, err, "Expected to read the fixture")
+	block, _ := pem.Decode(content)
+	path := filepath.Join(t.TempDir(), fixture+".der")
+	assert.NoError(t, os.WriteFile(path, block.Bytes, 0600), "Expected to write the test file")
+	return path
+}
+
+// Re-encode the outer structure of a DER-encoded PKCS #12 bundle with an indefinite length, like the BER-encoded
+// bundles exported by Windows and Java.
+func writeBERBundle(t *testing.T, fixture string) string {
+	content, err := os.ReadFile(certImportFixture(fixture))
+	assert.NoError(t, err, "Expected to read the fixture")
+	var pfx asn1.RawValue
+	_, err = asn1.Unmarshal(content, &pfx)
+	assert.NoError(t, err, "Expected a DER-encoded bundle")
+	ber := append([]byte{0x30, 0x80}, pfx.Bytes...)
+	ber = append(ber, 0x00, 0x00)
+	path := filepath.Join(t.TempDir(), "ber-"+fixture)
+	assert.NoError(t, os.WriteFile(path, ber, 0600), "Expected to write the test file")
+	return path
+}
+
+func TestCertificateImport(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	ghostEnv.Set("django_allowed_hosts", "localhost 127.0.0.1 django ghostwriter.example.com reports.example.com .example.com ghostwriter.local")
+
+	// PEM chain out of order with the key in a separate file
+	unordered := writeCertImportFile(t, "unordered.pem", "root.pem", "leaf.pem", "intermediate.pem")
+	imported, err := PrepareCertificateImport(CertImportOptions{CertFile: unordered, KeyFile: certImportFixture("leaf.key")})
+	assert.NoError(t, err, "Expected `PrepareCertificateImport()` to return no error for PEM files")
+	if assert.Len(t, imported.Chain, 2) {
+		assert.Equal(t, "ghostwriter.example.com", imported.Chain[0].Subject.CommonName)
+		assert.Equal(t, "Example Issuing CA", imported.Chain[1].Subject.CommonName)
+	}
+	assert.Equal(t, "Example Root CA", imported.Root.Subject.CommonName)
+	assert.True(t, imported.Complete, "Expected the chain to be complete")
+	assert.Empty(t, imported.Unused)
+	assert.Equal(t, []string{"ghostwriter.example.com", "reports.example.com"}, imported.Covered)
+	// The wildcard SAN doesn't cover the domain itself
+	assert.Equal(t, []string{".example.com", "ghostwriter.local"}, imported.Uncovered)
+
+	// DER leaf and intermediate with an encrypted PKCS #8 key
+	opts := CertImportOptions{
+		CertFile:   writeDERFile(t, "leaf.pem"),
+		KeyFile:    certImportFixture("leaf-encrypted.key"),
+		ChainFiles: []string{writeDERFile(t, "intermediate.pem")},
+		Password:   "ghostwriter",
+	}
+	imported, err = PrepareCertificateImport(opts)
+	assert.NoError(t, err, "Expected `PrepareCertificateImport()` to return no error for DER files")
+	assert.Len(t, imported.Chain, 2)
+	assert.Nil(t, imported.Root)
+	assert.False(t, imported.Complete, "Expected the chain to be incomplete without the root")
+	opts.Password = "wrong"
+	_, err = PrepareCertificateImport(opts)
+	assert.Error(t, err, "Expected an error for the wrong key password")
+
+	// PKCS #12 bundles, including a BER-encoded bundle
+	for _, bundle := range []string{certImportFixture("bundle.p12"), certImportFixture("bundle-legacy.p12"), writeBERBundle(t, "bundle.p12")} {
+		imported, err = PrepareCertificateImport(CertImportOptions{CertFile: bundle, Password: "ghostwriter"})
+		assert.NoError(t, err, "Expected `PrepareCertificateImport()` to return no error for %s", bundle)
+		assert.Len(t, imported.Chain, 2)
+		assert.True(t, imported.Complete, "Expected the chain to be complete for %s", bundle)
+		_, err = PrepareCertificateImport(CertImportOptions{CertFile: bundle, Password: "wrong"})
+		assert.ErrorIs(t, err, errIncorrectPFX)
+	}
+
+	// A key that doesn't match any certificate
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(otherKey)
+	otherKeyPath := filepath.Join(t.TempDir(), "other.key")
+	os.WriteFile(otherKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	_, err = PrepareCertificateImport(CertImportOptions{CertFile: certImportFixture("leaf.pem"), KeyFile: otherKeyPath})
+	assert.Error(t, err, "Expected an error for a mismatched key")
+
+	// A certificate that doesn't cover any allowed hosts is only imported with "Force"
+	ghostEnv.Set("django_allowed_hosts", "localhost ghostwriter.test")
+	bundle := CertImportOptions{CertFile: certImportFixture("bundle.p12"), Password: "ghostwriter"}
+	_, err = PrepareCertificateImport(bundle)
+	assert.Error(t, err, "Expected an error for a certificate that doesn't cover the allowed hosts")
+	bundle.Force = true
+	imported, err = PrepareCertificateImport(bundle)
+	assert.NoError(t, err, "Expected `PrepareCertificateImport()` to return no error with `Force`")
+
+	// Installing the certificate backs up the previous pair
+	ensureSSLDir()
+	certOpts := DefaultCertOptions()
+	certOpts.Force = true
+	assert.NoError(t, generateCertificates(certOpts), "Expected `generateCertificates()` to return no error")
+	previous, _ := os.ReadFile(filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt"))
+	backupDir, err := InstallCertificate(imported)
+	assert.NoError(t, err, "Expected `InstallCertificate()` to return no error")
+	defer os.RemoveAll(filepath.Join(GetCwdFromExe(), "ssl", "backups"))
+	backup, err := os.ReadFile(filepath.Join(backupDir, "ghostwriter.crt"))
+	assert.NoError(t, err, "Expected the previous certificate to be backed up")
+	assert.Equal(t, previous, backup)
+	info, err := os.Stat(filepath.Join(backupDir, "ghostwriter.key"))
+	assert.NoError(t, err, "Expected the previous key to be backed up")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	status, err := InspectCertificate()
+	assert.NoError(t, err, "Expected `InspectCertificate()` to return no error")
+	assert.True(t, status.KeyMatches, "Expected the installed certificate to match its key")
+	assert.Equal(t, 2, status.ChainLength)
+	assert.Equal(t, "RSA 2048", status.KeyType)
+}
+
+func TestBERToDER(t *testing.T) {
+	// Indefinite length SEQUENCE containing a constructed OCTET STRING with two segments
+	ber := []byte{0x30, 0x80, 0x24, 0x80, 0x04, 0x01, 'a', 0x04, 0x01, 'b', 0x00, 0x00, 0x02, 0x01, 0x05, 0x00, 0x00}
+	der, err := berToDER(ber)
+	assert.NoError(t, err, "Expected `berToDER()` to return no error")
+	assert.Equal(t, []byte{0x30, 0x07, 0x04, 0x02, 'a', 'b', 0x02, 0x01, 0x05}, der)
+
+	// DER is returned unchanged
+	der, err = berToDER(der)
+	assert.NoError(t, err, "Expected `berToDER()` to return no error for DER")
+	assert.Equal(t, []byte{0x30, 0x07, 0x04, 0x02, 'a', 'b', 0x02, 0x01, 0x05}, der)
+
+	_, err = berToDER([]byte{0x30, 0x80, 0x04, 0x01, 'a'})
+	assert.Error(t, err, "Expected `berToDER()` to return an error for a truncated element")
+}
@@ -1,69 +1,167 @@
 package internal
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
-	"github.com/Luzifer/go-dhparam"
 	"log"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// Supported private key types for certificates
 const (
-	// Bit size for Diffie-Helman parameters
-	bitSize = 2048
+	KeyECDSAP256 = "ecdsa-p256"
+	KeyECDSAP384 = "ecdsa-p384"
+	KeyRSA2048   = "rsa-2048"
+	KeyRSA4096   = "rsa-4096"
+	KeyEd25519   = "ed25519"
 )
 
-// Callback function for "go-dhparam".
-func dhCallback(r dhparam.GeneratorResult) {
-	switch r {
-	case dhparam.GeneratorFoundPossiblePrime:
-		os.Stdout.WriteString(".")
-	case dhparam.GeneratorFirstConfirmation:
-		os.Stdout.WriteString("+")
-	case dhparam.GeneratorSafePrimeFound:
-		os.Stdout.WriteString("*\n")
+// KeyTypes lists the supported private key types.
+var KeyTypes = []string{KeyECDSAP256, KeyECDSAP384, KeyRSA2048, KeyRSA4096, KeyEd25519}
+
+// CertOptions is a custom type for storing the parameters of the certificates generated for Nginx.
+type CertOptions struct {
+	ValidDays    int
+	KeyType      string
+	Organization string
+	CommonName   string
+	Force        bool
+	DH           DHOptions
+}
+
+// DefaultCertOptions returns the parameters used for certificates when no flags are provided.
+func DefaultCertOptions() CertOptions {
+	return CertOptions{
+		ValidDays:    365,
+		KeyType:      KeyECDSAP384,
+		Organization: "Ghostwriter",
+		CommonName:   "nginx",
+		DH:           DefaultDHOptions(),
 	}
 }
 
-// Generate Diffie-Helman parameters using "go-dhparam".
-func generateDHParam() ([]byte, error) {
-	dh, err := dhparam.Generate(bitSize, dhparam.GeneratorTwo, dhCallback)
-	if err != nil {
-		return nil, err
+// Validate checks the certificate parameters and prints warnings for values browsers may reject.
+func (o CertOptions) Validate() error {
+	if !Contains(KeyTypes, o.KeyType) {
+		return fmt.Errorf("unknown key type `%s` (use %s)", o.KeyType, strings.Join(KeyTypes, ", "))
 	}
-	bytes, err := dh.ToPEM()
-	if err != nil {
-		return nil, err
+	if o.ValidDays < 1 {
+		return fmt.Errorf("the validity period must be at least one day")
+	}
+	if o.ValidDays > 825 {
+		fmt.Printf("[!] Some browsers and operating systems reject certificates valid for more than 825 days\n")
+	}
+	if o.CommonName == "" {
+		return fmt.Errorf("the common name cannot be empty")
+	}
+	return o.DH.Validate()
+}
+
+// Build the certificate template for the Nginx server with the allowed hosts as SANs.
+func (o CertOptions) template(serialNumber *big.Int, notBefore time.Time, notAfter time.Time, priv crypto.Signer) x509.Certificate {
+	dnsNames, ips := CertificateSANs()
+	keyUsage := x509.KeyUsageDigitalSignature
+	// RSA key exchange encrypts the session key with the certificate's key
+	if _, ok := priv.(*rsa.PrivateKey); ok {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	var organization []string
+	if o.Organization != "" {
+		organization = []string{o.Organization}
+	}
+	return x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: organization,
+			CommonName:   o.CommonName,
+		},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
 	}
-	return bytes, nil
 }
 
-// Generate the Diffie-Helman parameters and then write the file to disk in the
-// output directory ("outputDir") with the specified "name".
-func writeDHParams(outputDir, name string) error {
-	fileName := filepath.Join(outputDir, name+".pem")
-	if FileExists(fileName) {
-		fmt.Printf("[*] Skipping DH params because %s already exists\n", fileName)
-		return nil
+// Generate a private key of the given type ("keyType" parameter).
+func generatePrivateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case KeyECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	return nil, fmt.Errorf("unknown key type `%s` (use %s)", keyType, strings.Join(KeyTypes, ", "))
+}
+
+// Encode a private key as PEM. ECDSA keys use the SEC 1 format like earlier versions and other keys use PKCS #8.
+func marshalPrivateKey(priv crypto.Signer) ([]byte, error) {
+	if key, ok := priv.(*ecdsa.PrivateKey); ok {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
 	}
-	fmt.Println("[*] Generating a new `dhparam.pem` file (this could take a few minutes)")
-	b, err := generateDHParam()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("[+] Writing DH parameters to %s\n", fileName)
-	if err := os.WriteFile(fileName, b, 0644); err != nil {
-		return err
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Generate a random serial number for a certificate.
+func newSerialNumber() (*big.Int, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	return rand.Int(rand.Reader, serialNumberLimit)
+}
+
+// CertificateSANs returns the DNS names and IP addresses from the allowed hosts for a certificate's
+// Subject Alternative Names. Entries that match a domain and its subdomains (e.g., ".example.com")
+// add the domain and a wildcard, and the "*" entry is left out.
+func CertificateSANs() ([]string, []net.IP) {
+	var dnsNames []string
+	var ips []net.IP
+	for _, host := range splitVariable("django_allowed_hosts") {
+		if host == "*" {
+			continue
+		}
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		name := strings.TrimPrefix(host, ".")
+		if !Contains(dnsNames, name) {
+			dnsNames = append(dnsNames, name)
+		}
+		if strings.HasPrefix(host, ".") && !Contains(dnsNames, "*"+host) {
+			dnsNames = append(dnsNames, "*"+host)
+		}
 	}
-	return nil
+	return dnsNames, ips
 }
 
 // Check if the SSL certificates are present in the specified "certPath" and "keyPath".
@@ -76,89 +174,81 @@ func checkCerts(certPath string, keyPath string) error {
 	return nil
 }
 
-// Generate the TLS certificates and Diffie-Helman parameters file using Go.
-func generateCertificates() error {
+// Generate a self-signed TLS certificate with the "opts". Existing certificate files are only
+// replaced if "opts.Force" is true.
+func generateCertificates(opts CertOptions) error {
 	certPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt")
 	keyPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key")
 	if checkCerts(certPath, keyPath) == nil {
-		fmt.Printf("[!] Found existing certificate files, so new ones will not be generated...\n")
-		fmt.Printf("[*] Rename or delete ssl/ghostwriter.key and ssl/ghostwriter.key if you want to replace these keys")
-		return nil
+		if !opts.Force {
+			fmt.Printf("[!] Found existing certificate files, so new ones will not be generated...\n")
+			fmt.Printf("[*] Use the `--force` flag or rename or delete ssl/ghostwriter.crt and ssl/ghostwriter.key if you want to replace these keys\n")
+			return nil
+		}
+		fmt.Printf("[*] Replacing the existing TLS/SSL certs for the Nginx container...\n")
+	} else {
+		fmt.Printf("[*] Did not find existing TLS/SSL certs for the Nginx container, so generating them now...\n")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
 	}
-	fmt.Printf("[*] Did not find existing TLS/SSL certs for the Nginx container, so generating them now...\n")
 
-	// Generate the ECDSA private key
-	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	// Generate the private key
+	priv, err := generatePrivateKey(opts.KeyType)
 	if err != nil {
 		log.Printf("Failed to generate private key: %s\n", err)
 		return err
 	}
 
-	// Set dates to today and one year from now
+	// Set dates to today and the end of the validity period
 	notBefore := time.Now()
-	oneYear := 365 * 24 * time.Hour
-	notAfter := notBefore.Add(oneYear)
+	notAfter := notBefore.Add(time.Duration(opts.ValidDays) * 24 * time.Hour)
 
 	// Generate a serial number for the certificate
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	serialNumber, err := newSerialNumber()
 	if err != nil {
 		log.Printf("Failed to generate the serial number: %s\n", err)
 		return err
 	}
 
 	// Template the certificate with necessary values
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"Ghostwriter"},
-			CommonName:   "nginx",
-		},
-		SignatureAlgorithm: x509.ECDSAWithSHA384,
-		PublicKeyAlgorithm: x509.ECDSA,
-		NotBefore:          notBefore,
-		NotAfter:           notAfter,
-
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-	}
+	template := opts.template(serialNumber, notBefore, notAfter, priv)
 
 	// Create the certificate using our private key and the template
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 	if err != nil {
 		log.Printf("Failed to create certificate: %s\n", err)
 		return err
 	}
 
-	certOut, err := os.Create(certPath)
-	if err != nil {
-		log.Printf("Failed to open %s for writing: %s\n", certPath, err)
+	if err := writeKeyPair(certPath, keyPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}), priv); err != nil {
+		log.Printf("Failed to write the certificate files: %v\n", err)
 		return err
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-	certOut.Close()
+	fmt.Printf("[+] Successfully generated new TLS/SSL certificates\n")
 
-	keyOut, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	return nil
+}
+
+// Write the PEM-encoded certificate ("certPEM") and private key ("priv") to the given paths. The key is
+// only readable by the owner.
+func writeKeyPair(certPath string, keyPath string, certPEM []byte, priv crypto.Signer) error {
+	keyPEM, err := marshalPrivateKey(priv)
 	if err != nil {
-		log.Printf("Failed to open %s for writing: %s/n", keyPath, err)
 		return err
 	}
-	marshalKey, err := x509.MarshalECPrivateKey(priv)
-	if err != nil {
-		log.Printf("Unable to marshal ECDSA private key: %v\n", err)
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
 		return err
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: marshalKey})
-	keyOut.Close()
-	fmt.Printf("[+] Successfully generated new TLS/SSL certificates\n")
-
-	return nil
+	// "WriteFile()" keeps the permissions of existing files
+	if err := os.Chmod(keyPath, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, certPEM, 0644)
 }
 
-// GenerateCertificatePackage generate TLS certificates and Diffie-Helman parameters file using Go.
-func GenerateCertificatePackage() error {
-	// Ensure the ``ssl`` directory exists to receive the keys
+// Ensure the "ssl" directory exists to receive the keys and return its path.
+func ensureSSLDir() string {
 	sslPath := filepath.Join(GetCwdFromExe(), "ssl")
 	if !DirExists(sslPath) {
 		err := os.MkdirAll(sslPath, os.ModePerm)
@@ -167,14 +257,39 @@ func GenerateCertificatePackage() error {
 		}
 		fmt.Println("[+] Successfully made the `ssl` directory")
 	}
+	return sslPath
+}
+
+// GenerateCertificatePackage generate TLS certificates and Diffie-Helman parameters file using Go.
+// The certificate and DH parameters are created with the "opts" (see "DefaultCertOptions()").
+func GenerateCertificatePackage(opts CertOptions) error {
+	sslPath := ensureSSLDir()
 
 	fmt.Println("[*] Generating new `ghostwriter.crt` and `ghostwriter.key` files")
-	certErr := generateCertificates()
+	certErr := generateCertificates(opts)
 	if certErr != nil {
 		fmt.Printf("[!] Failed to generate TLS/SSL certificate files: %s\n", certErr)
 	}
 
-	dhErr := writeDHParams(sslPath, "dhparam")
+	dhErr := writeDHParams(sslPath, "dhparam", opts.DH)
+	if dhErr != nil {
+		fmt.Printf("[!] Failed to generate Diffie-Helman parameters: %s\n", dhErr)
+	}
+
+	return nil
+}
+
+// GenerateACMECertificatePackage obtains a TLS certificate from an ACME server (see "ObtainACMECertificate()")
+// and writes the Diffie-Helman parameters file with the "dh" options if it doesn't exist.
+func GenerateACMECertificatePackage(opts ACMEOptions, dh DHOptions) error {
+	sslPath := ensureSSLDir()
+
+	if err := ObtainACMECertificate(opts); err != nil {
+		fmt.Printf("[!] Failed to obtain a certificate from the ACME server: %s\n", err)
+		return err
+	}
+
+	dhErr := writeDHParams(sslPath, "dhparam", dh)
 	if dhErr != nil {
 		fmt.Printf("[!] Failed to generate Diffie-Helman parameters: %s\n", dhErr)
 	}
@@ -1,16 +1,18 @@
 package internal
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"github.com/stretchr/testify/assert"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGenerateCertificatePackage(t *testing.T) {
 	defer quietTests()()
 
-	t.Log("Testing `GenerateCertificatePackage()` and generating DH parameters can take several minutes...")
-	GenerateCertificatePackage()
+	GenerateCertificatePackage(DefaultCertOptions())
 
 	// Paths we expect to exist after generating the certificate package
 	sslDir := filepath.Join(GetCwdFromExe(), "ssl")
@@ -26,3 +28,54 @@ func TestGenerateCertificatePackage(t *testing.T) {
 	assert.True(t, FileExists(keyPath), "Expected `ghostwriter.key` file to exist")
 	assert.True(t, FileExists(crtPath), "Expected `ghostwriter.crt` file to exist")
 }
+
+func TestGenerateCertificates(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	ensureSSLDir()
+	ghostEnv.Set("django_allowed_hosts", "localhost 127.0.0.1 ghostwriter.local *")
+
+	keyPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key")
+	crtPath := filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt")
+
+	// Invalid parameters are rejected
+	opts := DefaultCertOptions()
+	opts.Force = true
+	opts.KeyType = "dsa"
+	assert.Error(t, generateCertificates(opts), "Expected an error for an unknown key type")
+	opts.KeyType = KeyECDSAP384
+	opts.ValidDays = 0
+	assert.Error(t, generateCertificates(opts), "Expected an error for an invalid validity period")
+
+	// Every key type produces a certificate with the allowed hosts as SANs
+	expected := map[string]x509.PublicKeyAlgorithm{
+		KeyECDSAP256: x509.ECDSA,
+		KeyECDSAP384: x509.ECDSA,
+		KeyRSA2048:   x509.RSA,
+		KeyEd25519:   x509.Ed25519,
+	}
+	for keyType, algorithm := range expected {
+		opts := DefaultCertOptions()
+		opts.Force = true
+		opts.KeyType = keyType
+		opts.ValidDays = 30
+		opts.Organization = "SpecterOps"
+		opts.CommonName = "ghostwriter.local"
+		assert.NoError(t, generateCertificates(opts), "Expected `generateCertificates()` to return no error for %s", keyType)
+
+		cert := readTestCertificate(t, crtPath)
+		assert.Equal(t, algorithm, cert.PublicKeyAlgorithm, "Expected a %s key", keyType)
+		assert.Equal(t, []string{"localhost", "ghostwriter.local"}, cert.DNSNames)
+		assert.Len(t, cert.IPAddresses, 1)
+		assert.Equal(t, "ghostwriter.local", cert.Subject.CommonName)
+		assert.Equal(t, []string{"SpecterOps"}, cert.Subject.Organization)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), cert.NotAfter, time.Minute)
+		_, err := tls.LoadX509KeyPair(crtPath, keyPath)
+		assert.NoError(t, err, "Expected the key to match the certificate for %s", keyType)
+	}
+
+	// Existing files are kept without "Force"
+	before := readTestCertificate(t, crtPath)
+	assert.NoError(t, generateCertificates(DefaultCertOptions()), "Expected `generateCertificates()` to return no error")
+	assert.Equal(t, before.Raw, readTestCertificate(t, crtPath).Raw, "Expected the existing certificate to be kept")
+}
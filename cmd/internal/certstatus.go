@@ -0,0 +1,190 @@
+package internal
+
+// Functions for inspecting the Nginx container's TLS certificate and monitoring its expiry
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CertificateInfo is a custom type for storing the details of a certificate shown by "cert status".
+type CertificateInfo struct {
+	Subject     string
+	Issuer      string
+	DNSNames    []string
+	IPAddresses []string
+	KeyType     string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	SelfSigned  bool
+	Fingerprint string
+	ChainLength int
+	KeyMatches  bool
+	KeyError    string
+}
+
+// DaysLeft returns the number of whole days until the certificate expires. Expired certificates return a negative number.
+func (c CertificateInfo) DaysLeft() int {
+	return int(time.Until(c.NotAfter).Hours() / 24)
+}
+
+// Get the paths to the Nginx container's certificate and key.
+func certificatePaths() (string, string) {
+	return filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.crt"), filepath.Join(GetCwdFromExe(), "ssl", "ghostwriter.key")
+}
+
+// Describe the type and size of a certificate's public key (e.g., "ECDSA P-384").
+func describePublicKey(key interface{}) string {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return "Unknown"
+}
+
+// Return the SHA-256 fingerprint of a certificate as colon-separated hex.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	encoded := hex.EncodeToString(sum[:])
+	fingerprint := ""
+	for i := 0; i < len(encoded); i += 2 {
+		if i > 0 {
+			fingerprint += ":"
+		}
+		fingerprint += encoded[i : i+2]
+	}
+	return fingerprint
+}
+
+// Determine if a certificate is signed by its own key. This doesn't use "CheckSignatureFrom()" because
+// self-signed server certificates are usually not CA certificates.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// Summarize the certificate for "CertificateInfo".
+func describeCertificate(cert *x509.Certificate) CertificateInfo {
+	info := CertificateInfo{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		DNSNames:    cert.DNSNames,
+		KeyType:     describePublicKey(cert.PublicKey),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		SelfSigned:  isSelfSigned(cert),
+		Fingerprint: certificateFingerprint(cert),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	return info
+}
+
+// InspectCertificate parses the Nginx container's certificate ("ssl/ghostwriter.crt") and checks if it matches
+// the private key ("ssl/ghostwriter.key").
+func InspectCertificate() (CertificateInfo, error) {
+	var info CertificateInfo
+	certPath, keyPath := certificatePaths()
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return info, err
+	}
+	var chain []*x509.Certificate
+	for rest := certPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return info, fmt.Errorf("could not parse %s: %v", certPath, err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return info, fmt.Errorf("no certificates found in %s", certPath)
+	}
+
+	info = describeCertificate(chain[0])
+	info.ChainLength = len(chain)
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		info.KeyError = err.Error()
+		return info, nil
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		info.KeyError = err.Error()
+	} else {
+		info.KeyMatches = true
+	}
+	return info, nil
+}
+
+// FetchServedCertificate connects to the "address" (e.g., "localhost:443") and returns the certificate
+// presented by the server. The certificate is not verified, so self-signed certificates are returned, too.
+func FetchServedCertificate(address string, timeout time.Duration) (CertificateInfo, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return CertificateInfo{}, err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return CertificateInfo{}, fmt.Errorf("%s did not present a certificate", address)
+	}
+	info := describeCertificate(certs[0])
+	info.ChainLength = len(certs)
+	return info, nil
+}
+
+// GetNginxAddress returns the local address where Nginx serves HTTPS based on the "nginx_port" value.
+func GetNginxAddress() string {
+	return net.JoinHostPort("localhost", ghostEnv.GetString("nginx_port"))
+}
+
+// CheckCertificateHealth checks that the Nginx container's certificate exists, matches its key, and does
+// not expire within the number of days in the "healthcheck_cert_expiry_days" value.
+func CheckCertificateHealth() HealthIssues {
+	var issues HealthIssues
+	info, err := InspectCertificate()
+	if err != nil {
+		issues = append(issues, HealthIssue{Type: "Certificate", Service: "nginx", Message: fmt.Sprintf("Could not read the certificate: %v", err)})
+		return issues
+	}
+
+	threshold := ghostEnv.GetInt("healthcheck_cert_expiry_days")
+	days := info.DaysLeft()
+	switch {
+	case time.Now().After(info.NotAfter):
+		issues = append(issues, HealthIssue{Type: "Certificate", Service: "nginx", Message: fmt.Sprintf("The certificate expired on %s", info.NotAfter.Format("2006-01-02"))})
+	case days < threshold:
+		issues = append(issues, HealthIssue{Type: "Certificate", Service: "nginx", Message: fmt.Sprintf("The certificate expires in %d days on %s", days, info.NotAfter.Format("2006-01-02"))})
+	}
+	if !info.KeyMatches {
+		issues = append(issues, HealthIssue{Type: "Certificate", Service: "nginx", Message: fmt.Sprintf("The certificate does not match ghostwriter.key: %s", info.KeyError)})
+	}
+	return issues
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateStatus(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	ensureSSLDir()
+	ghostEnv.Set("django_allowed_hosts", "localhost 127.0.0.1")
+	certPath, keyPath := certificatePaths()
+
+	opts := DefaultCertOptions()
+	opts.Force = true
+	opts.ValidDays = 20
+	assert.NoError(t, generateCertificates(opts), "Expected `generateCertificates()` to return no error")
+
+	info, err := InspectCertificate()
+	assert.NoError(t, err, "Expected `InspectCertificate()` to return no error")
+	assert.Equal(t, "ECDSA P-384", info.KeyType)
+	assert.Equal(t, []string{"localhost"}, info.DNSNames)
+	assert.Equal(t, []string{"127.0.0.1"}, info.IPAddresses)
+	assert.True(t, info.SelfSigned, "Expected the certificate to be self-signed")
+	assert.True(t, info.KeyMatches, "Expected the certificate to match its key")
+	assert.Equal(t, 19, info.DaysLeft())
+
+	// The certificate expires within the default threshold of 30 days
+	issues := CheckCertificateHealth()
+	assert.Len(t, issues, 1)
+	ghostEnv.Set("healthcheck_cert_expiry_days", 10)
+	assert.Empty(t, CheckCertificateHealth(), "Expected no issues with a lower threshold")
+
+	// The certificate served by a TLS server matches the file
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	assert.NoError(t, err, "Expected the key pair to load")
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{pair}})
+	assert.NoError(t, err, "Expected the TLS listener to start")
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	served, err := FetchServedCertificate(listener.Addr().String(), time.Second)
+	assert.NoError(t, err, "Expected `FetchServedCertificate()` to return no error")
+	assert.Equal(t, info.Fingerprint, served.Fingerprint)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	ghostEnv.Set("nginx_port", port)
+	assert.Equal(t, "localhost:"+port, GetNginxAddress())
+
+	// A key from another certificate is reported as a mismatch
+	other, _ := generatePrivateKey(KeyECDSAP256)
+	otherPEM, _ := marshalPrivateKey(other)
+	os.WriteFile(keyPath, otherPEM, 0600)
+	info, err = InspectCertificate()
+	assert.NoError(t, err, "Expected `InspectCertificate()` to return no error")
+	assert.False(t, info.KeyMatches, "Expected the certificate to not match the new key")
+	assert.Len(t, CheckCertificateHealth(), 1)
+}
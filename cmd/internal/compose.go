@@ -0,0 +1,198 @@
+package internal
+
+// Functions for identifying Ghostwriter's containers with the labels Docker Compose adds to every container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Labels Docker Compose adds to the containers it creates
+	composeProjectLabel    = "com.docker.compose.project"
+	composeServiceLabel    = "com.docker.compose.service"
+	composeWorkingDirLabel = "com.docker.compose.project.working_dir"
+	// Time a Compose configuration read by "LoadComposeProject()" is reused, so long-running commands (e.g.,
+	// "exporter" and "healthcheck --watch") pick up changes to the Compose files
+	composeProjectTTL = time.Minute
+)
+
+// Vars for tracking the default services of the Compose files
+// Used when the Compose configuration can't be read (e.g., before Ghostwriter is installed)
+var (
+	prodServices = []ComposeService{
+		{"django", "ghostwriter_production_django", "ghostwriter_django"},
+		{"nginx", "ghostwriter_production_nginx", "ghostwriter_nginx"},
+		{"redis", "ghostwriter_production_redis", "ghostwriter_redis"},
+		{"postgres", "ghostwriter_production_postgres", "ghostwriter_postgres"},
+		{"graphql_engine", "ghostwriter_production_graphql", "ghostwriter_graphql"},
+		{"queue", "ghostwriter_production_queue", "ghostwriter_queue"},
+		{"collab-server", "ghostwriter_production_collab_server", "ghostwriter_collab_server"},
+	}
+	devServices = []ComposeService{
+		{"django", "ghostwriter_local_django", "ghostwriter_django"},
+		{"redis", "ghostwriter_local_redis", "ghostwriter_redis"},
+		{"postgres", "ghostwriter_local_postgres", "ghostwriter_postgres"},
+		{"graphql_engine", "ghostwriter_local_graphql", "ghostwriter_graphql"},
+		{"queue", "ghostwriter_local_queue", "ghostwriter_queue"},
+		{"collab-server", "ghostwriter_local_collab_server", "ghostwriter_collab_server"},
+		{"frontend", "ghostwriter_local_frontend", "ghostwriter_frontend"},
+	}
+	// Compose configurations successfully read by "LoadComposeProject()" keyed by the Compose file
+	composeProjects      = make(map[string]composeProjectResult)
+	composeProjectsMutex sync.Mutex
+)
+
+// ComposeService is a custom type for storing a service from the Docker Compose configuration.
+type ComposeService struct {
+	// Name of the service in the Compose file (e.g., "graphql_engine")
+	Name string
+	// Image the service's container runs
+	Image string
+	// Value of the container's "name" label (e.g., "ghostwriter_graphql")
+	Label string
+}
+
+// ComposeProject is a custom type for storing the Docker Compose project of the active instance and the services
+// it defines.
+type ComposeProject struct {
+	// Name of the project used for the "com.docker.compose.project" label
+	Name string
+	// Compose file the services were read from, empty when the default services are used
+	File     string
+	Services []ComposeService
+}
+
+type composeProjectResult struct {
+	project ComposeProject
+	loaded  time.Time
+}
+
+// Get the Compose file for the environment ("dev" parameter).
+func composeFile(dev bool) string {
+	if dev {
+		return "local.yml"
+	}
+	return "production.yml"
+}
+
+// Build the project with the default services of the environment ("dev" parameter). The project's name is
+// unknown, so containers are matched by the directory of the Compose files instead.
+func defaultComposeProject(dev bool) ComposeProject {
+	services := prodServices
+	if dev {
+		services = devServices
+	}
+	return ComposeProject{Services: append([]ComposeService(nil), services...)}
+}
+
+// Parse the output of "docker compose config --format json" for the Compose file ("file" parameter).
+func parseComposeConfig(output []byte, file string) (ComposeProject, error) {
+	var config struct {
+		Name     string `json:"name"`
+		Services map[string]struct {
+			Image  string            `json:"image"`
+			Labels map[string]string `json:"labels"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(output, &config); err != nil {
+		return ComposeProject{}, fmt.Errorf("failed to parse the Compose configuration: %w", err)
+	}
+	if config.Name == "" || len(config.Services) == 0 {
+		return ComposeProject{}, fmt.Errorf("the Compose configuration of %s has no project name or services", file)
+	}
+	project := ComposeProject{Name: config.Name, File: file}
+	for name, service := range config.Services {
+		project.Services = append(project.Services, ComposeService{Name: name, Image: service.Image, Label: service.Labels["name"]})
+	}
+	sort.Slice(project.Services, func(i, j int) bool { return project.Services[i].Name < project.Services[j].Name })
+	return project, nil
+}
+
+// LoadComposeProject reads the project and its services from the Compose file of the environment ("dev"
+// parameter) with "docker compose config" for the active instance. The result is reused for "composeProjectTTL".
+// If the configuration can't be read, the default services are returned along with the error and the next call
+// tries again.
+func LoadComposeProject(dev bool) (ComposeProject, error) {
+	return loadComposeProject(dev, time.Now(), readComposeConfig)
+}
+
+// Run "docker compose config" for the Compose file ("file" parameter) and return its JSON output.
+func readComposeConfig(file string) ([]byte, error) {
+	command := exec.Command(dockerCmd, ComposeArgs("-f", file, "config", "--format", "json")...)
+	command.Dir = GetCwdFromExe()
+	command.Env = commandEnvironment()
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("`%s compose -f %s config` failed: %w", dockerCmd, file, err)
+	}
+	return output, nil
+}
+
+// Load the project for "LoadComposeProject()" at the given time ("now" parameter), reading the configuration
+// with the "read" function when there is no cached result younger than "composeProjectTTL".
+func loadComposeProject(dev bool, now time.Time, read func(file string) ([]byte, error)) (ComposeProject, error) {
+	file := composeFile(dev)
+	composeProjectsMutex.Lock()
+	defer composeProjectsMutex.Unlock()
+	if result, ok := composeProjects[file]; ok && now.Sub(result.loaded) < composeProjectTTL {
+		return result.project, nil
+	}
+
+	output, err := read(file)
+	if err != nil {
+		return defaultComposeProject(dev), err
+	}
+	project, err := parseComposeConfig(output, file)
+	if err != nil {
+		return defaultComposeProject(dev), err
+	}
+	composeProjects[file] = composeProjectResult{project: project, loaded: now}
+	return project, nil
+}
+
+// Get the Compose project used to identify the active instance's containers, based on the recorded mode.
+func activeComposeProject() ComposeProject {
+	project, _ := LoadComposeProject(GetMode() != ModeProduction)
+	return project
+}
+
+// ServiceNames returns the sorted names of the project's services.
+func (p ComposeProject) ServiceNames() []string {
+	var names []string
+	for _, service := range p.Services {
+		names = append(names, service.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Determine if a container with the given labels is a service of the project. Without a project name (i.e.,
+// the default services), containers created from the Compose files in the CLI's directory are matched.
+func (p ComposeProject) owns(labels map[string]string) bool {
+	if labels[composeServiceLabel] == "" {
+		return false
+	}
+	if p.Name != "" {
+		return labels[composeProjectLabel] == p.Name
+	}
+	return labels[composeWorkingDirLabel] == GetCwdFromExe() && belongsToActiveInstance(labels)
+}
+
+// Get the container's name from its "name" label, falling back to its Compose service for services without one.
+func labelContainerName(labels map[string]string) string {
+	if name, ok := labels["name"]; ok && name != "" {
+		return name
+	}
+	return labels[composeServiceLabel]
+}
+
+// Determine if two Compose service names are the same, ignoring the difference between "-" and "_".
+func sameComposeService(a string, b string) bool {
+	return strings.ReplaceAll(a, "-", "_") == strings.ReplaceAll(b, "-", "_")
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseComposeConfig(t *testing.T) {
+	output := []byte(`{
+		"name": "ghostwriter",
+		"services": {
+			"graphql_engine": {"image": "ghostwriter_production_graphql", "labels": {"name": "ghostwriter_graphql"}},
+			"django": {"image": "ghostwriter_production_django", "labels": {"name": "ghostwriter_django"}},
+			"metrics": {"image": "prom/node-exporter"}
+		}
+	}`)
+	project, err := parseComposeConfig(output, "production.yml")
+	assert.NoError(t, err, "Expected `parseComposeConfig()` to return no error")
+	assert.Equal(t, "ghostwriter", project.Name)
+	assert.Equal(t, "production.yml", project.File)
+	assert.Equal(t, []string{"django", "graphql_engine", "metrics"}, project.ServiceNames())
+	assert.Equal(t, ComposeService{Name: "graphql_engine", Image: "ghostwriter_production_graphql", Label: "ghostwriter_graphql"}, project.Services[1])
+	// Services without a "name" label use their service name for logs
+	assert.Equal(t, []string{"django", "graphql", "metrics"}, logServicesFor(project))
+
+	_, err = parseComposeConfig([]byte(`{"services": {}}`), "local.yml")
+	assert.Error(t, err, "Expected an error for a configuration without a project")
+	_, err = parseComposeConfig([]byte(`not json`), "local.yml")
+	assert.Error(t, err)
+}
+
+func TestLoadComposeProjectCache(t *testing.T) {
+	composeProjects = make(map[string]composeProjectResult)
+	t.Cleanup(func() { composeProjects = make(map[string]composeProjectResult) })
+
+	reads := 0
+	output := []byte(`{"name": "ghostwriter", "services": {"django": {"image": "ghostwriter_production_django"}}}`)
+	readErr := errors.New("docker is not running")
+	read := func(file string) ([]byte, error) {
+		reads++
+		if readErr != nil {
+			return nil, readErr
+		}
+		return output, nil
+	}
+
+	// Failures return the default services and aren't cached
+	base := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	project, err := loadComposeProject(false, base, read)
+	assert.Error(t, err, "Expected `loadComposeProject()` to return the error of the read")
+	assert.Equal(t, defaultComposeProject(false), project)
+	readErr = nil
+	project, err = loadComposeProject(false, base, read)
+	assert.NoError(t, err, "Expected the configuration to be read again after a failure")
+	assert.Equal(t, "ghostwriter", project.Name)
+	assert.Equal(t, 2, reads)
+
+	// Successful reads are reused until they expire
+	_, err = loadComposeProject(false, base.Add(composeProjectTTL-time.Second), read)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reads)
+	output = []byte(`{"name": "renamed", "services": {"django": {"image": "ghostwriter_production_django"}}}`)
+	project, err = loadComposeProject(false, base.Add(composeProjectTTL), read)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", project.Name, "Expected an expired configuration to be read again")
+	assert.Equal(t, 3, reads)
+}
+
+func TestComposeProjectOwns(t *testing.T) {
+	project := ComposeProject{Name: "ghostwriter", File: "production.yml"}
+	assert.True(t, project.owns(map[string]string{composeProjectLabel: "ghostwriter", composeServiceLabel: "django"}))
+	assert.False(t, project.owns(map[string]string{composeProjectLabel: "other", composeServiceLabel: "django"}), "Expected containers of other projects to be ignored")
+	assert.False(t, project.owns(map[string]string{composeProjectLabel: "ghostwriter"}), "Expected containers without a service label to be ignored")
+
+	// Without a project name, containers created from the Compose files in the CLI's directory are matched
+	project = defaultComposeProject(false)
+	assert.True(t, project.owns(map[string]string{composeProjectLabel: "renamed", composeServiceLabel: "django", composeWorkingDirLabel: GetCwdFromExe()}))
+	assert.False(t, project.owns(map[string]string{composeProjectLabel: "renamed", composeServiceLabel: "django", composeWorkingDirLabel: "/opt/other"}))
+
+	assert.Equal(t, "ghostwriter_django", labelContainerName(map[string]string{"name": "ghostwriter_django", composeServiceLabel: "django"}))
+	assert.Equal(t, "metrics", labelContainerName(map[string]string{composeServiceLabel: "metrics"}))
+	assert.True(t, sameComposeService("collab_server", "collab-server"))
+}
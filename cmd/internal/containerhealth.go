@@ -0,0 +1,260 @@
+package internal
+
+// Functions for checking the state of Ghostwriter's containers with Docker's native health checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+)
+
+const (
+	// Containers restarted at least this many times are crash-looping if they also restarted recently
+	crashLoopRestarts = 3
+	// Window in which the latest start of a restarted container counts as recent
+	crashLoopWindow = 10 * time.Minute
+	// Maximum length of the health check output shown in reports
+	healthOutputLength = 120
+)
+
+// ContainerHealth is a custom type for storing the state of a container from "docker inspect".
+type ContainerHealth struct {
+	ID                 string
+	Name               string
+	Service            string
+	ComposeService     string
+	ComposeProject     string
+	Image              string
+	State              string
+	Health             string
+	FailingStreak      int
+	LastHealthOutput   string
+	LastHealthExitCode int
+	RestartCount       int
+	OOMKilled          bool
+	ExitCode           int
+	Error              string
+	StartedAt          time.Time
+	FinishedAt         time.Time
+	Uptime             time.Duration
+	CrashLooping       bool
+	Ports              []uint16
+	Volumes            []VolumeMount
+}
+
+// VolumeMount is a custom type for storing a Docker volume mounted in a container.
+type VolumeMount struct {
+	Name        string
+	Destination string
+}
+
+// ContainerHealths is a collection of ContainerHealth structs
+type ContainerHealths []ContainerHealth
+
+// Len returns the length of a ContainerHealths struct
+func (c ContainerHealths) Len() int {
+	return len(c)
+}
+
+// Less determines if one ContainerHealth is less than another ContainerHealth
+func (c ContainerHealths) Less(i, j int) bool {
+	return c[i].Service < c[j].Service
+}
+
+// Swap exchanges the position of two ContainerHealth values in a ContainerHealths struct
+func (c ContainerHealths) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// Get the short service name used in healthcheck reports from the image name (e.g., "DJANGO") for containers
+// without a Compose service label.
+func imageServiceName(image string) string {
+	return strings.ToUpper(image[strings.LastIndex(image, "_")+1:])
+}
+
+// Parse a timestamp from "docker inspect". Docker uses "0001-01-01T00:00:00Z" for containers that never started.
+func parseDockerTime(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || parsed.Year() <= 1 {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// Summarize the output of a health check on one line for reports.
+func summarizeHealthOutput(output string) string {
+	summary := strings.Join(strings.Fields(output), " ")
+	if len(summary) > healthOutputLength {
+		summary = summary[:healthOutputLength-3] + "..."
+	}
+	return summary
+}
+
+// Describe the container's health from the "docker inspect" response ("inspect" parameter) at the time "now".
+func describeContainerHealth(inspect container.InspectResponse, now time.Time) ContainerHealth {
+	health := ContainerHealth{
+		ID:           inspect.ID,
+		Name:         strings.TrimPrefix(inspect.Name, "/"),
+		RestartCount: inspect.RestartCount,
+		Health:       string(container.NoHealthcheck),
+	}
+	if inspect.Config != nil {
+		health.Image = inspect.Config.Image
+		if name := labelContainerName(inspect.Config.Labels); name != "" {
+			health.Name = name
+		}
+		health.ComposeService = inspect.Config.Labels[composeServiceLabel]
+		health.ComposeProject = inspect.Config.Labels[composeProjectLabel]
+		for port := range inspect.Config.ExposedPorts {
+			if port.Proto() == network.TCP {
+				health.Ports = append(health.Ports, port.Num())
+			}
+		}
+		sort.Slice(health.Ports, func(i, j int) bool { return health.Ports[i] < health.Ports[j] })
+	}
+	health.Service = imageServiceName(health.Image)
+	if health.ComposeService != "" {
+		health.Service = strings.ToUpper(health.ComposeService)
+	}
+	for _, point := range inspect.Mounts {
+		if point.Type == mount.TypeVolume {
+			health.Volumes = append(health.Volumes, VolumeMount{Name: point.Name, Destination: point.Destination})
+		}
+	}
+	if inspect.State == nil {
+		health.State = "unknown"
+		return health
+	}
+
+	state := inspect.State
+	health.State = string(state.Status)
+	health.OOMKilled = state.OOMKilled
+	health.ExitCode = state.ExitCode
+	health.Error = state.Error
+	health.StartedAt = parseDockerTime(state.StartedAt)
+	health.FinishedAt = parseDockerTime(state.FinishedAt)
+	if state.Running && !health.StartedAt.IsZero() {
+		health.Uptime = now.Sub(health.StartedAt)
+	}
+	if state.Health != nil {
+		health.Health = string(state.Health.Status)
+		health.FailingStreak = state.Health.FailingStreak
+		if len(state.Health.Log) > 0 {
+			last := state.Health.Log[len(state.Health.Log)-1]
+			health.LastHealthOutput = summarizeHealthOutput(last.Output)
+			health.LastHealthExitCode = last.ExitCode
+		}
+	}
+
+	// A container is crash-looping if Docker is restarting it or it restarted several times and
+	// the latest start was recent
+	recentStart := !health.StartedAt.IsZero() && now.Sub(health.StartedAt) < crashLoopWindow
+	health.CrashLooping = state.Restarting || (health.RestartCount >= crashLoopRestarts && recentStart)
+	return health
+}
+
+// Issues returns the healthcheck issues for the container. Containers that are still in the start period set
+// by the "healthcheck_start" value are not reported as unhealthy yet.
+func (c ContainerHealth) Issues() HealthIssues {
+	var issues HealthIssues
+	if c.CrashLooping {
+		issues = append(issues, HealthIssue{Type: "Container", Service: c.Service, Message: fmt.Sprintf("Container is crash-looping (restarted %d times, last exit code %d)", c.RestartCount, c.ExitCode)})
+	}
+	if c.OOMKilled {
+		issues = append(issues, HealthIssue{Type: "Container", Service: c.Service, Message: "Container was killed because it ran out of memory"})
+	}
+
+	switch {
+	case c.State == string(container.StateRunning):
+	case c.State == string(container.StateRestarting):
+		if !c.CrashLooping {
+			issues = append(issues, HealthIssue{Type: "Container", Service: c.Service, Message: "Container is restarting"})
+		}
+	case c.State == string(container.StateExited) || c.State == string(container.StateDead):
+		message := fmt.Sprintf("Container is %s with exit code %d", c.State, c.ExitCode)
+		if c.Error != "" {
+			message += ": " + c.Error
+		}
+		issues = append(issues, HealthIssue{Type: "Container", Service: c.Service, Message: message})
+		return issues
+	default:
+		issues = append(issues, HealthIssue{Type: "Container", Service: c.Service, Message: fmt.Sprintf("Container is %s", c.State)})
+		return issues
+	}
+
+	switch c.Health {
+	case string(container.Unhealthy):
+		message := fmt.Sprintf("Health check is failing (%d consecutive failures)", c.FailingStreak)
+		if c.LastHealthOutput != "" {
+			message += ": " + c.LastHealthOutput
+		}
+		issues = append(issues, HealthIssue{Type: "Health", Service: c.Service, Message: message})
+	case string(container.Starting):
+		startPeriod, err := time.ParseDuration(ghostEnv.GetString("healthcheck_start"))
+		if err != nil {
+			startPeriod = time.Minute
+		}
+		if c.Uptime > startPeriod {
+			issues = append(issues, HealthIssue{Type: "Health", Service: c.Service, Message: fmt.Sprintf("Health check is still starting after %s", FormatUptime(c.Uptime))})
+		}
+	}
+	return issues
+}
+
+// FormatUptime formats a duration like "docker ps" (e.g., "3 days", "5 hours", or "12 minutes").
+func FormatUptime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "–"
+	case d < time.Minute:
+		return fmt.Sprintf("%d seconds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
+
+// InspectContainers inspects every Ghostwriter container in the active instance, including stopped containers,
+// and returns their state sorted by service.
+func InspectContainers() (ContainerHealths, error) {
+	var healths ContainerHealths
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return healths, err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(context.Background(), client.ContainerListOptions{All: true})
+	if err != nil {
+		return healths, err
+	}
+	now := time.Now()
+	project := activeComposeProject()
+	for _, item := range containers.Items {
+		if !project.owns(item.Labels) {
+			continue
+		}
+		result, err := cli.ContainerInspect(context.Background(), item.ID, client.ContainerInspectOptions{})
+		if err != nil {
+			return healths, err
+		}
+		health := describeContainerHealth(result.Container, now)
+		// Use the image name from the list because "Config.Image" may be an ID
+		health.Image = item.Image
+		if health.ComposeService == "" {
+			health.Service = imageServiceName(item.Image)
+		}
+		healths = append(healths, health)
+	}
+	sort.Sort(healths)
+	return healths, nil
+}
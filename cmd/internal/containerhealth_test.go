@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a "docker inspect" response for a Ghostwriter container.
+func testInspectResponse(image string, state *container.State, restarts int) container.InspectResponse {
+	return container.InspectResponse{
+		Name:         "/ghostwriter-" + image,
+		RestartCount: restarts,
+		State:        state,
+		Config:       &container.Config{Image: image, Labels: map[string]string{"name": "ghostwriter_" + image[len("ghostwriter_production_"):]}},
+	}
+}
+
+func TestContainerHealth(t *testing.T) {
+	ParseGhostwriterEnvironmentVariables()
+	now := time.Now()
+	started := now.Add(-2 * time.Hour).Format(time.RFC3339Nano)
+
+	// Healthy container
+	healthy := describeContainerHealth(testInspectResponse("ghostwriter_production_django", &container.State{
+		Status:    container.StateRunning,
+		Running:   true,
+		StartedAt: started,
+		Health: &container.Health{
+			Status: container.Healthy,
+			Log:    []*container.HealthcheckResult{{ExitCode: 0, Output: "  ok\n"}},
+		},
+	}, 0), now)
+	assert.Equal(t, "ghostwriter_django", healthy.Name)
+	assert.Equal(t, "DJANGO", healthy.Service)
+	assert.Equal(t, "healthy", healthy.Health)
+	assert.Equal(t, "ok", healthy.LastHealthOutput)
+	assert.Equal(t, "2 hours", FormatUptime(healthy.Uptime))
+	assert.False(t, healthy.CrashLooping, "Expected a healthy container to not be crash-looping")
+	assert.Empty(t, healthy.Issues())
+
+	// Failing health check
+	unhealthy := describeContainerHealth(testInspectResponse("ghostwriter_production_graphql", &container.State{
+		Status:    container.StateRunning,
+		Running:   true,
+		StartedAt: started,
+		Health: &container.Health{
+			Status:        container.Unhealthy,
+			FailingStreak: 4,
+			Log:           []*container.HealthcheckResult{{ExitCode: 0}, {ExitCode: 1, Output: "curl: (7) Failed to connect"}},
+		},
+	}, 0), now)
+	issues := unhealthy.Issues()
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "Health check is failing (4 consecutive failures): curl: (7) Failed to connect", issues[0].Message)
+	}
+
+	// Health checks in the start period are not issues until "healthcheck_start" passes
+	starting := testInspectResponse("ghostwriter_production_queue", &container.State{
+		Status:    container.StateRunning,
+		Running:   true,
+		StartedAt: now.Add(-30 * time.Second).Format(time.RFC3339Nano),
+		Health:    &container.Health{Status: container.Starting},
+	}, 0)
+	assert.Empty(t, describeContainerHealth(starting, now).Issues())
+	assert.Len(t, describeContainerHealth(starting, now.Add(5*time.Minute)).Issues(), 1)
+
+	// Restarted several times in the last few minutes after running out of memory
+	looping := describeContainerHealth(testInspectResponse("ghostwriter_production_redis", &container.State{
+		Status:     container.StateRestarting,
+		Restarting: true,
+		OOMKilled:  true,
+		ExitCode:   137,
+		StartedAt:  now.Add(-time.Minute).Format(time.RFC3339Nano),
+	}, 5), now)
+	assert.True(t, looping.CrashLooping, "Expected the container to be crash-looping")
+	issues = looping.Issues()
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, "Container is crash-looping (restarted 5 times, last exit code 137)", issues[0].Message)
+		assert.Equal(t, "Container was killed because it ran out of memory", issues[1].Message)
+	}
+
+	// Restarts long ago are not a crash loop
+	stable := describeContainerHealth(testInspectResponse("ghostwriter_production_nginx", &container.State{
+		Status:    container.StateRunning,
+		Running:   true,
+		StartedAt: now.Add(-72 * time.Hour).Format(time.RFC3339Nano),
+	}, 5), now)
+	assert.False(t, stable.CrashLooping, "Expected old restarts to not be a crash loop")
+	assert.Equal(t, "none", stable.Health)
+
+	// Stopped container
+	exited := describeContainerHealth(testInspectResponse("ghostwriter_production_postgres", &container.State{
+		Status:     container.StateExited,
+		ExitCode:   1,
+		StartedAt:  "0001-01-01T00:00:00Z",
+		FinishedAt: now.Format(time.RFC3339Nano),
+	}, 0), now)
+	assert.True(t, exited.StartedAt.IsZero(), "Expected Docker's zero time to be parsed as a zero time")
+	issues = exited.Issues()
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "Container is exited with exit code 1", issues[0].Message)
+	}
+
+	// Missing containers are reported along with the issues of the others
+	healthy.ComposeService, unhealthy.ComposeService = "django", "graphql_engine"
+	looping.ComposeService, stable.ComposeService, exited.ComposeService = "redis", "nginx", "postgres"
+	issues = containerIssues(ContainerHealths{healthy, unhealthy, looping, stable, exited}, defaultComposeProject(false), true)
+	assert.Len(t, issues, 6)
+	assert.Contains(t, issues, HealthIssue{Type: "Container", Service: "COLLAB-SERVER", Message: "Container is not running"})
+	assert.Contains(t, issues, HealthIssue{Type: "Container", Service: "QUEUE", Message: "Container is not running"})
+	assert.Equal(t, HealthIssues{{Type: "Container", Service: "ALL", Message: "No Ghostwriter containers are running"}}, containerIssues(nil, defaultComposeProject(false), true))
+
+	// Containers that are not services in the Compose file are reported once the file was read
+	frontend := ContainerHealth{Name: "ghostwriter_frontend", Service: "FRONTEND", ComposeService: "frontend", ComposeProject: "ghostwriter", State: "running"}
+	project := ComposeProject{Name: "ghostwriter", File: "production.yml", Services: []ComposeService{{Name: "django"}}}
+	assert.Equal(t, HealthIssues{{
+		Type:    "Container",
+		Service: "FRONTEND",
+		Message: "Container is not a service in production.yml (remove it with `docker rm -f ghostwriter_frontend`)",
+	}}, containerIssues(ContainerHealths{healthy, frontend}, project, true))
+	assert.Len(t, containerIssues(ContainerHealths{healthy, frontend}, defaultComposeProject(true), true), 5, "Expected only missing containers with the default services")
+
+	// Removal is not suggested when the environment is uncertain or the container belongs to another project
+	assert.Equal(t, "Container is not a service in production.yml", containerIssues(ContainerHealths{healthy, frontend}, project, false)[0].Message)
+	frontend.ComposeProject = "other"
+	assert.Equal(t, "Container is not a service in production.yml", containerIssues(ContainerHealths{healthy, frontend}, project, true)[0].Message)
+}
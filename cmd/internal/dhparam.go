@@ -0,0 +1,235 @@
+package internal
+
+// Functions for writing the Diffie-Helman parameters used by the Nginx container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Luzifer/go-dhparam"
+)
+
+const (
+	// Default bit size for Diffie-Helman parameters
+	DHDefaultBits = 2048
+	// Range of bit sizes for generated Diffie-Helman parameters
+	dhMinBits = 2048
+	dhMaxBits = 8192
+)
+
+// Primes of the RFC 7919 finite field Diffie-Helman groups (ffdhe2048, ffdhe3072, and ffdhe4096) with generator 2
+// Reference: https://datatracker.ietf.org/doc/html/rfc7919#appendix-A
+var ffdhePrimes = map[int]string{
+	2048: "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF",
+	3072: "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF",
+	4096: "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF",
+}
+
+// DHGroupSizes lists the bit sizes of the RFC 7919 groups.
+var DHGroupSizes = []int{2048, 3072, 4096}
+
+// DHOptions is a custom type for storing how the Diffie-Helman parameters are created. The RFC 7919 group with the
+// bit size ("Bits") is used unless "Generate" is true.
+type DHOptions struct {
+	Bits     int
+	Generate bool
+}
+
+// DefaultDHOptions returns the Diffie-Helman options used when no flags are provided.
+func DefaultDHOptions() DHOptions {
+	return DHOptions{Bits: DHDefaultBits}
+}
+
+// Validate checks the bit size for the RFC 7919 group or for generated parameters.
+func (o DHOptions) Validate() error {
+	if o.Generate {
+		if o.Bits < dhMinBits || o.Bits > dhMaxBits {
+			return fmt.Errorf("generated DH parameters must be between %d and %d bits", dhMinBits, dhMaxBits)
+		}
+		return nil
+	}
+	if _, ok := ffdhePrimes[o.Bits]; !ok {
+		return fmt.Errorf("there is no RFC 7919 group with %d bits (use 2048, 3072, or 4096, or generate new parameters)", o.Bits)
+	}
+	return nil
+}
+
+// Return the RFC 7919 group with the given bit size.
+func ffdheGroup(bits int) *dhparam.DH {
+	p, _ := new(big.Int).SetString(ffdhePrimes[bits], 16)
+	return &dhparam.DH{P: p, G: int(dhparam.GeneratorTwo)}
+}
+
+// Return the largest RFC 7919 group that isn't bigger than "bits" (or the smallest group).
+func closestFFDHEGroup(bits int) int {
+	group := DHGroupSizes[0]
+	for _, size := range DHGroupSizes {
+		if size <= bits {
+			group = size
+		}
+	}
+	return group
+}
+
+// Progress of the Diffie-Helman parameter generation updated by the "go-dhparam" callback.
+type dhProgress struct {
+	candidates atomic.Int64
+	primes     atomic.Int64
+}
+
+func (p *dhProgress) callback(r dhparam.GeneratorResult) {
+	switch r {
+	case dhparam.GeneratorFoundPossiblePrime:
+		p.candidates.Add(1)
+	case dhparam.GeneratorFirstConfirmation:
+		p.primes.Add(1)
+	}
+}
+
+// Show the progress until "done" is closed. Terminals get a progress bar that is redrawn in place and other
+// outputs get a status line every 30 seconds. The time to find a safe prime can't be predicted, so the bar
+// moves back and forth to show the generation is still running.
+func (p *dhProgress) show(bits int, done <-chan struct{}) {
+	const width = 20
+	interactive := false
+	if info, err := os.Stdout.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	interval := 30 * time.Second
+	if interactive {
+		interval = 250 * time.Millisecond
+	}
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for tick := 0; ; tick++ {
+		select {
+		case <-done:
+			if interactive {
+				fmt.Print("\r\033[K")
+			}
+			return
+		case <-ticker.C:
+		}
+		elapsed := time.Since(start).Round(time.Second)
+		if !interactive {
+			fmt.Printf("[*] Still generating %d-bit DH parameters after %s (%d candidates tested)\n", bits, elapsed, p.candidates.Load())
+			continue
+		}
+		position := tick % (2 * (width - 3))
+		if position > width-3 {
+			position = 2*(width-3) - position
+		}
+		bar := strings.Repeat(" ", position) + "<=>" + strings.Repeat(" ", width-3-position)
+		fmt.Printf(
+			"\r\033[K[*] Generating %d-bit DH parameters [%s] %s, %d candidates, %d primes (Ctrl+C to cancel)",
+			bits, bar, elapsed, p.candidates.Load(), p.primes.Load(),
+		)
+	}
+}
+
+// Create the PEM-encoded Diffie-Helman parameters with the "opts". Generation stops when the context ("ctx")
+// is cancelled and the closest RFC 7919 group is used instead.
+func dhParamsPEM(ctx context.Context, opts DHOptions) ([]byte, error) {
+	if !opts.Generate {
+		fmt.Printf("[*] Using the RFC 7919 ffdhe%d group for the DH parameters\n", opts.Bits)
+		return ffdheGroup(opts.Bits).ToPEM()
+	}
+
+	fmt.Printf("[*] Generating new %d-bit DH parameters (this could take several minutes)\n", opts.Bits)
+	progress := &dhProgress{}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		progress.show(opts.Bits, done)
+		close(finished)
+	}()
+	start := time.Now()
+	dh, err := dhparam.GenerateWithContext(ctx, opts.Bits, dhparam.GeneratorTwo, progress.callback)
+	close(done)
+	<-finished
+	if errors.Is(err, context.Canceled) {
+		group := closestFFDHEGroup(opts.Bits)
+		fmt.Printf("[!] Cancelled generating DH parameters, so using the RFC 7919 ffdhe%d group instead\n", group)
+		return ffdheGroup(group).ToPEM()
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[+] Generated DH parameters in %s after testing %d candidates\n", time.Since(start).Round(time.Second), progress.candidates.Load())
+	return dh.ToPEM()
+}
+
+// Write the Diffie-Helman parameters to "fileName". Pressing Ctrl+C cancels generation.
+func saveDHParams(fileName string, opts DHOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	b, err := dhParamsPEM(ctx, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[+] Writing DH parameters to %s\n", fileName)
+	return os.WriteFile(fileName, b, 0644)
+}
+
+// Write the Diffie-Helman parameters to disk in the output directory ("outputDir") with the
+// specified "name" unless the file already exists.
+func writeDHParams(outputDir, name string, opts DHOptions) error {
+	fileName := filepath.Join(outputDir, name+".pem")
+	if FileExists(fileName) {
+		fmt.Printf("[*] Skipping DH params because %s already exists\n", fileName)
+		return nil
+	}
+	return saveDHParams(fileName, opts)
+}
+
+// ReplaceDHParams writes new Diffie-Helman parameters to "ssl/dhparam.pem" with the "opts" and replaces the
+// existing file.
+func ReplaceDHParams(opts DHOptions) error {
+	return saveDHParams(filepath.Join(ensureSSLDir(), "dhparam.pem"), opts)
+}
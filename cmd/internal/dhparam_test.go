@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Luzifer/go-dhparam"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFDHEGroups(t *testing.T) {
+	for _, bits := range DHGroupSizes {
+		group := ffdheGroup(bits)
+		assert.Equal(t, bits, group.P.BitLen())
+		assert.Equal(t, 2, group.G)
+		// RFC 7919 groups are safe primes
+		q := new(big.Int).Rsh(group.P, 1)
+		assert.True(t, group.P.ProbablyPrime(10), "Expected the ffdhe%d prime to be prime", bits)
+		assert.True(t, q.ProbablyPrime(10), "Expected the ffdhe%d prime to be a safe prime", bits)
+	}
+}
+
+func TestDHOptions(t *testing.T) {
+	defer quietTests()()
+
+	assert.NoError(t, DefaultDHOptions().Validate(), "Expected the default options to be valid")
+	assert.NoError(t, DHOptions{Bits: 4096}.Validate(), "Expected the ffdhe4096 group to be valid")
+	assert.Error(t, DHOptions{Bits: 2560}.Validate(), "Expected an error for a bit size without an RFC 7919 group")
+	assert.NoError(t, DHOptions{Bits: 2560, Generate: true}.Validate(), "Expected generating 2560 bits to be valid")
+	assert.Error(t, DHOptions{Bits: 1024, Generate: true}.Validate(), "Expected an error for generating weak parameters")
+
+	// The RFC 7919 group is written without generating parameters
+	dir := t.TempDir()
+	assert.NoError(t, writeDHParams(dir, "dhparam", DHOptions{Bits: 3072}), "Expected `writeDHParams()` to return no error")
+	data, err := os.ReadFile(filepath.Join(dir, "dhparam.pem"))
+	assert.NoError(t, err, "Expected `dhparam.pem` to exist")
+	dh, err := dhparam.Decode(data)
+	assert.NoError(t, err, "Expected valid DH parameters")
+	assert.Equal(t, ffdheGroup(3072).P, dh.P)
+
+	// Existing files are kept
+	assert.NoError(t, writeDHParams(dir, "dhparam", DHOptions{Bits: 2048}), "Expected `writeDHParams()` to return no error")
+	kept, _ := os.ReadFile(filepath.Join(dir, "dhparam.pem"))
+	assert.Equal(t, data, kept)
+
+	// Generation falls back to the closest group when cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	data, err = dhParamsPEM(ctx, DHOptions{Bits: 3584, Generate: true})
+	assert.NoError(t, err, "Expected `dhParamsPEM()` to return no error when cancelled")
+	dh, _ = dhparam.Decode(data)
+	assert.Equal(t, ffdheGroup(3072).P, dh.P)
+
+	// Small parameters to keep the test fast
+	data, err = dhParamsPEM(context.Background(), DHOptions{Bits: 256, Generate: true})
+	assert.NoError(t, err, "Expected `dhParamsPEM()` to return no error")
+	dh, _ = dhparam.Decode(data)
+	assert.Equal(t, 256, dh.P.BitLen())
+	assert.True(t, dh.P.ProbablyPrime(10), "Expected the generated parameters to use a prime")
+}
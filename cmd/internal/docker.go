@@ -2,7 +2,7 @@ package internal
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -16,32 +16,19 @@ import (
 	"github.com/moby/moby/client"
 )
 
-// Vars for tracking the list of Ghostwriter images
-// Used for filtering the list of containers returned by the Docker client
 var (
-	prodImages = []string{
-		"ghostwriter_production_django", "ghostwriter_production_nginx",
-		"ghostwriter_production_redis", "ghostwriter_production_postgres",
-		"ghostwriter_production_graphql", "ghostwriter_production_queue",
-		"ghostwriter_production_collab_server",
-	}
-	devImages = []string{
-		"ghostwriter_local_django", "ghostwriter_local_redis",
-		"ghostwriter_local_postgres", "ghostwriter_local_graphql",
-		"ghostwriter_local_queue", "ghostwriter_local_collab_server",
-		"ghostwriter_local_frontend",
-	}
 	// Default root command for Docker commands, will fallback to Podman if Docker is not found
 	dockerCmd = "docker"
 )
 
 // Container is a custom type for storing container information similar to output from "docker containers ls".
 type Container struct {
-	ID     string
-	Image  string
-	Status string
-	Ports  []container.PortSummary
-	Name   string
+	ID      string
+	Image   string
+	Status  string
+	Ports   []container.PortSummary
+	Name    string
+	Project string
 }
 
 // Containers is a collection of Container structs
@@ -63,9 +50,18 @@ func (c Containers) Swap(i, j int) {
 }
 
 // EvaluateDockerComposeStatus determines if the host has the "docker compose" plugin or the "docker compose"
-// script installed and set the global `dockerCmd` variable.
+// script installed and set the global `dockerCmd` variable. Exits if Docker or Compose is not available.
 func EvaluateDockerComposeStatus() error {
 	fmt.Println("[+] Checking the status of Docker and the Compose plugin...")
+	if err := CheckDockerComposeStatus(); err != nil {
+		log.Fatalln(err)
+	}
+	return nil
+}
+
+// CheckDockerComposeStatus performs the checks of "EvaluateDockerComposeStatus()" and returns an error instead
+// of exiting, for commands that can finish their work without Docker (e.g., renewing a certificate from cron).
+func CheckDockerComposeStatus() error {
 	// Check for ``docker`` first because it's required for everything to come
 	dockerExists := CheckPath("docker")
 	if !dockerExists {
@@ -74,7 +70,7 @@ func EvaluateDockerComposeStatus() error {
 			fmt.Println("[+] Docker is not installed, but Podman is installed. Using Podman as a Docker alternative.")
 			dockerCmd = "podman"
 		} else {
-			log.Fatalln("Neither Docker nor Podman is installed on this system, so please install Docker or Podman (in Docker compatibility mode) and try again.")
+			return errors.New("Neither Docker nor Podman is installed on this system, so please install Docker or Podman (in Docker compatibility mode) and try again.")
 		}
 	}
 
@@ -82,10 +78,9 @@ func EvaluateDockerComposeStatus() error {
 	_, engineErr := RunBasicCmd(dockerCmd, []string{"info"})
 	if engineErr != nil {
 		if strings.Contains(strings.ToLower(engineErr.Error()), "permission denied") {
-			log.Fatalf("%s is installed, but you don't have permission to talk to the daemon (Try running with sudo or adjusting your group membership)", dockerCmd)
-		} else {
-			log.Fatalf("%s is installed on this system, but the daemon may not be running", dockerCmd)
+			return fmt.Errorf("%s is installed, but you don't have permission to talk to the daemon (Try running with sudo or adjusting your group membership)", dockerCmd)
 		}
+		return fmt.Errorf("%s is installed on this system, but the daemon may not be running", dockerCmd)
 	}
 
 	// Check for the ``compose`` plugin as our first choice
@@ -96,16 +91,15 @@ func EvaluateDockerComposeStatus() error {
 		if composeScriptExists {
 			fmt.Println("[!] The deprecated `docker-compose` v1 script was detected on your system")
 			fmt.Println("[!] Docker has deprecated v1 and this CLI tool no longer supports it")
-			log.Fatalln("Please upgrade to Docker Compose v2 and try again: https://docs.docker.com/compose/install/")
-		} else {
-			log.Fatalln("Docker Compose is not installed, so please install it and try again: https://docs.docker.com/compose/install/")
+			return errors.New("Please upgrade to Docker Compose v2 and try again: https://docs.docker.com/compose/install/")
 		}
+		return errors.New("Docker Compose is not installed, so please install it and try again: https://docs.docker.com/compose/install/")
 	}
 
 	// Bail out if we're not in the same directory as the YAML files
 	// Otherwise, we'll get a confusing error message from the `compose` plugin
 	if !FileExists(filepath.Join(GetCwdFromExe(), "local.yml")) || !FileExists(filepath.Join(GetCwdFromExe(), "production.yml")) {
-		log.Fatalln("Ghostwriter CLI must be run in the same directory as the `local.yml` and `production.yml` files")
+		return errors.New("Ghostwriter CLI must be run in the same directory as the `local.yml` and `production.yml` files")
 	}
 
 	return nil
@@ -255,6 +249,17 @@ func RunDockerComposeDown(yaml string, volumes bool) {
 	}
 }
 
+// RunDockerComposeRecreate executes the "docker compose" commands to recreate only the specified "services"
+// (without their dependencies) with the specified YAML file ("yaml" parameter).
+func RunDockerComposeRecreate(yaml string, services []string) {
+	fmt.Printf("[+] Running `%s` to recreate the %s services with %s...\n", dockerCmd, strings.Join(services, ", "), yaml)
+	args := append([]string{"-f", yaml, "up", "-d", "--no-deps"}, services...)
+	upErr := RunCmd(dockerCmd, args)
+	if upErr != nil {
+		log.Fatalf("Error trying to recreate the services with %s: %v\n", yaml, upErr)
+	}
+}
+
 // RunManagementCmd executes the "docker compose" commands to execute the provided management command ("mgmt" parameter)
 // with the specified YAML file ("yaml" parameter).
 func RunManagementCmd(yaml string, mgmt string) {
@@ -265,81 +270,53 @@ func RunManagementCmd(yaml string, mgmt string) {
 	}
 }
 
-// FetchLogs fetches logs from the container with the specified "name" label ("containerName" parameter).
-func FetchLogs(containerName string, lines string) []string {
-	var logs []string
-	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Fatalf("Failed to get client in logs: %v", err)
-	}
-	containers, err := cli.ContainerList(context.Background(), client.ContainerListOptions{})
-	if err != nil {
-		log.Fatalf("Failed to get container list: %v", err)
-	}
-	if len(containers.Items) > 0 {
-		for _, container := range containers.Items {
-			if container.Labels["name"] == containerName || containerName == "all" || container.Labels["name"] == "ghostwriter_"+containerName {
-				logs = append(logs, fmt.Sprintf("\n*** Logs for `%s` ***\n\n", container.Labels["name"]))
-				reader, err := cli.ContainerLogs(context.Background(), container.ID, client.ContainerLogsOptions{
-					ShowStdout: true,
-					ShowStderr: true,
-					Tail:       lines,
-				})
-				if err != nil {
-					log.Fatalf("Failed to get container logs: %v", err)
-				}
-				defer reader.Close()
-				// Reference: https://medium.com/@dhanushgopinath/reading-docker-container-logs-with-golang-docker-engine-api-702233fac044
-				p := make([]byte, 8)
-				_, err = reader.Read(p)
-				for err == nil {
-					content := make([]byte, binary.BigEndian.Uint32(p[4:]))
-					reader.Read(content)
-					logs = append(logs, string(content))
-					_, err = reader.Read(p)
-				}
-			}
-		}
-
-		if len(logs) == 0 {
-			logs = append(logs, fmt.Sprintf("\n*** No logs found for requested container '%s' ***\n", containerName))
-		}
-	} else {
-		fmt.Println("Failed to find that container")
+// ReloadNginx tells Nginx to reload its configuration and certificates without restarting the container.
+func ReloadNginx(yaml string) error {
+	if !isServiceRunning("ghostwriter_nginx") {
+		fmt.Println("[*] Nginx is not running, so the new certificate will be used when it starts")
+		return nil
 	}
-	return logs
+	fmt.Println("[+] Reloading Nginx to use the new certificate...")
+	return RunCmd(dockerCmd, []string{"-f", yaml, "exec", "nginx", "nginx", "-s", "reload"})
 }
 
-// GetRunning determines if the container with the specified "name" label ("containerName" parameter) is running.
-func GetRunning() Containers {
+// GetRunning returns the running containers of the active instance. An error is returned if Docker can't be
+// reached, so callers that poll Docker (e.g., "running --watch") can keep going.
+func GetRunning() (Containers, error) {
 	var running Containers
 
 	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		log.Fatalf("Failed to get client connection to Docker: %v", err)
+		return running, fmt.Errorf("failed to get client connection to Docker: %w", err)
 	}
+	defer cli.Close()
 	containers, err := cli.ContainerList(context.Background(), client.ContainerListOptions{
 		All: false,
 	})
 	if err != nil {
-		log.Fatalf("Failed to get container list from Docker: %v", err)
+		return running, fmt.Errorf("failed to get container list from Docker: %w", err)
 	}
+	project := activeComposeProject()
 	if len(containers.Items) > 0 {
 		for _, container := range containers.Items {
-			if Contains(devImages, container.Image) || Contains(prodImages, container.Image) {
+			if project.owns(container.Labels) {
 				running = append(running, Container{
-					container.ID, container.Image, container.Status, container.Ports, container.Labels["name"],
+					container.ID, container.Image, container.Status, container.Ports, labelContainerName(container.Labels),
+					container.Labels[composeProjectLabel],
 				})
 			}
 		}
 	}
 
-	return running
+	return running, nil
 }
 
 // Determine if the container with the specified "name" label ("containerName" parameter) is running.
 func isServiceRunning(containerName string) bool {
-	containers := GetRunning()
+	containers, err := GetRunning()
+	if err != nil {
+		log.Fatalf("Failed to check the running containers: %v", err)
+	}
 	for _, container := range containers {
 		if container.Name == strings.ToLower(containerName) {
 			return true
@@ -352,7 +329,10 @@ func isServiceRunning(containerName string) bool {
 // the "Application startup complete" log message.
 func isDjangoStarted() bool {
 	expectedString := "Application startup complete"
-	logs := FetchLogs("ghostwriter_django", "500")
+	logs, err := FetchLogs("ghostwriter_django", "500")
+	if err != nil {
+		log.Fatalf("Failed to get container logs: %v", err)
+	}
 	for _, entry := range logs {
 		result := strings.Contains(entry, expectedString)
 		if result {
@@ -365,7 +345,10 @@ func isDjangoStarted() bool {
 // Check if PostgreSQL is having trouble starting due to a password mismatch.
 func isPostgresStarted() bool {
 	expectedString := "Password does not match for user"
-	logs := FetchLogs("ghostwriter_postgres", "100")
+	logs, err := FetchLogs("ghostwriter_postgres", "100")
+	if err != nil {
+		log.Fatalf("Failed to get container logs: %v", err)
+	}
 	for _, entry := range logs {
 		result := strings.Contains(entry, expectedString)
 		if result {
@@ -408,7 +391,8 @@ func waitForDjango() bool {
 // RunGhostwriterTests runs Ghostwriter's unit and integration tests via "docker compose".
 // The tests are run in the development environment and assume certain values
 // will be set for test conditions, so the .env file is temporarily adjusted
-// during the test run.
+// during the test run. The settings module is derived from the mode, so it is
+// only changed for this invocation.
 func RunGhostwriterTests() {
 	// Save the current env values we're about to change
 	currentActionSecret := ghostEnv.Get("HASURA_GRAPHQL_ACTION_SECRET")
@@ -431,47 +415,63 @@ func RunGhostwriterTests() {
 	WriteGhostwriterEnvironmentVariables()
 }
 
-// CheckDockerHealth determines if all containers are running and passing their respective health checks.
+// CheckDockerHealth inspects the Ghostwriter containers and returns the issues found by "ContainerIssues()".
 func CheckDockerHealth(dev bool) (HealthIssues, error) {
-	var found []string
-	var imageName string
-	var issues HealthIssues
-
-	requiredImages := prodImages
-	if dev {
-		requiredImages = devImages
+	healths, err := InspectContainers()
+	if err != nil {
+		return nil, err
 	}
+	return ContainerIssues(healths, dev), nil
+}
 
-	// Check running containers to make sure every necessary container is up
-	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+// ContainerIssues returns issues for missing containers, containers that are not services of the environment's
+// Compose file, containers that are not running or crash-looping, containers killed for running out of memory,
+// and failing health checks. The expected services are read from the Compose file of the environment ("dev"
+// parameter) with "LoadComposeProject()".
+func ContainerIssues(healths ContainerHealths, dev bool) HealthIssues {
+	project, err := LoadComposeProject(dev)
+	// The environment is only certain when it matches the recorded mode
+	mode := GetMode()
+	certain := mode != "" && (mode != ModeProduction) == dev
+	issues := containerIssues(healths, project, certain)
 	if err != nil {
-		return issues, err
+		issues = append(issues, HealthIssue{Type: "Compose", Service: "ALL", Message: fmt.Sprintf("Could not read the Compose configuration, so the default services were expected: %v", err)})
 	}
+	return issues
+}
 
-	containers, err := cli.ContainerList(context.Background(), client.ContainerListOptions{
-		All: false,
-	})
-	if err != nil {
-		return issues, err
+// Compare the containers ("healths" parameter) with the services of the Compose project ("project" parameter).
+// Unexpected containers are only reported when the services were read from a Compose file. Removing an unexpected
+// container is only suggested when it belongs to the project and the environment is certain ("certain" parameter)
+// because both Compose files use the same project name.
+func containerIssues(healths ContainerHealths, project ComposeProject, certain bool) HealthIssues {
+	var found []string
+	var issues HealthIssues
+
+	if len(healths) == 0 {
+		issues = append(issues, HealthIssue{Type: "Container", Service: "ALL", Message: "No Ghostwriter containers are running"})
+		return issues
 	}
 
-	if len(containers.Items) > 0 {
-		for _, container := range containers.Items {
-			if Contains(devImages, container.Image) || Contains(prodImages, container.Image) {
-				found = append(found, container.Image)
+	expected := project.ServiceNames()
+	for _, health := range healths {
+		found = append(found, health.ComposeService)
+		if project.File != "" && health.ComposeService != "" && !Contains(expected, health.ComposeService) {
+			message := fmt.Sprintf("Container is not a service in %s", project.File)
+			if certain && health.ComposeProject == project.Name {
+				message += fmt.Sprintf(" (remove it with `%s rm -f %s`)", dockerCmd, health.Name)
 			}
+			issues = append(issues, HealthIssue{Type: "Container", Service: health.Service, Message: message})
 		}
-		for _, image := range requiredImages {
-			if !Contains(found, image) {
-				imageName = strings.ToUpper(image[strings.LastIndex(image, "_")+1:])
-				issues = append(issues, HealthIssue{"Container", imageName, "Container is not running"})
-			}
+		issues = append(issues, health.Issues()...)
+	}
+	for _, service := range expected {
+		if !Contains(found, service) {
+			issues = append(issues, HealthIssue{Type: "Container", Service: strings.ToUpper(service), Message: "Container is not running"})
 		}
-	} else {
-		issues = append(issues, HealthIssue{"Container", "ALL", "No Ghostwriter containers are running"})
 	}
 
-	return issues, nil
+	return issues
 }
 
 // RunDockerComposeBackup executes the "docker compose" command to back up the PostgreSQL database in the environment
@@ -507,15 +507,9 @@ func RunDockerComposeRestore(yaml string, restore string) {
 // RunDockerComposeMediaBackup executes the "docker compose" command to back up the media files in the environment
 // from the specified YAML file ("yaml" parameter).
 func RunDockerComposeMediaBackup(yaml string) {
-	// Determine the volume names based on the environment
-	var dataVolume, backupVolume string
-	if yaml == "local.yml" {
-		dataVolume = "ghostwriter_local_data"
-		backupVolume = "ghostwriter_local_postgres_data_backups"
-	} else {
-		dataVolume = "ghostwriter_production_data"
-		backupVolume = "ghostwriter_production_postgres_data_backups"
-	}
+	// Determine the volume names based on the environment and instance
+	dataVolume := VolumeName(yaml, "data")
+	backupVolume := VolumeName(yaml, "postgres_data_backups")
 
 	// Generate timestamp for backup filename
 	timestamp := time.Now().Format("2006_01_02T15_04_05")
@@ -542,15 +536,9 @@ func RunDockerComposeMediaBackup(yaml string) {
 // RunDockerComposeMediaRestore executes the "docker compose" command to restore media files backup in the
 // environment from the specified YAML file ("yaml" parameter).
 func RunDockerComposeMediaRestore(yaml string, restore string) {
-	// Determine the volume names based on the environment
-	var dataVolume, backupVolume string
-	if yaml == "local.yml" {
-		dataVolume = "ghostwriter_local_data"
-		backupVolume = "ghostwriter_local_postgres_data_backups"
-	} else {
-		dataVolume = "ghostwriter_production_data"
-		backupVolume = "ghostwriter_production_postgres_data_backups"
-	}
+	// Determine the volume names based on the environment and instance
+	dataVolume := VolumeName(yaml, "data")
+	backupVolume := VolumeName(yaml, "postgres_data_backups")
 
 	fmt.Printf("[+] Running `%s` to restore media files from backup %s with %s...\n", dockerCmd, restore, yaml)
 
@@ -587,7 +575,7 @@ func RunDockerComposeMediaRestore(yaml string, restore string) {
 func PostgresVersionInstalled(
 	yaml string,
 ) int {
-	out, err := RunBasicCmd("docker", []string{"compose", "-f", yaml, "run", "--rm", "postgres", "psql", "--version"})
+	out, err := RunBasicCmd("docker", ComposeArgs("-f", yaml, "run", "--rm", "postgres", "psql", "--version"))
 	if err != nil {
 		log.Fatalf("Error trying to get postgresql server version: %v\n", err)
 	}
@@ -608,7 +596,7 @@ func PostgresVersionInstalled(
 func PostgresVersionForData(
 	yaml string,
 ) int {
-	out, err := RunBasicCmd("docker", []string{"compose", "-f", yaml, "run", "--rm", "postgres", "cat", "/var/lib/postgresql/data/PG_VERSION"})
+	out, err := RunBasicCmd("docker", ComposeArgs("-f", yaml, "run", "--rm", "postgres", "cat", "/var/lib/postgresql/data/PG_VERSION"))
 	if err != nil {
 		log.Fatalf("Error trying to get postgresql data version: %v\n", err)
 	}
@@ -8,7 +8,6 @@ import (
 	"github.com/spf13/viper"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 )
@@ -111,6 +110,7 @@ func setGhostwriterConfigDefaultValues() {
 	ghostEnv.SetDefault("hasura_graphql_server_port", 8080)
 
 	// Docker & Django health check configuration
+	ghostEnv.SetDefault("healthcheck_cert_expiry_days", 30)
 	ghostEnv.SetDefault("healthcheck_disk_usage_max", 90)
 	ghostEnv.SetDefault("healthcheck_interval", "300s")
 	ghostEnv.SetDefault("healthcheck_mem_min", 100)
@@ -125,15 +125,19 @@ func setGhostwriterConfigDefaultValues() {
 }
 
 // WriteGhostwriterEnvironmentVariables writes the environment variables to the ".env" file.
+// Values derived from the environment mode are left out (see "ModeEnvironment()").
 func WriteGhostwriterEnvironmentVariables() {
 	c := ghostEnv.AllSettings()
 	// To make it easier to read and look at, get all the keys, sort them, and display variables in order
 	keys := make([]string, 0, len(c))
 	for k := range c {
+		if isDerivedKey(k) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-	f, err := os.Create(filepath.Join(GetCwdFromExe(), ".env"))
+	f, err := os.Create(activeInstance.EnvFile)
 	if err != nil {
 		log.Fatalf("Error writing out environment!\n%v", err)
 	}
@@ -155,15 +159,17 @@ func WriteGhostwriterEnvironmentVariables() {
 // If an .env file is found, load it into the Viper configuration.
 // If an .env file is not found, create a new one with default values.
 // Then write the final file with "WriteGhostwriterEnvironmentVariables()".
+// The file belongs to the active instance (see "SetActiveInstance()").
 func ParseGhostwriterEnvironmentVariables() {
+	// Start from a clean slate in case another instance's values were loaded
+	ghostEnv = viper.New()
 	setGhostwriterConfigDefaultValues()
-	ghostEnv.SetConfigName(".env")
+	ghostEnv.SetConfigFile(activeInstance.EnvFile)
 	ghostEnv.SetConfigType("env")
-	ghostEnv.AddConfigPath(GetCwdFromExe())
 	ghostEnv.AutomaticEnv()
 	// Check if expected env file exists
-	if !FileExists(filepath.Join(GetCwdFromExe(), ".env")) {
-		_, err := os.Create(filepath.Join(GetCwdFromExe(), ".env"))
+	if !FileExists(activeInstance.EnvFile) {
+		_, err := os.Create(activeInstance.EnvFile)
 		if err != nil {
 			log.Fatalf("The .env doesn't exist and couldn't be created")
 		}
@@ -176,32 +182,141 @@ func ParseGhostwriterEnvironmentVariables() {
 			log.Fatalf("Error while parsing .env file: %s", err)
 		}
 	}
+	inferMode()
+	applyModeValues()
 	WriteGhostwriterEnvironmentVariables()
 }
 
-// SetProductionMode updates the environment variables to switch to production mode.
-func SetProductionMode() {
-	ghostEnv.Set("hasura_graphql_dev_mode", false)
-	ghostEnv.Set("django_secure_ssl_redirect", true)
-	ghostEnv.Set("django_settings_module", "config.settings.production")
-	ghostEnv.Set("django_csrf_cookie_secure", true)
-	ghostEnv.Set("django_session_cookie_secure", true)
+// Modes recorded in the "ghostwriter_mode" value
+const (
+	ModeProduction  = "production"
+	ModeDevelopment = "development"
+)
+
+// Key that records the environment mode in the ".env" file
+const modeKey = "ghostwriter_mode"
+
+// Values derived from the environment mode for every invocation. These are never written to the ".env" file,
+// so running a single command for the other environment can't silently change security settings.
+var modeValues = map[string]map[string]interface{}{
+	ModeProduction: {
+		"hasura_graphql_dev_mode":      false,
+		"django_secure_ssl_redirect":   true,
+		"django_settings_module":       "config.settings.production",
+		"django_csrf_cookie_secure":    true,
+		"django_session_cookie_secure": true,
+	},
+	ModeDevelopment: {
+		"hasura_graphql_dev_mode":      true,
+		"django_secure_ssl_redirect":   false,
+		"django_settings_module":       "config.settings.local",
+		"django_csrf_cookie_secure":    false,
+		"django_session_cookie_secure": false,
+	},
+}
+
+// Determine if the environment variable ("key" parameter) is derived from the environment mode.
+func isDerivedKey(key string) bool {
+	_, ok := modeValues[ModeProduction][strings.ToLower(key)]
+	return ok
+}
+
+// Determine if the environment variable ("key" parameter) records or is derived from the environment mode.
+func isModeKey(key string) bool {
+	return strings.ToLower(key) == modeKey || isDerivedKey(key)
+}
+
+// Infer the mode of installations that predate the "ghostwriter_mode" value from the last persisted
+// "django_settings_module" value. New installations have no mode until the first command records it.
+func inferMode() {
+	if ghostEnv.InConfig(modeKey) || !ghostEnv.InConfig("django_settings_module") {
+		return
+	}
+	if strings.HasSuffix(ghostEnv.GetString("django_settings_module"), ".production") {
+		ghostEnv.Set(modeKey, ModeProduction)
+	} else {
+		ghostEnv.Set(modeKey, ModeDevelopment)
+	}
+}
+
+// Set the values derived from the recorded mode for this invocation.
+func applyModeValues() {
+	values, ok := modeValues[GetMode()]
+	if !ok {
+		values = modeValues[ModeDevelopment]
+	}
+	for key, val := range values {
+		ghostEnv.Set(key, val)
+	}
+}
+
+// GetMode returns the environment mode recorded in the configuration. The mode is empty until a
+// command records it.
+func GetMode() string {
+	return ghostEnv.GetString(modeKey)
+}
+
+// ModeEnvironment returns the values derived from the recorded mode as "KEY=value" strings. These are passed
+// to "docker compose" for every invocation because they are not stored in the ".env" file.
+func ModeEnvironment() []string {
+	var variables []string
+	for key := range modeValues[ModeProduction] {
+		variables = append(variables, fmt.Sprintf("%s=%s", strings.ToUpper(key), ghostEnv.GetString(key)))
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// ModeComposeFile returns the Docker Compose file for the recorded mode.
+func ModeComposeFile() string {
+	if GetMode() == ModeProduction {
+		return "production.yml"
+	}
+	return "local.yml"
+}
+
+// Record the "mode" in the ".env" file and derive its values.
+func setMode(mode string) {
+	before := snapshotConfig(modeKey)
+	ghostEnv.Set(modeKey, mode)
+	applyModeValues()
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange(mode, before)
 }
 
-// SetDevMode updates the environment variables to switch to development mode.
+// SetProductionMode records production mode in the configuration.
+func SetProductionMode() {
+	setMode(ModeProduction)
+}
+
+// SetDevMode records development mode in the configuration.
 func SetDevMode() {
-	ghostEnv.Set("hasura_graphql_dev_mode", true)
-	ghostEnv.Set("django_secure_ssl_redirect", false)
-	ghostEnv.Set("django_settings_module", "config.settings.local")
-	ghostEnv.Set("django_csrf_cookie_secure", false)
-	ghostEnv.Set("django_session_cookie_secure", false)
-	WriteGhostwriterEnvironmentVariables()
+	setMode(ModeDevelopment)
+}
+
+// SelectMode checks the mode targeted by a command ("dev" parameter) against the mode recorded in the
+// configuration. The first command records its mode. Switching to a different mode changes security
+// settings, so it is refused unless "switchMode" is true. Returns true if the recorded mode changed.
+func SelectMode(dev bool, switchMode bool) (bool, error) {
+	requested := ModeProduction
+	if dev {
+		requested = ModeDevelopment
+	}
+	current := GetMode()
+	if current == requested {
+		return false, nil
+	}
+	if current != "" && !switchMode {
+		return false, fmt.Errorf("this installation is configured for %s mode, but the command targets %s mode (use `--switch-mode` to switch modes)", current, requested)
+	}
+	setMode(requested)
+	return true, nil
 }
 
 // Convert the environment variable ("env") to a slice of strings.
+// Extra whitespace is ignored, so an empty value returns an empty slice.
 func splitVariable(env string) []string {
-	return strings.Split(ghostEnv.GetString(env), " ")
+	return strings.Fields(ghostEnv.GetString(env))
 }
 
 // Remove one or more matches for "item" from a "slice" of strings.
@@ -275,38 +390,108 @@ func GetConfig(args []string) Configurations {
 	return values
 }
 
-// SetConfig sets the value of the specified key in the .env file.
-func SetConfig(key string, value string) {
-	if strings.ToLower(value) == "true" {
+// Set the value of the specified key without writing the .env file.
+// Boolean strings are stored as booleans.
+func setConfigValue(key string, value string) {
+	if strings.ToLower(key) == modeKey {
+		ghostEnv.Set(modeKey, value)
+		applyModeValues()
+	} else if strings.ToLower(value) == "true" {
 		ghostEnv.Set(key, true)
 	} else if strings.ToLower(value) == "false" {
 		ghostEnv.Set(key, false)
 	} else {
 		ghostEnv.Set(key, value)
 	}
+}
+
+// SetConfig sets the value of the specified key in the .env file. Values derived from the environment
+// mode can't be set, and the mode itself must be "production" or "development".
+func SetConfig(key string, value string) error {
+	if isDerivedKey(key) {
+		return fmt.Errorf("`%s` is derived from the environment mode and can't be set (use the `--dev` and `--switch-mode` flags)", strings.ToLower(key))
+	}
+	if strings.ToLower(key) == modeKey && value != ModeProduction && value != ModeDevelopment {
+		return fmt.Errorf("`%s` must be `%s` or `%s`", modeKey, ModeProduction, ModeDevelopment)
+	}
+	before := snapshotConfig(key)
+	setConfigValue(key, value)
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("set", before)
+	return nil
 }
 
-// AllowHost appends a host to the allowed hosts list in the .env file.
-func AllowHost(host string) {
+// Print any warnings returned by the host and origin validation functions.
+func printWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Printf("[!] %s\n", warning)
+	}
+}
+
+// AllowHost validates a host and appends it to the allowed hosts list in the .env file.
+func AllowHost(host string) error {
+	host, warnings, err := ValidateHost(host)
+	printWarnings(warnings)
+	if err != nil {
+		return err
+	}
+	before := snapshotConfig("django_allowed_hosts")
 	appendHost("django_allowed_hosts", host)
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("allowhost", before)
+	return nil
 }
 
-// DisallowHost removes a host to the allowed hosts list in the .env file.
-func DisallowHost(host string) {
-	removeHost("django_allowed_hosts", host)
+// DisallowHost removes a host to the allowed hosts list in the .env file. Trusted origins
+// for the same host are removed, too, to keep the lists consistent.
+func DisallowHost(host string) error {
+	normalized, _, err := ValidateHost(host)
+	if err != nil {
+		// Still allow removing invalid values that were added before validation existed
+		normalized = host
+	}
+	before := snapshotConfig("django_allowed_hosts", "django_csrf_trusted_origins")
+	removeHost("django_allowed_hosts", normalized)
+	for _, origin := range splitVariable("django_csrf_trusted_origins") {
+		if originToHost(origin) == normalized {
+			removeHost("django_csrf_trusted_origins", origin)
+			fmt.Printf("[+] Also removed %s from the trusted origins\n", origin)
+		}
+	}
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("disallowhost", before)
+	return nil
 }
 
-// TrustOrigin appends an origin to the trusted origins list in the .env file.
-func TrustOrigin(host string) {
-	appendHost("django_csrf_trusted_origins", host)
+// TrustOrigin validates an origin and appends it to the trusted origins list in the .env file. The origin's host
+// is added to the allowed hosts list if it is not already allowed to keep the lists consistent.
+func TrustOrigin(origin string) error {
+	origin, warnings, err := ValidateOrigin(origin)
+	printWarnings(warnings)
+	if err != nil {
+		return err
+	}
+	before := snapshotConfig("django_allowed_hosts", "django_csrf_trusted_origins")
+	appendHost("django_csrf_trusted_origins", origin)
+	host := originToHost(origin)
+	if !isHostAllowed(host, splitVariable("django_allowed_hosts")) {
+		appendHost("django_allowed_hosts", host)
+		fmt.Printf("[+] Also added %s to the allowed hosts\n", host)
+	}
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("trustorigin", before)
+	return nil
 }
 
 // DistrustOrigin removes an origin to the trusted origins list in the .env file.
-func DistrustOrigin(host string) {
-	removeHost("django_csrf_trusted_origins", host)
+func DistrustOrigin(origin string) error {
+	before := snapshotConfig("django_csrf_trusted_origins")
+	// Remove the value as provided in case it was added before validation existed
+	removeHost("django_csrf_trusted_origins", origin)
+	if normalized, _, err := ValidateOrigin(origin); err == nil {
+		removeHost("django_csrf_trusted_origins", normalized)
+	}
 	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("distrustorigin", before)
+	return nil
 }
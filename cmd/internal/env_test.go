@@ -2,6 +2,7 @@ package internal
 
 import (
 	"github.com/stretchr/testify/assert"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -42,7 +43,7 @@ func TestGhostwriterEnvironmentVariables(t *testing.T) {
 
 	// Test ``GetConfigAll()``
 	config := GetConfigAll()
-	assert.Equal(t, len(config), 64, "`GetConfigAll()` should return all values")
+	assert.Equal(t, len(config), 66, "`GetConfigAll()` should return all values")
 
 	// Test ``SetConfig()``
 	SetConfig("django_date_format", "Y M d")
@@ -78,3 +79,34 @@ func TestGhostwriterEnvironmentVariables(t *testing.T) {
 	DistrustOrigin("ghostwriter.local")
 	assert.False(t, strings.Contains(ghostEnv.GetString("django_csrf_trusted_origins"), "ghostwriter.local"), "Value of `django_csrf_trusted_origins` should include `ghostwriter.local`")
 }
+
+func TestEnvironmentMode(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+
+	// The first command records its mode
+	SetConfig("ghostwriter_mode", ModeProduction)
+	switched, err := SelectMode(false, false)
+	assert.NoError(t, err, "Expected `SelectMode()` to return no error for the recorded mode")
+	assert.False(t, switched, "Expected the mode to stay the same")
+
+	// Switching modes requires the switch flag
+	_, err = SelectMode(true, false)
+	assert.Error(t, err, "Expected an error when switching modes without `switchMode`")
+	assert.Equal(t, ModeProduction, GetMode())
+	assert.Equal(t, true, ghostEnv.GetBool("django_secure_ssl_redirect"), "Production value of `django_secure_ssl_redirect` should be true")
+	switched, err = SelectMode(true, true)
+	assert.NoError(t, err, "Expected `SelectMode()` to return no error with `switchMode`")
+	assert.True(t, switched, "Expected the mode to change")
+	assert.Equal(t, ModeDevelopment, GetMode())
+	assert.Contains(t, ModeEnvironment(), "DJANGO_SETTINGS_MODULE=config.settings.local")
+	assert.Equal(t, "local.yml", ModeComposeFile())
+
+	// Derived values are never written to the .env file and can't be set
+	content, err := os.ReadFile(filepath.Join(GetCwdFromExe(), ".env"))
+	assert.NoError(t, err, "Expected the .env file to be readable")
+	assert.Contains(t, string(content), "GHOSTWRITER_MODE='development'")
+	assert.NotContains(t, string(content), "DJANGO_SETTINGS_MODULE")
+	assert.Error(t, SetConfig("django_secure_ssl_redirect", "false"), "Expected an error when setting a derived value")
+	assert.Error(t, SetConfig("ghostwriter_mode", "staging"), "Expected an error for an unknown mode")
+}
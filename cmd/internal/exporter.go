@@ -0,0 +1,458 @@
+package internal
+
+// Functions for exposing Ghostwriter's health as Prometheus metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GhostManager/Ghostwriter_CLI/cmd/config"
+	"github.com/moby/moby/client"
+)
+
+// Prefix of every metric name
+const metricPrefix = "ghostwriter_"
+
+// Metric is a custom type for storing a Prometheus metric family and its samples.
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []MetricSample
+}
+
+// MetricSample is a custom type for storing one value of a metric and its labels.
+type MetricSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Add a sample with labels given as name and value pairs.
+func (m *Metric) add(value float64, labels ...string) {
+	sample := MetricSample{Value: value, Labels: make(map[string]string)}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.Labels[labels[i]] = labels[i+1]
+	}
+	m.Samples = append(m.Samples, sample)
+}
+
+// Create a gauge metric.
+func newGauge(name string, help string) *Metric {
+	return &Metric{Name: metricPrefix + name, Help: help, Type: "gauge"}
+}
+
+// Escape a label value for the Prometheus text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+// Format a sample value for the Prometheus text format.
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// WriteMetrics writes the metrics in the Prometheus text exposition format. Labels are sorted by name.
+func WriteMetrics(w io.Writer, metrics []Metric) error {
+	for _, metric := range metrics {
+		help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(metric.Help)
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.Name, help, metric.Name, metric.Type); err != nil {
+			return err
+		}
+		for _, sample := range metric.Samples {
+			names := make([]string, 0, len(sample.Labels))
+			for name := range sample.Labels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var labels []string
+			for _, name := range names {
+				labels = append(labels, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(sample.Labels[name])))
+			}
+			line := metric.Name
+			if len(labels) > 0 {
+				line += "{" + strings.Join(labels, ",") + "}"
+			}
+			if _, err := fmt.Fprintf(w, "%s %s\n", line, formatMetricValue(sample.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Convert a boolean to a metric value.
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// Build the metric with the CLI's version information.
+func buildInfoMetric() Metric {
+	info := newGauge("cli_build_info", "Version information of the Ghostwriter CLI running the exporter")
+	info.add(1, "version", config.Version, "build_date", config.BuildDate)
+	return *info
+}
+
+// Build the metrics for the state of the containers ("healths" parameter).
+func containerMetrics(healths ContainerHealths) []Metric {
+	states := []string{"created", "running", "paused", "restarting", "removing", "exited", "dead"}
+	state := newGauge("container_state", "Current state of the container (1 for the current state)")
+	healthy := newGauge("container_healthy", "Whether Docker's health check reports the container as healthy")
+	restarts := newGauge("container_restarts", "Number of times Docker restarted the container")
+	uptime := newGauge("container_uptime_seconds", "Seconds since the container started")
+	failing := newGauge("container_health_failing_streak", "Number of consecutive failed health checks")
+	oom := newGauge("container_oom_killed", "Whether the container was killed because it ran out of memory")
+	for _, health := range healths {
+		for _, name := range states {
+			state.add(boolValue(health.State == name), "container", health.Name, "service", health.Service, "state", name)
+		}
+		healthy.add(boolValue(health.Health == "healthy"), "container", health.Name, "service", health.Service, "health", health.Health)
+		restarts.add(float64(health.RestartCount), "container", health.Name, "service", health.Service)
+		uptime.add(health.Uptime.Seconds(), "container", health.Name, "service", health.Service)
+		failing.add(float64(health.FailingStreak), "container", health.Name, "service", health.Service)
+		oom.add(boolValue(health.OOMKilled), "container", health.Name, "service", health.Service)
+	}
+	return []Metric{*state, *healthy, *restarts, *uptime, *failing, *oom}
+}
+
+// Build the metric for the number of healthcheck issues by type and service.
+func healthIssueMetric(issues HealthIssues) Metric {
+	metric := newGauge("health_issues", "Number of issues found by the healthcheck by type and service")
+	counts := make(map[[2]string]int)
+	var keys [][2]string
+	for _, issue := range issues {
+		key := [2]string{issue.Type, issue.Service}
+		if _, ok := counts[key]; !ok {
+			keys = append(keys, key)
+		}
+		counts[key]++
+	}
+	for _, key := range keys {
+		metric.add(float64(counts[key]), "type", key[0], "service", key[1])
+	}
+	return *metric
+}
+
+// Build the metrics for the service probes.
+func probeMetrics(probes ProbeResults) []Metric {
+	up := newGauge("probe_success", "Whether the probe of the service succeeded")
+	latency := newGauge("probe_latency_seconds", "Latency of the probe of the service")
+	for _, probe := range probes {
+		up.add(boolValue(probe.OK), "service", probe.Service, "probe", probe.Probe)
+		latency.add(probe.Latency.Seconds(), "service", probe.Service, "probe", probe.Probe)
+	}
+	return []Metric{*up, *latency}
+}
+
+// BackupFile is a custom type for storing a file in the PostgreSQL container's backups volume.
+type BackupFile struct {
+	Name     string
+	Kind     string
+	Size     int64
+	Modified time.Time
+}
+
+// Parse the output of "stat -c '%Y %s %n'" for the files in the backups volume. Media backups are
+// named "media_backup_*" and everything else is a database backup.
+func parseBackupListing(output string) []BackupFile {
+	var files []BackupFile
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		modified, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		file := BackupFile{Name: path.Base(fields[2]), Kind: "database", Size: size, Modified: time.Unix(modified, 0)}
+		if strings.HasPrefix(file.Name, "media_backup_") {
+			file.Kind = "media"
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Build the metrics for the latest backup of each kind at the time "now".
+func backupMetrics(files []BackupFile, now time.Time) []Metric {
+	count := newGauge("backup_files", "Number of backup files in the backups volume")
+	timestamp := newGauge("backup_last_timestamp_seconds", "Modification time of the latest backup as a Unix timestamp")
+	age := newGauge("backup_age_seconds", "Seconds since the latest backup was made")
+	size := newGauge("backup_last_size_bytes", "Size of the latest backup")
+	for _, kind := range []string{"database", "media"} {
+		var latest *BackupFile
+		var total int
+		for i, file := range files {
+			if file.Kind != kind {
+				continue
+			}
+			total++
+			if latest == nil || file.Modified.After(latest.Modified) {
+				latest = &files[i]
+			}
+		}
+		count.add(float64(total), "kind", kind)
+		if latest != nil {
+			timestamp.add(float64(latest.Modified.Unix()), "kind", kind)
+			age.add(now.Sub(latest.Modified).Seconds(), "kind", kind)
+			size.add(float64(latest.Size), "kind", kind)
+		}
+	}
+	return []Metric{*count, *timestamp, *age, *size}
+}
+
+// Build the metrics for the Nginx certificate.
+func certificateMetrics(info CertificateInfo) []Metric {
+	expiry := newGauge("certificate_expiry_timestamp_seconds", "Expiration time of the Nginx certificate as a Unix timestamp")
+	expiry.add(float64(info.NotAfter.Unix()), "subject", info.Subject)
+	matches := newGauge("certificate_key_matches", "Whether the Nginx certificate matches its private key")
+	matches.add(boolValue(info.KeyMatches), "subject", info.Subject)
+	return []Metric{*expiry, *matches}
+}
+
+// Build the metrics for the sizes of the volumes of the Compose project ("project" parameter).
+func volumeMetrics(usage client.VolumesDiskUsage, project string) []Metric {
+	size := newGauge("volume_size_bytes", "Disk space used by the Docker volume")
+	for _, volume := range usage.Items {
+		if volume.Labels[composeProjectLabel] != project {
+			continue
+		}
+		// Docker reports -1 if the size was not calculated
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		size.add(float64(volume.UsageData.Size), "volume", volume.Name, "compose_volume", volume.Labels["com.docker.compose.volume"])
+	}
+	return []Metric{*size}
+}
+
+// List the files in the backups volume of the running PostgreSQL container.
+func listBackups(cli *client.Client, healths ContainerHealths) ([]BackupFile, error) {
+	postgres, ok := findRunningContainer(healths, "postgres")
+	if !ok {
+		return nil, fmt.Errorf("the PostgreSQL container is not running")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout())
+	defer cancel()
+	output, err := execInContainer(
+		ctx, cli, postgres.ID, nil,
+		"sh", "-c", `for f in /backups/*; do [ -f "$f" ] && stat -c '%Y %s %n' "$f"; done; true`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if output.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to list backups: %s", output.message())
+	}
+	return parseBackupListing(output.Stdout), nil
+}
+
+// Default interval between runs of the service probes
+const defaultProbeInterval = time.Minute
+
+// MetricsExporter is a custom type for collecting metrics when Prometheus scrapes the exporter.
+type MetricsExporter struct {
+	// Minimum time between runs of the service probes, which execute commands in the containers and are too
+	// slow to run on every scrape; the last results are reported in between
+	ProbeInterval time.Duration
+	// Scrapes are serialized, so concurrent requests don't run the probes in parallel
+	mutex sync.Mutex
+	// Function that runs the probes, which defaults to "ProbeServices()"
+	probe     func(ContainerHealths) (ProbeResults, error)
+	probes    ProbeResults
+	probesErr error
+	probedAt  time.Time
+}
+
+// Get the results of the service probes, running the probes again only if the last results are older than the
+// probe interval at the time "now".
+func (e *MetricsExporter) probeResults(healths ContainerHealths, now time.Time) (ProbeResults, time.Time, error) {
+	interval := e.ProbeInterval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	if e.probedAt.IsZero() || now.Sub(e.probedAt) >= interval {
+		probe := e.probe
+		if probe == nil {
+			probe = ProbeServices
+		}
+		e.probes, e.probesErr = probe(healths)
+		e.probedAt = now
+	}
+	return e.probes, e.probedAt, e.probesErr
+}
+
+// Run one collector and record whether it succeeded and how long it took.
+func collect(name string, success *Metric, duration *Metric, collector func() ([]Metric, error)) []Metric {
+	start := time.Now()
+	metrics, err := collector()
+	duration.add(time.Since(start).Seconds(), "collector", name)
+	success.add(boolValue(err == nil), "collector", name)
+	if err != nil {
+		log.Printf("Collector %s failed: %v", name, err)
+	}
+	return metrics
+}
+
+// Collect gathers all metrics. A failed collector is reported with the "exporter_collector_success" metric
+// instead of failing the scrape.
+func (e *MetricsExporter) Collect() []Metric {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	success := newGauge("exporter_collector_success", "Whether the collector succeeded during the scrape")
+	duration := newGauge("exporter_collector_duration_seconds", "Seconds the collector took during the scrape")
+	metrics := []Metric{buildInfoMetric()}
+	// Use the recorded mode, which also selects the containers of the active instance
+	dev := GetMode() != ModeProduction
+
+	var healths ContainerHealths
+	var healthsErr error
+	metrics = append(metrics, collect("containers", success, duration, func() ([]Metric, error) {
+		healths, healthsErr = InspectContainers()
+		if healthsErr != nil {
+			return nil, healthsErr
+		}
+		return containerMetrics(healths), nil
+	})...)
+
+	metrics = append(metrics, collect("health", success, duration, func() ([]Metric, error) {
+		// Without the containers, every service would be reported as missing
+		if healthsErr != nil {
+			return nil, fmt.Errorf("could not inspect the containers: %w", healthsErr)
+		}
+		issues := ContainerIssues(healths, dev)
+		up := newGauge("status_endpoint_up", "Whether Ghostwriter's /status/ endpoint responded")
+		serviceIssues, err := CheckGhostwriterHealth(DefaultStatusCheckOptions(dev))
+		if err != nil {
+			return nil, err
+		}
+		reachable := true
+		for _, issue := range serviceIssues {
+			if issue.Type == "Status" {
+				reachable = false
+			}
+		}
+		up.add(boolValue(reachable))
+		issues = append(issues, serviceIssues...)
+		if !dev {
+			issues = append(issues, CheckCertificateHealth()...)
+		}
+		return []Metric{healthIssueMetric(issues), *up}, nil
+	})...)
+
+	cli, cliErr := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if cliErr == nil {
+		defer cli.Close()
+	}
+	metrics = append(metrics, collect("probes", success, duration, func() ([]Metric, error) {
+		probes, probedAt, err := e.probeResults(healths, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		timestamp := newGauge("probe_last_run_timestamp_seconds", "Time the service probes last ran as a Unix timestamp")
+		timestamp.add(float64(probedAt.Unix()))
+		return append(probeMetrics(probes), *timestamp), nil
+	})...)
+
+	metrics = append(metrics, collect("backups", success, duration, func() ([]Metric, error) {
+		if cliErr != nil {
+			return nil, cliErr
+		}
+		files, err := listBackups(cli, healths)
+		if err != nil {
+			return nil, err
+		}
+		return backupMetrics(files, time.Now()), nil
+	})...)
+
+	if !dev {
+		metrics = append(metrics, collect("certificate", success, duration, func() ([]Metric, error) {
+			info, err := InspectCertificate()
+			if err != nil {
+				return nil, err
+			}
+			return certificateMetrics(info), nil
+		})...)
+	}
+
+	metrics = append(metrics, collect("volumes", success, duration, func() ([]Metric, error) {
+		if cliErr != nil {
+			return nil, cliErr
+		}
+		usage, err := cli.DiskUsage(context.Background(), client.DiskUsageOptions{Volumes: true, Verbose: true})
+		if err != nil {
+			return nil, err
+		}
+		project := activeComposeProject()
+		if project.Name == "" {
+			return nil, fmt.Errorf("could not read the Compose project name to identify the instance's volumes")
+		}
+		return volumeMetrics(usage.Volumes, project.Name), nil
+	})...)
+
+	return append(metrics, *success, *duration)
+}
+
+// ServeHTTP serves the metrics on "/metrics" and a short landing page on "/".
+func (e *MetricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/metrics":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WriteMetrics(w, e.Collect()); err != nil {
+			log.Printf("Failed to write metrics: %v", err)
+		}
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Ghostwriter Exporter</title></head><body><h1>Ghostwriter Exporter</h1><p><a href="/metrics">Metrics</a></p></body></html>`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ServeMetrics runs the exporter's HTTP server on the "listen" address until the context is cancelled. The service
+// probes run at most once every "probeInterval".
+func ServeMetrics(ctx context.Context, listen string, probeInterval time.Duration) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           &MetricsExporter{ProbeInterval: probeInterval},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdown)
+	}
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/volume"
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	metric := newGauge("test_value", "A test metric\nwith two lines")
+	metric.add(1.5, "service", "DJANGO", "message", `say "hi"`)
+	metric.add(0)
+	var output bytes.Buffer
+	assert.NoError(t, WriteMetrics(&output, []Metric{*metric}))
+	expected := `# HELP ghostwriter_test_value A test metric\nwith two lines
+# TYPE ghostwriter_test_value gauge
+ghostwriter_test_value{message="say \"hi\"",service="DJANGO"} 1.5
+ghostwriter_test_value 0
+`
+	assert.Equal(t, expected, output.String())
+}
+
+func TestContainerMetrics(t *testing.T) {
+	healths := ContainerHealths{
+		{Name: "ghostwriter_django", Service: "DJANGO", State: "running", Health: "healthy", Uptime: time.Hour},
+		{Name: "ghostwriter_queue", Service: "QUEUE", State: "exited", Health: "none", RestartCount: 2},
+	}
+	var output bytes.Buffer
+	assert.NoError(t, WriteMetrics(&output, containerMetrics(healths)))
+	assert.Contains(t, output.String(), `ghostwriter_container_state{container="ghostwriter_django",service="DJANGO",state="running"} 1`)
+	assert.Contains(t, output.String(), `ghostwriter_container_state{container="ghostwriter_queue",service="QUEUE",state="running"} 0`)
+	assert.Contains(t, output.String(), `ghostwriter_container_healthy{container="ghostwriter_django",health="healthy",service="DJANGO"} 1`)
+	assert.Contains(t, output.String(), `ghostwriter_container_restarts{container="ghostwriter_queue",service="QUEUE"} 2`)
+	assert.Contains(t, output.String(), `ghostwriter_container_uptime_seconds{container="ghostwriter_django",service="DJANGO"} 3600`)
+
+	issues := healthIssueMetric(HealthIssues{
+		{Type: "Container", Service: "QUEUE", Message: "Container is exited with exit code 1"},
+		{Type: "Container", Service: "QUEUE", Message: "Container was killed because it ran out of memory"},
+		{Type: "Service", Service: "redis", Message: "not working"},
+	})
+	if assert.Len(t, issues.Samples, 2) {
+		assert.Equal(t, float64(2), issues.Samples[0].Value)
+	}
+}
+
+func TestBackupMetrics(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	output := strings.Join([]string{
+		"1759990000 1048576 /backups/backup_2025_10_09T06_46_40.sql.gz",
+		"1759900000 1000000 /backups/backup_2025_10_08T02_53_20.sql.gz",
+		"1759996400 52428800 /backups/media_backup_2025_10_09T08_33_20.tar.gz",
+		"stat: cannot stat '/backups/*': No such file or directory",
+	}, "\n")
+	files := parseBackupListing(output)
+	assert.Len(t, files, 3)
+
+	var written bytes.Buffer
+	assert.NoError(t, WriteMetrics(&written, backupMetrics(files, now)))
+	assert.Contains(t, written.String(), `ghostwriter_backup_files{kind="database"} 2`)
+	assert.Contains(t, written.String(), `ghostwriter_backup_age_seconds{kind="database"} 10000`)
+	assert.Contains(t, written.String(), `ghostwriter_backup_last_size_bytes{kind="database"} 1.048576e+06`)
+	assert.Contains(t, written.String(), `ghostwriter_backup_age_seconds{kind="media"} 3600`)
+
+	// Without backups only the count is reported
+	written.Reset()
+	assert.NoError(t, WriteMetrics(&written, backupMetrics(nil, now)))
+	assert.Contains(t, written.String(), `ghostwriter_backup_files{kind="media"} 0`)
+	assert.NotContains(t, written.String(), `ghostwriter_backup_age_seconds{`)
+}
+
+func TestVolumeMetrics(t *testing.T) {
+	usage := client.VolumesDiskUsage{Items: []volume.Volume{
+		{
+			Name:      "ghostwriter_production_postgres_data",
+			Labels:    map[string]string{"com.docker.compose.project": "ghostwriter", "com.docker.compose.volume": "production_postgres_data"},
+			UsageData: &volume.UsageData{Size: 2048},
+		},
+		{Name: "unrelated", UsageData: &volume.UsageData{Size: 4096}},
+		{
+			Name:      "other_postgres_data",
+			Labels:    map[string]string{"com.docker.compose.project": "other", "com.docker.compose.volume": "postgres_data"},
+			UsageData: &volume.UsageData{Size: 8192},
+		},
+		{
+			Name:      "ghostwriter_production_data",
+			Labels:    map[string]string{"com.docker.compose.project": "ghostwriter", "com.docker.compose.volume": "production_data"},
+			UsageData: &volume.UsageData{Size: -1},
+		},
+	}}
+	metrics := volumeMetrics(usage, "ghostwriter")
+	if assert.Len(t, metrics[0].Samples, 1) {
+		assert.Equal(t, "production_postgres_data", metrics[0].Samples[0].Labels["compose_volume"])
+		assert.Equal(t, float64(2048), metrics[0].Samples[0].Value)
+	}
+}
+
+func TestMetricsExporterProbeInterval(t *testing.T) {
+	runs := 0
+	exporter := &MetricsExporter{ProbeInterval: time.Minute, probe: func(ContainerHealths) (ProbeResults, error) {
+		runs++
+		return ProbeResults{{Service: "REDIS", Probe: "ping", OK: true}}, nil
+	}}
+	start := time.Now()
+	probes, probedAt, err := exporter.probeResults(nil, start)
+	assert.NoError(t, err)
+	assert.Len(t, probes, 1)
+	assert.Equal(t, start, probedAt)
+
+	// Scrapes within the interval report the last results
+	_, probedAt, _ = exporter.probeResults(nil, start.Add(30*time.Second))
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, start, probedAt)
+
+	_, probedAt, _ = exporter.probeResults(nil, start.Add(time.Minute))
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, start.Add(time.Minute), probedAt)
+}
+
+func TestMetricsExporterRoutes(t *testing.T) {
+	exporter := &MetricsExporter{}
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, recorder.Body.String(), `href="/metrics"`)
+
+	recorder = httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteMetrics(&output, []Metric{buildInfoMetric()}))
+	assert.Contains(t, output.String(), `ghostwriter_cli_build_info{build_date="",version="v0.1.0"} 1`)
+}
+
+func TestMetricsExporterDockerUnavailable(t *testing.T) {
+	defer quietTests()()
+	// Point the client at a Docker daemon that isn't running
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	exporter := &MetricsExporter{probe: func(ContainerHealths) (ProbeResults, error) { return nil, nil }}
+	metrics := exporter.Collect()
+
+	for _, metric := range metrics {
+		switch metric.Name {
+		case "ghostwriter_health_issues":
+			t.Errorf("Expected no healthcheck issues without the containers, got %v", metric.Samples)
+		case "ghostwriter_exporter_collector_success":
+			for _, sample := range metric.Samples {
+				if sample.Labels["collector"] == "containers" || sample.Labels["collector"] == "health" {
+					assert.Equal(t, float64(0), sample.Value, "Expected the %s collector to fail", sample.Labels["collector"])
+				}
+			}
+		}
+	}
+}
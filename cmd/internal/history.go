@@ -0,0 +1,214 @@
+package internal
+
+// Functions for keeping an append-only journal of configuration changes and reverting them
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prefix for hashed secret values in the journal
+const hashedPrefix = "sha256:"
+
+// KeyChange is a custom type for storing the old and new values of a single configuration value.
+// Secret values are stored as salted SHA-256 hashes.
+type KeyChange struct {
+	Key    string `json:"key"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+	Hashed bool   `json:"hashed,omitempty"`
+}
+
+// HistoryEntry is a custom type for storing one journal entry. Every entry is one mutation of the
+// configuration that may have changed multiple values.
+type HistoryEntry struct {
+	ID      int         `json:"id"`
+	Time    time.Time   `json:"time"`
+	Action  string      `json:"action"`
+	Changes []KeyChange `json:"changes"`
+	Reverts int         `json:"reverts,omitempty"`
+}
+
+// History is a collection of HistoryEntry structs
+type History []HistoryEntry
+
+// Get the path to the journal for the active instance's environment file.
+func historyPath() string {
+	return activeInstance.EnvFile + ".history"
+}
+
+// Hash a secret value with a random salt so the journal never holds the plaintext value.
+func hashSecret(val string) string {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		log.Fatalf("Failed to generate a salt for the configuration history: %v", err)
+	}
+	return hashSecretWithSalt(val, hex.EncodeToString(salt))
+}
+
+// Hash a secret value with the provided hex-encoded "salt".
+func hashSecretWithSalt(val string, salt string) string {
+	sum := sha256.Sum256([]byte(salt + val))
+	return hashedPrefix + salt + ":" + hex.EncodeToString(sum[:])
+}
+
+// Capture the current values of the configuration keys before a mutation.
+func snapshotConfig(keys ...string) map[string]string {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		values[strings.ToLower(key)] = ghostEnv.GetString(key)
+	}
+	return values
+}
+
+// Compare the "before" snapshot with the current configuration and append the differences
+// to the journal as a single entry for the "action". Nothing is recorded if nothing changed.
+func recordConfigChange(action string, before map[string]string) {
+	recordHistoryEntry(action, before, 0)
+}
+
+// Append a journal entry for the "action" and optionally mark it as reverting another entry ("reverts" parameter).
+func recordHistoryEntry(action string, before map[string]string, reverts int) {
+	var changes []KeyChange
+	for key, old := range before {
+		current := ghostEnv.GetString(key)
+		if current == old {
+			continue
+		}
+		change := KeyChange{Key: strings.ToUpper(key), Old: old, New: current}
+		if IsSecretKey(key) {
+			change.Old, change.New, change.Hashed = hashSecret(old), hashSecret(current), true
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	history, err := ReadHistory()
+	if err != nil {
+		log.Fatalf("Failed to read the configuration history: %v", err)
+	}
+	entry := HistoryEntry{ID: 1, Time: time.Now().UTC(), Action: action, Changes: changes, Reverts: reverts}
+	if len(history) > 0 {
+		entry.ID = history[len(history)-1].ID + 1
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Fatalf("Failed to record the configuration change: %v", err)
+	}
+	f, err := os.OpenFile(historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatalf("Failed to open the configuration history: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Fatalf("Failed to record the configuration change: %v", err)
+	}
+}
+
+// ReadHistory returns every entry in the active instance's configuration journal, oldest first.
+func ReadHistory() (History, error) {
+	var history History
+	f, err := os.Open(historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return history, nil
+		}
+		return history, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return history, fmt.Errorf("corrupt history entry: %v", err)
+		}
+		history = append(history, entry)
+	}
+	return history, scanner.Err()
+}
+
+// IsReverted determines if the entry with the given "id" has already been reverted by a later entry.
+func (h History) IsReverted(id int) bool {
+	for _, entry := range h {
+		if entry.Reverts == id {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoConfigChange reverts the journal entry with the given "id". An "id" of 0 reverts the most recent
+// change that has not been reverted yet. Values changed again since the entry was recorded are only
+// overwritten if "force" is true. Secrets can't be restored because the journal only holds their hashes.
+// Returns the entry that was reverted.
+func UndoConfigChange(id int, force bool) (HistoryEntry, error) {
+	var target HistoryEntry
+
+	history, err := ReadHistory()
+	if err != nil {
+		return target, err
+	}
+	if id == 0 {
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Reverts == 0 && !history.IsReverted(history[i].ID) {
+				target = history[i]
+				break
+			}
+		}
+		if target.ID == 0 {
+			return target, fmt.Errorf("there are no changes to undo")
+		}
+	} else {
+		for _, entry := range history {
+			if entry.ID == id {
+				target = entry
+			}
+		}
+		if target.ID == 0 {
+			return target, fmt.Errorf("change %d does not exist", id)
+		}
+		if history.IsReverted(id) {
+			return target, fmt.Errorf("change %d has already been undone", id)
+		}
+	}
+
+	// Check every value before changing anything so an undo is all or nothing
+	var keys []string
+	for _, change := range target.Changes {
+		if change.Hashed {
+			return target, fmt.Errorf("change %d includes the secret %s, which can't be restored from the history", target.ID, change.Key)
+		}
+		current := ghostEnv.GetString(change.Key)
+		if current != change.New && !force {
+			return target, fmt.Errorf("%s changed again after change %d (use --force to overwrite it)", change.Key, target.ID)
+		}
+		keys = append(keys, change.Key)
+	}
+
+	before := snapshotConfig(keys...)
+	for _, change := range target.Changes {
+		setConfigValue(strings.ToLower(change.Key), change.Old)
+	}
+	WriteGhostwriterEnvironmentVariables()
+	recordHistoryEntry("undo", before, target.ID)
+
+	return target, nil
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHistory(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	os.Remove(historyPath())
+	defer os.Remove(historyPath())
+
+	history, err := ReadHistory()
+	assert.NoError(t, err, "Expected `ReadHistory()` to return no error without a journal")
+	assert.Empty(t, history)
+	_, err = UndoConfigChange(0, false)
+	assert.Error(t, err, "Expected an error when there is nothing to undo")
+
+	original := ghostEnv.GetString("django_date_format")
+	SetConfig("django_date_format", "Y-m-d")
+	SetConfig("postgres_password", "hunter2")
+	// Setting the same value again does not record an entry
+	SetConfig("django_date_format", "Y-m-d")
+
+	history, err = ReadHistory()
+	assert.NoError(t, err, "Expected `ReadHistory()` to return no error")
+	assert.Len(t, history, 2)
+	assert.Equal(t, KeyChange{"DJANGO_DATE_FORMAT", original, "Y-m-d", false}, history[0].Changes[0])
+
+	// Secrets are only stored as hashes
+	secret := history[1].Changes[0]
+	assert.True(t, secret.Hashed, "Expected `POSTGRES_PASSWORD` to be hashed")
+	assert.True(t, strings.HasPrefix(secret.New, hashedPrefix), "Expected the new secret to be hashed")
+	assert.NotContains(t, secret.New, "hunter2")
+	_, err = UndoConfigChange(0, false)
+	assert.Error(t, err, "Expected an error when undoing a change to a secret")
+
+	// Values changed again after the entry are only reverted with "force"
+	SetConfig("django_date_format", "d/m/Y")
+	_, err = UndoConfigChange(1, false)
+	assert.Error(t, err, "Expected an error when the value changed again")
+	reverted, err := UndoConfigChange(1, true)
+	assert.NoError(t, err, "Expected `UndoConfigChange()` to return no error with `force`")
+	assert.Equal(t, 1, reverted.ID)
+	assert.Equal(t, original, ghostEnv.GetString("django_date_format"))
+
+	history, err = ReadHistory()
+	assert.NoError(t, err, "Expected `ReadHistory()` to return no error")
+	last := history[len(history)-1]
+	assert.Equal(t, "undo", last.Action)
+	assert.Equal(t, 1, last.Reverts)
+	assert.True(t, history.IsReverted(1), "Expected change 1 to be marked as undone")
+	_, err = UndoConfigChange(1, false)
+	assert.Error(t, err, "Expected an error when undoing a change twice")
+}
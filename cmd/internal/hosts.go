@@ -0,0 +1,218 @@
+package internal
+
+// Functions for validating the allowed hosts and trusted origins lists
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// A single DNS label (RFC 1123)
+var hostnameLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Kinds of entries in the allowed hosts list
+const (
+	HostKindAny       = "any host"
+	HostKindIP        = "IP address"
+	HostKindHostname  = "hostname"
+	HostKindSubdomain = "domain and subdomains"
+)
+
+// HostEntry is a custom type for storing an allowed host or trusted origin and whether
+// the matching entry exists in the other list.
+type HostEntry struct {
+	Value   string
+	Kind    string
+	Matched bool
+}
+
+// HostEntries is a collection of HostEntry structs
+type HostEntries []HostEntry
+
+// Determine if "name" is a valid hostname (without a port or wildcard).
+func isHostname(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !hostnameLabelRegex.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateHost checks a value for Django's allowed hosts list and returns the normalized value. Django supports
+// hostnames, IP addresses, a leading "." to match a domain and its subdomains, and a lone "*" to match anything.
+// Any warnings about the value are returned so they can be shown to the user.
+func ValidateHost(host string) (string, []string, error) {
+	var warnings []string
+	host = strings.ToLower(strings.TrimSpace(host))
+
+	switch {
+	case host == "":
+		return "", warnings, fmt.Errorf("the host cannot be empty")
+	case host == "*":
+		warnings = append(warnings, "Allowing every host with `*` is NOT recommended and should only be used for testing")
+		return host, warnings, nil
+	case strings.Contains(host, "://"):
+		return "", warnings, fmt.Errorf("`%s` is a URL; provide only the hostname or IP address", host)
+	case strings.HasPrefix(host, "*."):
+		return "", warnings, fmt.Errorf("wildcards like `%s` do not work for allowed hosts; use `%s` to allow the domain and its subdomains", host, host[1:])
+	case strings.Contains(host, "*"):
+		return "", warnings, fmt.Errorf("wildcards like `%s` do not work for allowed hosts; only a single `*` is supported", host)
+	case strings.Contains(host, "/"):
+		if _, _, err := net.ParseCIDR(host); err == nil {
+			return "", warnings, fmt.Errorf("Django does not support CIDR ranges like `%s` for allowed hosts; add each IP address instead", host)
+		}
+		return "", warnings, fmt.Errorf("`%s` is not a valid hostname or IP address", host)
+	}
+
+	// IPv6 addresses must be enclosed in brackets to match the "Host" header
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(trimmed); ip != nil {
+		if ip.To4() == nil {
+			return "[" + trimmed + "]", warnings, nil
+		}
+		return host, warnings, nil
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return "", warnings, fmt.Errorf("`%s` includes a port; allowed hosts must not include ports", host)
+	}
+	if isHostname(strings.TrimPrefix(host, ".")) {
+		return host, warnings, nil
+	}
+	return "", warnings, fmt.Errorf("`%s` is not a valid hostname or IP address", host)
+}
+
+// ValidateOrigin checks a value for Django's trusted origins list and returns the normalized value. Django requires
+// origins to include the scheme and allows a "*." prefix to trust all subdomains. Origins without a scheme are
+// assumed to use HTTPS. Any warnings about the value are returned so they can be shown to the user.
+func ValidateOrigin(origin string) (string, []string, error) {
+	var warnings []string
+	origin = strings.ToLower(strings.TrimSpace(origin))
+
+	switch {
+	case origin == "":
+		return "", warnings, fmt.Errorf("the origin cannot be empty")
+	case origin == "*" || strings.HasSuffix(origin, "://*"):
+		return "", warnings, fmt.Errorf("trusting every origin with `*` defeats CSRF protection; use a hostname or `*.domain` instead")
+	case !strings.Contains(origin, "://"):
+		warnings = append(warnings, fmt.Sprintf("Origins must include a scheme, so `%s` was changed to `https://%s`", origin, origin))
+		origin = "https://" + origin
+	}
+
+	// Parse without the wildcard, which is not a valid URL character
+	wildcard := strings.Contains(origin, "://*.")
+	parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+	if err != nil {
+		return "", warnings, fmt.Errorf("`%s` is not a valid origin: %v", origin, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", warnings, fmt.Errorf("`%s` must use the http or https scheme", origin)
+	}
+	if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+		return "", warnings, fmt.Errorf("`%s` must only include the scheme, host, and optional port", origin)
+	}
+	if strings.Contains(parsed.Hostname(), "*") {
+		return "", warnings, fmt.Errorf("`%s` uses an unsupported wildcard; only a leading `*.` is supported", origin)
+	}
+	if net.ParseIP(parsed.Hostname()) != nil {
+		if wildcard {
+			return "", warnings, fmt.Errorf("`%s` uses a wildcard with an IP address", origin)
+		}
+	} else if !isHostname(parsed.Hostname()) {
+		return "", warnings, fmt.Errorf("`%s` does not contain a valid hostname or IP address", origin)
+	}
+	if parsed.Scheme == "http" {
+		warnings = append(warnings, fmt.Sprintf("`%s` uses plain HTTP, which should only be used for testing", origin))
+	}
+
+	normalized := parsed.Scheme + "://" + parsed.Host
+	if wildcard {
+		normalized = parsed.Scheme + "://*." + parsed.Host
+	}
+	return normalized, warnings, nil
+}
+
+// Convert a normalized trusted origin into the matching allowed hosts entry. For example,
+// "https://*.example.com:8443" becomes ".example.com".
+func originToHost(origin string) string {
+	rest := origin
+	if _, after, found := strings.Cut(origin, "://"); found {
+		rest = after
+	}
+	wildcard := strings.HasPrefix(rest, "*.")
+	rest = strings.TrimPrefix(rest, "*.")
+	if host, _, err := net.SplitHostPort(rest); err == nil {
+		rest = host
+		if strings.Contains(rest, ":") {
+			rest = "[" + rest + "]"
+		}
+	}
+	if wildcard {
+		return "." + rest
+	}
+	return rest
+}
+
+// Determine if Django would accept the "host" with the given allowed hosts ("allowed" parameter).
+// This mirrors the matching done by Django's "validate_host()" function.
+func isHostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == host {
+			return true
+		}
+		if strings.HasPrefix(pattern, ".") && (strings.HasSuffix(host, pattern) || host == pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify an allowed hosts entry.
+func hostKind(host string) string {
+	switch {
+	case host == "*":
+		return HostKindAny
+	case strings.HasPrefix(host, "."):
+		return HostKindSubdomain
+	case net.ParseIP(strings.Trim(host, "[]")) != nil:
+		return HostKindIP
+	}
+	return HostKindHostname
+}
+
+// ListAllowedHosts returns the allowed hosts. Each entry is matched if at least one trusted origin uses the host.
+func ListAllowedHosts() HostEntries {
+	var entries HostEntries
+	origins := splitVariable("django_csrf_trusted_origins")
+	for _, host := range splitVariable("django_allowed_hosts") {
+		matched := false
+		for _, origin := range origins {
+			if host == "*" || isHostAllowed(originToHost(origin), []string{host}) {
+				matched = true
+				break
+			}
+		}
+		entries = append(entries, HostEntry{host, hostKind(host), matched})
+	}
+	return entries
+}
+
+// ListTrustedOrigins returns the trusted origins. Each entry is matched if its host is in the allowed hosts.
+func ListTrustedOrigins() HostEntries {
+	var entries HostEntries
+	hosts := splitVariable("django_allowed_hosts")
+	for _, origin := range splitVariable("django_csrf_trusted_origins") {
+		host := originToHost(origin)
+		entries = append(entries, HostEntry{origin, hostKind(host), isHostAllowed(host, hosts)})
+	}
+	return entries
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHost(t *testing.T) {
+	for _, host := range []string{"ghostwriter.local", "192.168.1.100", ".example.com", "localhost"} {
+		normalized, _, err := ValidateHost(host)
+		assert.NoError(t, err, "Expected `%s` to be a valid host", host)
+		assert.Equal(t, host, normalized)
+	}
+
+	normalized, warnings, err := ValidateHost("*")
+	assert.NoError(t, err, "Expected `*` to be a valid host")
+	assert.Equal(t, "*", normalized)
+	assert.NotEmpty(t, warnings, "Expected a warning for `*`")
+
+	normalized, _, err = ValidateHost("::1")
+	assert.NoError(t, err, "Expected `::1` to be a valid host")
+	assert.Equal(t, "[::1]", normalized, "Expected IPv6 addresses to be enclosed in brackets")
+
+	for _, host := range []string{"", "*.example.com", "192.168.1.*", "192.168.1.0/24", "https://example.com", "example.com:8443", "bad_host!"} {
+		_, _, err := ValidateHost(host)
+		assert.Error(t, err, "Expected `%s` to be an invalid host", host)
+	}
+}
+
+func TestValidateOrigin(t *testing.T) {
+	for _, origin := range []string{"https://ghostwriter.local", "https://*.ghostwriter.local", "https://ghostwriter.local:8443", "https://10.0.0.5"} {
+		normalized, _, err := ValidateOrigin(origin)
+		assert.NoError(t, err, "Expected `%s` to be a valid origin", origin)
+		assert.Equal(t, origin, normalized)
+	}
+
+	normalized, warnings, err := ValidateOrigin("ghostwriter.local")
+	assert.NoError(t, err, "Expected origins without a scheme to be accepted")
+	assert.Equal(t, "https://ghostwriter.local", normalized, "Expected origins without a scheme to use HTTPS")
+	assert.NotEmpty(t, warnings, "Expected a warning when adding the scheme")
+
+	normalized, _, err = ValidateOrigin("HTTPS://Ghostwriter.Local/")
+	assert.NoError(t, err, "Expected a trailing slash to be accepted")
+	assert.Equal(t, "https://ghostwriter.local", normalized)
+
+	for _, origin := range []string{"", "*", "https://*", "ftp://ghostwriter.local", "https://ghostwriter.local/path", "https://*.10.0.0.5", "https://gh*st.local", "10.0.0.0/8"} {
+		_, _, err := ValidateOrigin(origin)
+		assert.Error(t, err, "Expected `%s` to be an invalid origin", origin)
+	}
+}
+
+func TestOriginToHost(t *testing.T) {
+	assert.Equal(t, "ghostwriter.local", originToHost("https://ghostwriter.local"))
+	assert.Equal(t, "ghostwriter.local", originToHost("https://ghostwriter.local:8443"))
+	assert.Equal(t, ".ghostwriter.local", originToHost("https://*.ghostwriter.local"))
+	assert.Equal(t, "[::1]", originToHost("https://[::1]:8443"))
+	assert.True(t, isHostAllowed("app.example.com", []string{".example.com"}), "Expected a leading `.` to match subdomains")
+	assert.True(t, isHostAllowed("example.com", []string{".example.com"}), "Expected a leading `.` to match the domain")
+	assert.False(t, isHostAllowed("badexample.com", []string{".example.com"}), "Expected a leading `.` to not match other domains")
+}
+
+func TestHostAndOriginConsistency(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+	SetConfig("django_allowed_hosts", "localhost")
+	SetConfig("django_csrf_trusted_origins", "")
+
+	assert.Empty(t, splitVariable("django_csrf_trusted_origins"), "Expected an empty value to produce no entries")
+	assert.Error(t, AllowHost("*.example.com"), "Expected `AllowHost()` to reject wildcard hostnames")
+	assert.Error(t, TrustOrigin("*"), "Expected `TrustOrigin()` to reject `*`")
+
+	// Trusting an origin also allows its host
+	assert.NoError(t, TrustOrigin("https://app.example.com"))
+	assert.Equal(t, []string{"localhost", "app.example.com"}, splitVariable("django_allowed_hosts"))
+	assert.Equal(t, HostEntries{{"localhost", HostKindHostname, false}, {"app.example.com", HostKindHostname, true}}, ListAllowedHosts())
+	assert.Equal(t, HostEntries{{"https://app.example.com", HostKindHostname, true}}, ListTrustedOrigins())
+
+	// Subdomain origins map to a leading `.` in the allowed hosts
+	assert.NoError(t, TrustOrigin("https://*.corp.example"))
+	assert.Contains(t, splitVariable("django_allowed_hosts"), ".corp.example")
+
+	// Disallowing a host removes its trusted origins
+	assert.NoError(t, DisallowHost("app.example.com"))
+	assert.Equal(t, []string{"localhost", ".corp.example"}, splitVariable("django_allowed_hosts"))
+	assert.Equal(t, []string{"https://*.corp.example"}, splitVariable("django_csrf_trusted_origins"))
+
+	// Distrusting an origin accepts the value without a scheme
+	assert.NoError(t, DistrustOrigin("*.corp.example"))
+	assert.Empty(t, splitVariable("django_csrf_trusted_origins"))
+}
@@ -0,0 +1,260 @@
+package internal
+
+// Functions for managing multiple named Ghostwriter instances on the same host.
+// Every instance gets its own environment file, Docker Compose project name,
+// volume prefix, and port assignments.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Name of the instance that uses the original ``.env`` file and project name
+	DefaultInstanceName = "default"
+	// Project name (and volume prefix) used by the default instance
+	defaultProjectName = "ghostwriter"
+	// Directory (relative to the CLI binary) holding the named instances' environment files
+	instancesDir = "instances"
+)
+
+// Names must be valid Docker Compose project names once prefixed, so keep them short and simple
+var instanceNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Instance is a custom type for storing the settings that separate one Ghostwriter deployment from another.
+type Instance struct {
+	Name       string
+	Project    string
+	EnvFile    string
+	NginxPort  string
+	DjangoPort string
+}
+
+// Instances is a collection of Instance structs
+type Instances []Instance
+
+// Len returns the length of an Instances struct
+func (c Instances) Len() int {
+	return len(c)
+}
+
+// Less determines if one Instance is less than another Instance
+// The default instance is always sorted first
+func (c Instances) Less(i, j int) bool {
+	if c[i].IsDefault() != c[j].IsDefault() {
+		return c[i].IsDefault()
+	}
+	return c[i].Name < c[j].Name
+}
+
+// Swap exchanges the position of two Instance values in an Instances struct
+func (c Instances) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// IsDefault returns true if the instance is the default instance that uses the original ".env" file.
+func (i Instance) IsDefault() bool {
+	return i.Name == DefaultInstanceName
+}
+
+// The instance targeted by all commands
+var activeInstance = newInstance(DefaultInstanceName)
+
+// Build the Instance for the given "name" without checking if it exists.
+func newInstance(name string) Instance {
+	if name == DefaultInstanceName {
+		return Instance{
+			Name:    DefaultInstanceName,
+			Project: defaultProjectName,
+			EnvFile: filepath.Join(GetCwdFromExe(), ".env"),
+		}
+	}
+	return Instance{
+		Name:    name,
+		Project: defaultProjectName + "_" + name,
+		EnvFile: filepath.Join(GetCwdFromExe(), instancesDir, name+".env"),
+	}
+}
+
+// ValidateInstanceName checks if the "name" can be used for a named instance.
+func ValidateInstanceName(name string) error {
+	if !instanceNameRegex.MatchString(name) {
+		return fmt.Errorf("instance name `%s` is invalid (use up to 32 lowercase letters, numbers, dashes, and underscores)", name)
+	}
+	return nil
+}
+
+// GetActiveInstance returns the instance targeted by the current command.
+func GetActiveInstance() Instance {
+	return activeInstance
+}
+
+// SetActiveInstance switches the instance targeted by all commands. The instance must already exist.
+// This must be called before "ParseGhostwriterEnvironmentVariables()" to load the right environment file.
+func SetActiveInstance(name string) error {
+	if name == "" || name == DefaultInstanceName {
+		activeInstance = newInstance(DefaultInstanceName)
+		return nil
+	}
+	if err := ValidateInstanceName(name); err != nil {
+		return err
+	}
+	instance := newInstance(name)
+	if !FileExists(instance.EnvFile) {
+		return fmt.Errorf("instance `%s` does not exist (create it with `ghostwriter-cli instances create %s`)", name, name)
+	}
+	activeInstance = instance
+	return nil
+}
+
+// CreateInstance creates the environment file for a new named instance with the given ports.
+// Ports set to an empty string are assigned automatically so they do not conflict with other instances.
+// All other values are populated with defaults the first time the instance is loaded.
+func CreateInstance(name string, nginxPort string, djangoPort string) (Instance, error) {
+	if name == DefaultInstanceName {
+		return Instance{}, fmt.Errorf("the `%s` instance always exists", DefaultInstanceName)
+	}
+	if err := ValidateInstanceName(name); err != nil {
+		return Instance{}, err
+	}
+	instance := newInstance(name)
+	if FileExists(instance.EnvFile) {
+		return Instance{}, fmt.Errorf("instance `%s` already exists", name)
+	}
+
+	// Check for port conflicts with the existing instances
+	existing, err := ListInstances()
+	if err != nil {
+		return Instance{}, err
+	}
+	var usedPorts []string
+	for _, other := range existing {
+		usedPorts = append(usedPorts, other.NginxPort, other.DjangoPort)
+	}
+	if nginxPort == "" {
+		nginxPort = nextFreePort(443, 1000, usedPorts)
+	}
+	usedPorts = append(usedPorts, nginxPort)
+	if djangoPort == "" {
+		djangoPort = nextFreePort(8000, 1, usedPorts)
+	}
+	for _, port := range []string{nginxPort, djangoPort} {
+		for _, other := range existing {
+			if port == other.NginxPort || port == other.DjangoPort {
+				return Instance{}, fmt.Errorf("port %s is already assigned to the `%s` instance", port, other.Name)
+			}
+		}
+	}
+	if nginxPort == djangoPort {
+		return Instance{}, fmt.Errorf("the Nginx and Django ports must be different")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(instance.EnvFile), 0700); err != nil {
+		return Instance{}, err
+	}
+	content := fmt.Sprintf("NGINX_PORT='%s'\nDJANGO_PORT='%s'\n", nginxPort, djangoPort)
+	if err := os.WriteFile(instance.EnvFile, []byte(content), 0600); err != nil {
+		return Instance{}, err
+	}
+	instance.NginxPort = nginxPort
+	instance.DjangoPort = djangoPort
+	return instance, nil
+}
+
+// Find the first port after "start" (counting by "step") that is not in the "used" list.
+func nextFreePort(start int, step int, used []string) string {
+	for port := start + step; port < 65536; port += step {
+		candidate := fmt.Sprintf("%d", port)
+		if !Contains(used, candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// ListInstances returns the default instance and every named instance found in the "instances" directory.
+func ListInstances() (Instances, error) {
+	var instances Instances
+
+	names := []string{DefaultInstanceName}
+	matches, err := filepath.Glob(filepath.Join(GetCwdFromExe(), instancesDir, "*.env"))
+	if err != nil {
+		return instances, err
+	}
+	for _, match := range matches {
+		name := strings.TrimSuffix(filepath.Base(match), ".env")
+		if ValidateInstanceName(name) == nil {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		instance := newInstance(name)
+		instance.NginxPort, instance.DjangoPort = "443", "8000"
+		if FileExists(instance.EnvFile) {
+			values := viper.New()
+			values.SetConfigFile(instance.EnvFile)
+			values.SetConfigType("env")
+			if err := values.ReadInConfig(); err != nil {
+				return instances, fmt.Errorf("could not read %s: %v", instance.EnvFile, err)
+			}
+			if values.IsSet("nginx_port") {
+				instance.NginxPort = values.GetString("nginx_port")
+			}
+			if values.IsSet("django_port") {
+				instance.DjangoPort = values.GetString("django_port")
+			}
+		}
+		instances = append(instances, instance)
+	}
+
+	sort.Sort(instances)
+
+	return instances, nil
+}
+
+// Determine if a container with the given labels belongs to the active instance. Containers for the default
+// instance are any containers that do not belong to one of the named instances, so installations that predate
+// named instances (or use a different project name) keep working.
+func belongsToActiveInstance(labels map[string]string) bool {
+	project := labels["com.docker.compose.project"]
+	if !activeInstance.IsDefault() {
+		return project == activeInstance.Project
+	}
+	if strings.HasPrefix(project, defaultProjectName+"_") {
+		name := strings.TrimPrefix(project, defaultProjectName+"_")
+		return !FileExists(newInstance(name).EnvFile)
+	}
+	return true
+}
+
+// Build the global "docker compose" arguments that target the active instance's project and environment file.
+func instanceComposeArgs() []string {
+	if activeInstance.IsDefault() {
+		return nil
+	}
+	return []string{"-p", activeInstance.Project, "--env-file", activeInstance.EnvFile}
+}
+
+// ComposeArgs returns the full list of arguments for running "docker compose" (including the "compose"
+// command itself) for the active instance with "args" appended. Use this with "RunBasicCmd()".
+func ComposeArgs(args ...string) []string {
+	full := append([]string{"compose"}, instanceComposeArgs()...)
+	return append(full, args...)
+}
+
+// VolumeName returns the name of the Docker volume with the given "suffix" (e.g., "data") for the environment
+// described by the specified YAML file ("yaml" parameter) and the active instance.
+func VolumeName(yaml string, suffix string) string {
+	interfix := "production"
+	if yaml == "local.yml" {
+		interfix = "local"
+	}
+	return fmt.Sprintf("%s_%s_%s", activeInstance.Project, interfix, suffix)
+}
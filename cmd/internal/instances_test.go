@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInstanceName(t *testing.T) {
+	assert.NoError(t, ValidateInstanceName("training"), "Expected `training` to be a valid instance name")
+	assert.NoError(t, ValidateInstanceName("eng-2024_a"), "Expected `eng-2024_a` to be a valid instance name")
+	assert.Error(t, ValidateInstanceName("Training"), "Expected uppercase instance names to be invalid")
+	assert.Error(t, ValidateInstanceName("-training"), "Expected instance names starting with a dash to be invalid")
+	assert.Error(t, ValidateInstanceName("../training"), "Expected instance names with path characters to be invalid")
+}
+
+func TestInstances(t *testing.T) {
+	defer quietTests()()
+	defer os.RemoveAll(filepath.Join(GetCwdFromExe(), instancesDir))
+	defer SetActiveInstance(DefaultInstanceName)
+
+	// The default instance keeps the original behavior
+	assert.NoError(t, SetActiveInstance(""), "Expected `SetActiveInstance()` to accept an empty name")
+	assert.True(t, GetActiveInstance().IsDefault(), "Expected the default instance to be active")
+	assert.Empty(t, instanceComposeArgs(), "Expected no extra `compose` arguments for the default instance")
+	assert.Equal(t, "ghostwriter_production_data", VolumeName("production.yml", "data"))
+	assert.Equal(t, "ghostwriter_local_postgres_data_backups", VolumeName("local.yml", "postgres_data_backups"))
+
+	// Named instances must exist before they can be targeted
+	assert.Error(t, SetActiveInstance("training"), "Expected an error for an instance that does not exist")
+
+	training, err := CreateInstance("training", "", "")
+	assert.NoError(t, err, "Expected `CreateInstance()` to return no error")
+	assert.Equal(t, "ghostwriter_training", training.Project)
+	assert.Equal(t, "1443", training.NginxPort, "Expected the Nginx port to be assigned automatically")
+	assert.Equal(t, "8001", training.DjangoPort, "Expected the Django port to be assigned automatically")
+
+	_, err = CreateInstance("training", "", "")
+	assert.Error(t, err, "Expected an error when creating an instance twice")
+	_, err = CreateInstance("engagement", "1443", "")
+	assert.Error(t, err, "Expected an error when reusing another instance's port")
+
+	engagement, err := CreateInstance("engagement", "", "9000")
+	assert.NoError(t, err, "Expected `CreateInstance()` to return no error")
+	assert.Equal(t, "2443", engagement.NginxPort)
+	assert.Equal(t, "9000", engagement.DjangoPort)
+
+	instances, err := ListInstances()
+	assert.NoError(t, err, "Expected `ListInstances()` to return no error")
+	assert.Equal(t, 3, len(instances), "Expected `ListInstances()` to return three instances")
+	assert.Equal(t, DefaultInstanceName, instances[0].Name, "Expected the default instance to be listed first")
+	assert.Equal(t, "engagement", instances[1].Name)
+	assert.Equal(t, "training", instances[2].Name)
+
+	// Targeting a named instance loads its environment file and adjusts `compose` arguments and volumes
+	assert.NoError(t, SetActiveInstance("training"), "Expected `SetActiveInstance()` to return no error")
+	ParseGhostwriterEnvironmentVariables()
+	assert.Equal(t, "1443", ghostEnv.GetString("nginx_port"), "Expected the instance's Nginx port to be loaded")
+	assert.Equal(t, "d M Y", ghostEnv.GetString("django_date_format"), "Expected defaults to fill in the instance's environment file")
+	assert.Equal(
+		t,
+		[]string{"compose", "-p", "ghostwriter_training", "--env-file", training.EnvFile, "-f", "production.yml", "up"},
+		ComposeArgs("-f", "production.yml", "up"),
+	)
+	assert.Equal(t, "ghostwriter_training_production_data", VolumeName("production.yml", "data"))
+	assert.True(t, belongsToActiveInstance(map[string]string{"com.docker.compose.project": "ghostwriter_training"}))
+	assert.False(t, belongsToActiveInstance(map[string]string{"com.docker.compose.project": "ghostwriter"}))
+
+	// The default instance ignores containers that belong to named instances
+	assert.NoError(t, SetActiveInstance(DefaultInstanceName))
+	assert.False(t, belongsToActiveInstance(map[string]string{"com.docker.compose.project": "ghostwriter_training"}))
+	assert.True(t, belongsToActiveInstance(map[string]string{"com.docker.compose.project": "ghostwriter"}))
+}
@@ -0,0 +1,414 @@
+package internal
+
+// Functions for exporting the logs of Ghostwriter's containers to rotated files
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/moby/client"
+)
+
+// File in the export directory that records the time of the last line exported from each container
+const logPositionsFile = ".positions.json"
+
+// LogExportOptions is a custom type for storing the options for exporting logs to files.
+type LogExportOptions struct {
+	// Directory for the log files
+	Dir string
+	// Size in bytes at which a log file is rotated
+	MaxSize int64
+	// Number of rotated (gzipped) files to keep for each service
+	MaxFiles int
+	// Time window for services that have not been exported to the directory yet
+	Since string
+	Until string
+	// Keep tailing the containers and check for new or recreated containers every "Interval"
+	Daemon   bool
+	Interval time.Duration
+}
+
+// rotatingLogFile is a custom type for a service's log file that is gzipped and replaced once it reaches the
+// maximum size.
+type rotatingLogFile struct {
+	dir      string
+	service  string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func (f *rotatingLogFile) path() string {
+	return filepath.Join(f.dir, f.service+".log")
+}
+
+// Open the log file for appending.
+func (f *rotatingLogFile) open() error {
+	file, err := os.OpenFile(f.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write a line to the file, rotating the file first if the line would put it over the maximum size.
+func (f *rotatingLogFile) writeLine(line string) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line))+1 > f.maxSize {
+		if err := f.rotate(time.Now()); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.WriteString(line + "\n")
+	f.size += int64(n)
+	return err
+}
+
+// Compress the current file to "<service>-<time>.log.gz", start a new file, and remove the oldest archives.
+func (f *rotatingLogFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	archive := filepath.Join(f.dir, fmt.Sprintf("%s-%s.log.gz", f.service, now.UTC().Format("20060102-150405.000000")))
+	if err := gzipFile(f.path(), archive); err != nil {
+		return err
+	}
+	if err := os.Remove(f.path()); err != nil {
+		return err
+	}
+	if err := pruneLogArchives(f.dir, f.service, f.maxFiles); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingLogFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Compress the file at "src" to "dst".
+func gzipFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Remove the oldest gzipped files of the service, keeping "maxFiles" of them (or all of them if it is zero).
+func pruneLogArchives(dir string, service string, maxFiles int) error {
+	if maxFiles <= 0 {
+		return nil
+	}
+	archives, err := filepath.Glob(filepath.Join(dir, service+"-*.log.gz"))
+	if err != nil {
+		return err
+	}
+	// The timestamps in the names sort chronologically
+	sort.Strings(archives)
+	for len(archives) > maxFiles {
+		if err := os.Remove(archives[0]); err != nil {
+			return err
+		}
+		archives = archives[1:]
+	}
+	return nil
+}
+
+// Format a line for the log file with its timestamp and stream.
+func exportLogLine(line LogLine) string {
+	stamp := "-"
+	if !line.Time.IsZero() {
+		stamp = line.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%s %s %s", stamp, line.Stream, line.Text)
+}
+
+// LogExporter is a custom type for writing the logs of every service to its own rotated file. The time of the
+// last line written from each container is recorded, so repeated exports continue where the last export stopped
+// without duplicating lines. Positions are kept by container rather than by service because a stopped container
+// and its replacement (e.g., after an upgrade) can be exported at the same time.
+type LogExporter struct {
+	opts  LogExportOptions
+	mutex sync.Mutex
+	files map[string]*rotatingLogFile
+	// Time of the last line written by container ID
+	positions map[string]time.Time
+	lines     int
+}
+
+// NewLogExporter creates the export directory and loads the positions of earlier exports.
+func NewLogExporter(opts LogExportOptions) (*LogExporter, error) {
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, err
+	}
+	exporter := &LogExporter{
+		opts:      opts,
+		files:     make(map[string]*rotatingLogFile),
+		positions: make(map[string]time.Time),
+	}
+	data, err := os.ReadFile(filepath.Join(opts.Dir, logPositionsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &exporter.positions); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", logPositionsFile, err)
+		}
+	}
+	return exporter, nil
+}
+
+// Get the name of the service's log file from the container's name (e.g., "django" for "ghostwriter_django").
+func exportServiceName(containerName string) string {
+	return strings.TrimPrefix(containerName, "ghostwriter_")
+}
+
+// Write a line from the container ("containerID" parameter) to its service's file unless it was exported before.
+func (e *LogExporter) write(containerID string, line LogLine) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	service := exportServiceName(line.Container)
+	if !line.Time.IsZero() {
+		if position, ok := e.positions[containerID]; ok && !line.Time.After(position) {
+			return nil
+		}
+		e.positions[containerID] = line.Time
+	}
+	file, ok := e.files[service]
+	if !ok {
+		file = &rotatingLogFile{dir: e.opts.Dir, service: service, maxSize: e.opts.MaxSize, maxFiles: e.opts.MaxFiles}
+		e.files[service] = file
+	}
+	e.lines++
+	return file.writeLine(exportLogLine(line))
+}
+
+// Get the options for reading a container's logs, starting after its last exported line.
+func (e *LogExporter) logOptions(container LogContainer, follow bool) LogOptions {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	opts := LogOptions{Tail: "all", Since: e.opts.Since, Until: e.opts.Until, Follow: follow}
+	if position, ok := e.positions[container.ID]; ok {
+		opts.Since = fmt.Sprintf("%d.%09d", position.Unix(), position.Nanosecond())
+	}
+	return opts
+}
+
+// Forget the positions of containers that no longer exist ("containers" parameter lists every container), so the
+// positions file doesn't grow with every recreated container.
+func (e *LogExporter) prune(containers []LogContainer) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	existing := make(map[string]bool)
+	for _, container := range containers {
+		existing[container.ID] = true
+	}
+	for id := range e.positions {
+		if !existing[id] {
+			delete(e.positions, id)
+		}
+	}
+}
+
+// Save the positions of the containers.
+func (e *LogExporter) savePositions() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	data, err := json.MarshalIndent(e.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.opts.Dir, logPositionsFile)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Close the log files and save the positions.
+func (e *LogExporter) Close() error {
+	e.mutex.Lock()
+	var err error
+	for _, file := range e.files {
+		if closeErr := file.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	e.mutex.Unlock()
+	if saveErr := e.savePositions(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// Lines returns the number of lines written by the exporter.
+func (e *LogExporter) Lines() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lines
+}
+
+// Export the logs of one container. The lines of a single container are already in order, so they are written to
+// the file as they are read instead of being merged. Write errors stop the export of the container.
+func (e *LogExporter) exportContainer(ctx context.Context, cli *client.Client, container LogContainer, follow bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var writeErr error
+	err := streamContainerLogs(ctx, cli, container, e.logOptions(container, follow), func(line LogLine) {
+		if writeErr != nil {
+			return
+		}
+		if writeErr = e.write(container.ID, line); writeErr != nil {
+			cancel()
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// ExportLogs writes the logs of every Ghostwriter container to "<service>.log" files in the export directory.
+// With the "Daemon" option, this keeps tailing the containers until the context is cancelled, picking up new
+// and recreated containers every "Interval". The "report" function is called with progress messages.
+func ExportLogs(ctx context.Context, opts LogExportOptions, report func(string)) (int, error) {
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return 0, err
+	}
+	defer cli.Close()
+
+	exporter, err := NewLogExporter(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if !opts.Daemon {
+		containers, err := FindLogContainers(cli, "all")
+		if err != nil {
+			exporter.Close()
+			return 0, err
+		}
+		exporter.prune(containers)
+		for _, container := range containers {
+			if err := exporter.exportContainer(ctx, cli, container, false); err != nil {
+				exporter.Close()
+				return exporter.Lines(), err
+			}
+		}
+		return exporter.Lines(), exporter.Close()
+	}
+
+	// Stop the other tails if writing a file fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wait sync.WaitGroup
+	var mutex sync.Mutex
+	// Containers being tailed and stopped containers that were already exported
+	tailing := make(map[string]bool)
+	exported := make(map[string]bool)
+	var exportErr error
+
+	scan := func() {
+		containers, err := FindLogContainers(cli, "all")
+		if err != nil {
+			report(fmt.Sprintf("Failed to list the containers: %v", err))
+			return
+		}
+		exporter.prune(containers)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, container := range containers {
+			if tailing[container.ID] || (!container.Running && exported[container.ID]) {
+				continue
+			}
+			if !container.Running {
+				exported[container.ID] = true
+			} else {
+				report(fmt.Sprintf("Tailing %s", container.Name))
+			}
+			tailing[container.ID] = true
+			wait.Add(1)
+			go func(container LogContainer) {
+				defer wait.Done()
+				err := exporter.exportContainer(ctx, cli, container, container.Running)
+				mutex.Lock()
+				delete(tailing, container.ID)
+				if err != nil && ctx.Err() == nil && exportErr == nil {
+					exportErr = err
+				}
+				mutex.Unlock()
+				if container.Running && ctx.Err() == nil {
+					report(fmt.Sprintf("Stopped tailing %s", container.Name))
+				}
+			}(container)
+		}
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	scan()
+	for {
+		select {
+		case <-ctx.Done():
+			wait.Wait()
+			return exporter.Lines(), exporter.Close()
+		case <-ticker.C:
+			mutex.Lock()
+			err := exportErr
+			mutex.Unlock()
+			if err != nil {
+				cancel()
+				wait.Wait()
+				exporter.Close()
+				return exporter.Lines(), err
+			}
+			scan()
+			if err := exporter.savePositions(); err != nil {
+				report(fmt.Sprintf("Failed to save the positions: %v", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingLogFile(t *testing.T) {
+	dir := t.TempDir()
+	file := &rotatingLogFile{dir: dir, service: "django", maxSize: 20, maxFiles: 2}
+	defer file.close()
+
+	assert.NoError(t, file.writeLine("first line"))
+	assert.NoError(t, file.writeLine("second line"), "Expected the file to rotate before the second line")
+	archives, _ := filepath.Glob(filepath.Join(dir, "django-*.log.gz"))
+	if assert.Len(t, archives, 1) {
+		archive, err := os.Open(archives[0])
+		assert.NoError(t, err)
+		gz, err := gzip.NewReader(archive)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(gz)
+		archive.Close()
+		assert.Equal(t, "first line\n", string(data))
+	}
+	current, _ := os.ReadFile(filepath.Join(dir, "django.log"))
+	assert.Equal(t, "second line\n", string(current))
+
+	// Only the newest archives are kept
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, file.rotate(time.Date(2030, 1, 2, 15, 4, i, 0, time.UTC)))
+	}
+	archives, _ = filepath.Glob(filepath.Join(dir, "django-*.log.gz"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "django-20300102-150401.000000.log.gz"),
+		filepath.Join(dir, "django-20300102-150402.000000.log.gz"),
+	}, archives)
+}
+
+func TestLogExporterPositions(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	exporter, err := NewLogExporter(LogExportOptions{Dir: dir})
+	assert.NoError(t, err, "Expected `NewLogExporter()` to return no error")
+	assert.NoError(t, exporter.write("old", LogLine{Container: "ghostwriter_django", Stream: LogStdout, Time: base, Text: "first"}))
+	assert.NoError(t, exporter.write("old", LogLine{Container: "ghostwriter_django", Stream: LogStderr, Time: base.Add(time.Second), Text: "second"}))
+	assert.NoError(t, exporter.Close())
+
+	// A later export skips the lines it already wrote for the container
+	exporter, err = NewLogExporter(LogExportOptions{Dir: dir, Since: "1h"})
+	assert.NoError(t, err)
+	opts := exporter.logOptions(LogContainer{ID: "old", Name: "ghostwriter_django"}, true)
+	assert.Equal(t, "1735830246.000000000", opts.Since)
+	assert.True(t, opts.Follow)
+	assert.Equal(t, "1h", exporter.logOptions(LogContainer{ID: "new", Name: "ghostwriter_django"}, false).Since)
+	assert.NoError(t, exporter.write("old", LogLine{Container: "ghostwriter_django", Stream: LogStderr, Time: base.Add(time.Second), Text: "second"}))
+	assert.NoError(t, exporter.write("old", LogLine{Container: "ghostwriter_django", Stream: LogStdout, Time: base.Add(2 * time.Second), Text: "third"}))
+
+	// A replacement container exported at the same time doesn't drop the old container's remaining lines
+	assert.NoError(t, exporter.write("new", LogLine{Container: "ghostwriter_django", Stream: LogStdout, Time: base.Add(10 * time.Second), Text: "replacement"}))
+	assert.NoError(t, exporter.write("old", LogLine{Container: "ghostwriter_django", Stream: LogStdout, Time: base.Add(3 * time.Second), Text: "fourth"}))
+	assert.Equal(t, 3, exporter.Lines())
+
+	// Positions of removed containers are forgotten
+	exporter.prune([]LogContainer{{ID: "new", Name: "ghostwriter_django"}})
+	assert.NoError(t, exporter.Close())
+	positions, _ := os.ReadFile(filepath.Join(dir, logPositionsFile))
+	assert.NotContains(t, string(positions), `"old"`)
+	assert.Contains(t, string(positions), `"new"`)
+
+	data, _ := os.ReadFile(filepath.Join(dir, "django.log"))
+	assert.Equal(t, []string{
+		"2025-01-02T15:04:05Z stdout first",
+		"2025-01-02T15:04:06Z stderr second",
+		"2025-01-02T15:04:07Z stdout third",
+		"2025-01-02T15:04:15Z stdout replacement",
+		"2025-01-02T15:04:08Z stdout fourth",
+	}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+}
+
+func TestLogExporterExportContainer(t *testing.T) {
+	// Fake Docker API that returns the logs of a container with a TTY
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/containers/abc/logs") {
+			io.WriteString(w, "2025-01-02T15:04:05Z first\n2025-01-02T15:04:06Z second\n")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	cli, err := client.New(client.WithHost("tcp://" + server.Listener.Addr().String()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+
+	dir := t.TempDir()
+	exporter, err := NewLogExporter(LogExportOptions{Dir: dir})
+	assert.NoError(t, err)
+	container := LogContainer{ID: "abc", Name: "ghostwriter_queue", TTY: true}
+	assert.NoError(t, exporter.exportContainer(context.Background(), cli, container, false), "Expected `exportContainer()` to return no error")
+	assert.Equal(t, 2, exporter.Lines())
+	assert.NoError(t, exporter.Close())
+
+	data, _ := os.ReadFile(filepath.Join(dir, "queue.log"))
+	assert.Equal(t, "2025-01-02T15:04:05Z stdout first\n2025-01-02T15:04:06Z stdout second\n", string(data))
+}
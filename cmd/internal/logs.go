@@ -0,0 +1,527 @@
+package internal
+
+// Functions for retrieving and streaming the logs of Ghostwriter's containers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// Streams of log lines
+const (
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+)
+
+// Time lines are held back while following logs, so lines from different containers can be merged in order
+const logMergeWindow = 250 * time.Millisecond
+
+// ANSI colors for the service prefixes, in the same order as "docker compose logs"
+var logColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+// LogOptions is a custom type for storing the options for retrieving logs.
+type LogOptions struct {
+	// Number of lines to show from the end of the logs (or "all")
+	Tail string
+	// Show logs since and until a timestamp (e.g., "2025-01-02T15:04:05") or a relative time (e.g., "42m")
+	Since string
+	Until string
+	// Keep streaming new lines
+	Follow bool
+	// Show Docker's timestamps and color the prefixes when printing the logs
+	Timestamps bool
+	Color      bool
+	// Only keep the lines the function returns true for, so other lines are dropped as they are read instead of
+	// being held until the logs are sorted
+	Filter func(LogLine) bool
+}
+
+// LogLine is a custom type for storing one line of a container's logs.
+type LogLine struct {
+	Container string
+	Stream    string
+	Time      time.Time
+	Text      string
+}
+
+// LogFormatter is a custom type for formatting log lines with a prefix for the container.
+type LogFormatter struct {
+	Timestamps bool
+	Color      bool
+	width      int
+	colors     map[string]string
+}
+
+// NewLogFormatter creates a LogFormatter with prefixes padded to the longest of the container names.
+func NewLogFormatter(containers []string, timestamps bool, color bool) *LogFormatter {
+	formatter := &LogFormatter{Timestamps: timestamps, Color: color, colors: make(map[string]string)}
+	sorted := append([]string(nil), containers...)
+	sort.Strings(sorted)
+	for i, name := range sorted {
+		if len(name) > formatter.width {
+			formatter.width = len(name)
+		}
+		formatter.colors[name] = logColors[i%len(logColors)]
+	}
+	return formatter
+}
+
+// Format the line with the container's prefix and an optional timestamp.
+func (f *LogFormatter) Format(line LogLine) string {
+	prefix := fmt.Sprintf("%-*s |", f.width, line.Container)
+	if f.Color {
+		color, ok := f.colors[line.Container]
+		if !ok {
+			color = logColors[0]
+		}
+		prefix = "\033[" + color + "m" + prefix + "\033[0m"
+	}
+	text := line.Text
+	if f.Timestamps && !line.Time.IsZero() {
+		text = line.Time.Format(time.RFC3339Nano) + " " + text
+	}
+	return prefix + " " + text
+}
+
+// Split a line from Docker's logs into the timestamp Docker adds with the "Timestamps" option and the text.
+func parseLogTimestamp(line string) (time.Time, string) {
+	stamp, text, ok := strings.Cut(line, " ")
+	if !ok {
+		stamp = line
+		text = ""
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return time.Time{}, line
+	}
+	return parsed, text
+}
+
+// logLineWriter is an io.Writer that splits a container's output into log lines. Partial lines are kept
+// until the rest arrives, so lines split across Docker's frames are not broken up.
+type logLineWriter struct {
+	container string
+	stream    string
+	emit      func(LogLine)
+	buffer    []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for {
+		index := bytes.IndexByte(w.buffer, '\n')
+		if index < 0 {
+			break
+		}
+		w.line(string(w.buffer[:index]))
+		w.buffer = w.buffer[index+1:]
+	}
+	return len(p), nil
+}
+
+// Emit the last line if the output did not end with a newline.
+func (w *logLineWriter) flush() {
+	if len(w.buffer) > 0 {
+		w.line(string(w.buffer))
+		w.buffer = nil
+	}
+}
+
+func (w *logLineWriter) line(raw string) {
+	stamp, text := parseLogTimestamp(strings.TrimSuffix(raw, "\r"))
+	w.emit(LogLine{Container: w.container, Stream: w.stream, Time: stamp, Text: text})
+}
+
+// Demultiplex a container's log stream ("reader" parameter) into lines. Containers with a TTY don't
+// multiplex their output, so everything is treated as stdout.
+func readLogStream(reader io.Reader, container string, tty bool, emit func(LogLine)) error {
+	stdout := &logLineWriter{container: container, stream: LogStdout, emit: emit}
+	stderr := &logLineWriter{container: container, stream: LogStderr, emit: emit}
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	stdout.flush()
+	stderr.flush()
+	return err
+}
+
+// Sort the lines by time. Lines without a timestamp keep their place after the previous line.
+func sortLogLines(lines []LogLine) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+}
+
+// logMerger is a custom type for merging lines from several containers in chronological order while following
+// logs. Lines are held for the "logMergeWindow" and released in order of their timestamps.
+type logMerger struct {
+	mutex   sync.Mutex
+	pending []pendingLogLine
+	emit    func(LogLine)
+}
+
+type pendingLogLine struct {
+	line    LogLine
+	arrived time.Time
+}
+
+func (m *logMerger) add(line LogLine) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pending = append(m.pending, pendingLogLine{line: line, arrived: time.Now()})
+}
+
+// Release the lines that arrived before the "cutoff" (or all lines if the cutoff is zero).
+func (m *logMerger) release(cutoff time.Time) {
+	m.mutex.Lock()
+	var ready []LogLine
+	var waiting []pendingLogLine
+	for _, pending := range m.pending {
+		if cutoff.IsZero() || pending.arrived.Before(cutoff) {
+			ready = append(ready, pending.line)
+		} else {
+			waiting = append(waiting, pending)
+		}
+	}
+	m.pending = waiting
+	m.mutex.Unlock()
+
+	sortLogLines(ready)
+	for _, line := range ready {
+		m.emit(line)
+	}
+}
+
+// LogContainer is a custom type for storing a container whose logs can be retrieved.
+type LogContainer struct {
+	ID      string
+	Name    string
+	TTY     bool
+	Running bool
+}
+
+// FindLogContainers finds the active instance's containers with the "name" label matching "containerName"
+// (e.g., "django" or "ghostwriter_django") or all Ghostwriter containers for "all". Stopped containers are
+// included, so their logs can be read after a crash.
+func FindLogContainers(cli *client.Client, containerName string) ([]LogContainer, error) {
+	var found []LogContainer
+	containers, err := cli.ContainerList(context.Background(), client.ContainerListOptions{All: true})
+	if err != nil {
+		return found, err
+	}
+	project := activeComposeProject()
+	for _, item := range containers.Items {
+		if !project.owns(item.Labels) {
+			continue
+		}
+		name := labelContainerName(item.Labels)
+		if !(containerName == "all" || name == containerName || name == "ghostwriter_"+containerName ||
+			logServiceName(name) == containerName) {
+			continue
+		}
+		inspect, err := cli.ContainerInspect(context.Background(), item.ID, client.ContainerInspectOptions{})
+		if err != nil {
+			return found, err
+		}
+		tty := inspect.Container.Config != nil && inspect.Container.Config.Tty
+		running := item.State == container.StateRunning
+		found = append(found, LogContainer{ID: item.ID, Name: name, TTY: tty, Running: running})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, nil
+}
+
+// Retrieve the logs of one container and pass each line to the "emit" function as it is read. The "Filter" option
+// is not applied.
+func streamContainerLogs(ctx context.Context, cli *client.Client, container LogContainer, opts LogOptions, emit func(LogLine)) error {
+	reader, err := cli.ContainerLogs(ctx, container.ID, client.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		Follow:     opts.Follow,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get logs for %s: %w", container.Name, err)
+	}
+	defer reader.Close()
+	// Closing the reader unblocks a follow when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { reader.Close() })
+	defer stop()
+	if err := readLogStream(reader, container.Name, container.TTY, emit); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs for %s: %w", container.Name, err)
+	}
+	return nil
+}
+
+// StreamLogs retrieves the logs of the containers and passes each line to the "handle" function. Lines from
+// several containers are merged in chronological order. With the "Follow" option, this blocks until the
+// context is cancelled or every container stops.
+func StreamLogs(ctx context.Context, cli *client.Client, containers []LogContainer, opts LogOptions, handle func(LogLine)) error {
+	merger := &logMerger{emit: handle}
+	var collected []LogLine
+	var collectedMutex sync.Mutex
+	emit := func(line LogLine) {
+		if opts.Filter != nil && !opts.Filter(line) {
+			return
+		}
+		if opts.Follow {
+			merger.add(line)
+			return
+		}
+		collectedMutex.Lock()
+		collected = append(collected, line)
+		collectedMutex.Unlock()
+	}
+
+	// Release the merged lines periodically while following
+	done := make(chan struct{})
+	var releaser sync.WaitGroup
+	if opts.Follow {
+		releaser.Add(1)
+		go func() {
+			defer releaser.Done()
+			ticker := time.NewTicker(logMergeWindow / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					merger.release(time.Now().Add(-logMergeWindow))
+				}
+			}
+		}()
+	}
+
+	var wait sync.WaitGroup
+	errs := make([]error, len(containers))
+	for i, container := range containers {
+		wait.Add(1)
+		go func(i int, container LogContainer) {
+			defer wait.Done()
+			errs[i] = streamContainerLogs(ctx, cli, container, opts, emit)
+		}(i, container)
+	}
+	wait.Wait()
+	close(done)
+	releaser.Wait()
+
+	if opts.Follow {
+		merger.release(time.Time{})
+	} else {
+		sortLogLines(collected)
+		for _, line := range collected {
+			handle(line)
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchLogs fetches the last lines ("lines" parameter) of the logs from the container with the specified "name"
+// label ("containerName" parameter) or all containers for "all". The logs of each container are preceded by a
+// header line.
+func FetchLogs(containerName string, lines string) ([]string, error) {
+	var logs []string
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return logs, err
+	}
+	defer cli.Close()
+
+	containers, err := FindLogContainers(cli, containerName)
+	if err != nil {
+		return logs, err
+	}
+	for _, container := range containers {
+		logs = append(logs, fmt.Sprintf("\n*** Logs for `%s` ***\n\n", container.Name))
+		err := StreamLogs(context.Background(), cli, []LogContainer{container}, LogOptions{Tail: lines}, func(line LogLine) {
+			logs = append(logs, line.Text+"\n")
+		})
+		if err != nil {
+			return logs, err
+		}
+	}
+	if len(containers) == 0 {
+		logs = append(logs, fmt.Sprintf("\n*** No logs found for requested container '%s' ***\n", containerName))
+	}
+	return logs, nil
+}
+
+// FindServiceContainers finds the containers of the services (e.g., "django" or "all") with "FindLogContainers()".
+// Every service must have a container, and containers matched by more than one service are only included once.
+func FindServiceContainers(cli *client.Client, services []string) ([]LogContainer, error) {
+	var containers []LogContainer
+	seen := make(map[string]bool)
+	for _, service := range services {
+		found, err := FindLogContainers(cli, service)
+		if err != nil {
+			return containers, err
+		}
+		if len(found) == 0 {
+			if service == "all" {
+				return containers, fmt.Errorf("no Ghostwriter containers were found")
+			}
+			return containers, fmt.Errorf("no container was found for the %q service (has it been created?)", service)
+		}
+		for _, container := range found {
+			if !seen[container.ID] {
+				seen[container.ID] = true
+				containers = append(containers, container)
+			}
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers, nil
+}
+
+// PrintLogs prints the logs of the containers of the services ("services" parameter, or "all"). Each line is
+// prefixed with the container's name, and the containers' stderr is printed to stderr.
+func PrintLogs(ctx context.Context, services []string, opts LogOptions) error {
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := FindServiceContainers(cli, services)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+	formatter := NewLogFormatter(names, opts.Timestamps, opts.Color)
+	return StreamLogs(ctx, cli, containers, opts, func(line LogLine) {
+		if line.Stream == LogStderr {
+			fmt.Fprintln(os.Stderr, formatter.Format(line))
+		} else {
+			fmt.Fprintln(os.Stdout, formatter.Format(line))
+		}
+	})
+}
+
+// Service names that differ from the suffix of their image
+var logServiceAliases = map[string]string{"collab_server": "collab"}
+
+// Get the service name for a container's "name" label (e.g., "collab" for "ghostwriter_collab_server").
+func logServiceName(label string) string {
+	service := strings.TrimPrefix(label, "ghostwriter_")
+	if alias, ok := logServiceAliases[service]; ok {
+		return alias
+	}
+	return service
+}
+
+// LogServices returns the sorted names of the services for the environment ("dev" parameter), derived from the
+// "name" labels of the services in the environment's Compose file (e.g., "frontend" is only available in
+// development).
+func LogServices(dev bool) []string {
+	project, _ := LoadComposeProject(dev)
+	return logServicesFor(project)
+}
+
+// Get the sorted log names of the project's services, using the service's name for services without a "name" label.
+func logServicesFor(project ComposeProject) []string {
+	var services []string
+	for _, service := range project.Services {
+		label := service.Label
+		if label == "" {
+			label = service.Name
+		}
+		services = append(services, logServiceName(label))
+	}
+	sort.Strings(services)
+	return services
+}
+
+// Compute the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// Find the services that are close to a mistyped name: names within two edits or that start with the name.
+func suggestLogServices(name string, services []string) []string {
+	var suggestions []string
+	for _, service := range services {
+		if editDistance(name, service) <= 2 || (len(name) > 1 && strings.HasPrefix(service, name)) {
+			suggestions = append(suggestions, service)
+		}
+	}
+	return suggestions
+}
+
+// ValidateLogServices checks the names of services ("names" parameter) against the services of the environment
+// ("dev" parameter) and returns the normalized names. Names may include the "ghostwriter_" prefix, and "all"
+// selects every service. Unknown names are reported with the closest matches.
+func ValidateLogServices(names []string, dev bool) ([]string, error) {
+	services := LogServices(dev)
+	environment := "production"
+	otherServices := LogServices(true)
+	if dev {
+		environment = "development"
+		otherServices = LogServices(false)
+	}
+
+	var validated []string
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "ghostwriter_")
+		if name == "all" {
+			return []string{"all"}, nil
+		}
+		if Contains(services, name) {
+			if !Contains(validated, name) {
+				validated = append(validated, name)
+			}
+			continue
+		}
+		if Contains(otherServices, name) {
+			return nil, fmt.Errorf("the %q service is not part of the %s environment", name, environment)
+		}
+		message := fmt.Sprintf("unknown service %q", name)
+		if suggestions := suggestLogServices(name, services); len(suggestions) > 0 {
+			message += fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, " or "))
+		}
+		return nil, fmt.Errorf("%s; valid services for the %s environment are: all, %s", message, environment, strings.Join(services, ", "))
+	}
+	return validated, nil
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a frame of Docker's multiplexed log stream for stdout (1) or stderr (2).
+func logFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestReadLogStream(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(logFrame(1, "2025-01-02T15:04:05.000000001Z Application startup "))
+	stream.Write(logFrame(1, "complete\n2025-01-02T15:04:06Z second line\n"))
+	stream.Write(logFrame(2, "2025-01-02T15:04:05.5Z an error\r\n"))
+	stream.Write(logFrame(1, "2025-01-02T15:04:07Z no newline"))
+
+	var lines []LogLine
+	// Read one byte at a time to make sure short reads don't truncate lines
+	err := readLogStream(iotest.OneByteReader(&stream), "ghostwriter_django", false, func(line LogLine) {
+		lines = append(lines, line)
+	})
+	assert.NoError(t, err, "Expected `readLogStream()` to return no error")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "Application startup complete", lines[0].Text)
+		assert.Equal(t, LogStdout, lines[0].Stream)
+		assert.Equal(t, 1, lines[0].Time.Nanosecond())
+		assert.Equal(t, "second line", lines[1].Text)
+		assert.Equal(t, "an error", lines[2].Text)
+		assert.Equal(t, LogStderr, lines[2].Stream)
+		assert.Equal(t, "no newline", lines[3].Text)
+	}
+
+	// Containers with a TTY don't multiplex their output
+	lines = nil
+	err = readLogStream(bytes.NewBufferString("2025-01-02T15:04:05Z raw output\n"), "ghostwriter_frontend", true, func(line LogLine) {
+		lines = append(lines, line)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []LogLine{{
+		Container: "ghostwriter_frontend",
+		Stream:    LogStdout,
+		Time:      time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC),
+		Text:      "raw output",
+	}}, lines)
+}
+
+func TestStreamLogsFilter(t *testing.T) {
+	// Fake Docker API that returns the logs of two containers with a TTY
+	logs := map[string]string{
+		"django": "2025-01-02T15:04:05Z GET /status/ 200\n2025-01-02T15:04:08Z ERROR failed\n",
+		"queue":  "2025-01-02T15:04:06Z ERROR timeout\n2025-01-02T15:04:07Z task done\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for id, output := range logs {
+			if strings.HasSuffix(r.URL.Path, "/containers/"+id+"/logs") {
+				io.WriteString(w, output)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	cli, err := client.New(client.WithHost("tcp://" + server.Listener.Addr().String()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+
+	containers := []LogContainer{{ID: "django", Name: "ghostwriter_django", TTY: true}, {ID: "queue", Name: "ghostwriter_queue", TTY: true}}
+	opts := LogOptions{Tail: "all", Filter: func(line LogLine) bool {
+		return strings.HasPrefix(line.Text, "ERROR")
+	}}
+	var lines []string
+	err = StreamLogs(context.Background(), cli, containers, opts, func(line LogLine) {
+		lines = append(lines, line.Container+" "+line.Text)
+	})
+	assert.NoError(t, err, "Expected `StreamLogs()` to return no error")
+	assert.Equal(t, []string{"ghostwriter_queue ERROR timeout", "ghostwriter_django ERROR failed"}, lines)
+}
+
+func TestParseLogTimestamp(t *testing.T) {
+	stamp, text := parseLogTimestamp("2025-01-02T15:04:05.123456789Z GET /status/ 200")
+	assert.Equal(t, time.Date(2025, 1, 2, 15, 4, 5, 123456789, time.UTC), stamp)
+	assert.Equal(t, "GET /status/ 200", text)
+
+	stamp, text = parseLogTimestamp("not a timestamp")
+	assert.True(t, stamp.IsZero(), "Expected a zero time without a timestamp")
+	assert.Equal(t, "not a timestamp", text)
+}
+
+func TestLogMerging(t *testing.T) {
+	base := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	lines := []LogLine{
+		{Container: "ghostwriter_django", Time: base.Add(2 * time.Second), Text: "third"},
+		{Container: "ghostwriter_nginx", Time: base, Text: "first"},
+		{Container: "ghostwriter_django", Time: base.Add(time.Second), Text: "second"},
+	}
+	sortLogLines(lines)
+	assert.Equal(t, "first", lines[0].Text)
+	assert.Equal(t, "second", lines[1].Text)
+	assert.Equal(t, "third", lines[2].Text)
+
+	// Lines are held until they are older than the cutoff and released in order
+	var released []string
+	merger := &logMerger{emit: func(line LogLine) { released = append(released, line.Text) }}
+	merger.add(LogLine{Time: base.Add(time.Second), Text: "later"})
+	merger.add(LogLine{Time: base, Text: "earlier"})
+	merger.release(time.Now().Add(-time.Hour))
+	assert.Empty(t, released, "Expected recent lines to be held")
+	merger.release(time.Time{})
+	assert.Equal(t, []string{"earlier", "later"}, released)
+}
+
+func TestLogFormatter(t *testing.T) {
+	line := LogLine{
+		Container: "ghostwriter_nginx",
+		Time:      time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC),
+		Text:      "GET /status/ 200",
+	}
+	formatter := NewLogFormatter([]string{"ghostwriter_nginx", "ghostwriter_django"}, false, false)
+	assert.Equal(t, "ghostwriter_nginx  | GET /status/ 200", formatter.Format(line))
+
+	formatter = NewLogFormatter([]string{"ghostwriter_nginx"}, true, true)
+	assert.Equal(t, "\033[36mghostwriter_nginx |\033[0m 2025-01-02T15:04:05Z GET /status/ 200", formatter.Format(line))
+}
+
+func TestValidateLogServices(t *testing.T) {
+	assert.Equal(t, []string{"collab", "django", "graphql", "nginx", "postgres", "queue", "redis"}, LogServices(false))
+	assert.Contains(t, LogServices(true), "frontend")
+	assert.NotContains(t, LogServices(true), "nginx")
+
+	services, err := ValidateLogServices([]string{"django", "ghostwriter_queue", "Django"}, false)
+	assert.NoError(t, err, "Expected `ValidateLogServices()` to return no error")
+	assert.Equal(t, []string{"django", "queue"}, services)
+
+	services, err = ValidateLogServices([]string{"nginx", "all"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"all"}, services)
+
+	_, err = ValidateLogServices([]string{"frontend"}, false)
+	assert.EqualError(t, err, `the "frontend" service is not part of the production environment`)
+
+	_, err = ValidateLogServices([]string{"djnago"}, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown service "djnago" (did you mean django?)`)
+	}
+	_, err = ValidateLogServices([]string{"post"}, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "did you mean postgres?")
+	}
+
+	assert.Equal(t, "collab", logServiceName("ghostwriter_collab_server"))
+	assert.Equal(t, 2, editDistance("djnago", "django"))
+}
@@ -0,0 +1,407 @@
+package internal
+
+// Functions for parsing the logs of Ghostwriter's services into structured entries and searching them
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/client"
+)
+
+// Formats of log lines recognized by "ParseLogLine()"
+const (
+	LogFormatUvicorn  = "uvicorn"
+	LogFormatDjango   = "django"
+	LogFormatHasura   = "hasura"
+	LogFormatNginx    = "nginx"
+	LogFormatPostgres = "postgres"
+	LogFormatText     = "text"
+)
+
+// Normalized log levels, from least to most severe
+var logLevels = []string{"debug", "info", "warning", "error", "critical"}
+
+var (
+	// INFO:     172.18.0.6:51234 - "GET /status/ HTTP/1.1" 200 OK
+	uvicornAccessPattern = regexp.MustCompile(`^(\w+):\s+(\S+) - "(\S+) (\S+) [^"]*" (\d{3})`)
+	// INFO:     Application startup complete.
+	uvicornPattern = regexp.MustCompile(`^(DEBUG|INFO|WARNING|ERROR|CRITICAL):\s+(.*)$`)
+	// [2025-01-02 15:04:05,123] WARNING [django.request:241] Not Found: /missing/
+	djangoPattern = regexp.MustCompile(`^\[?(?:\d{4}-\d{2}-\d{2}[ T][\d:.,]+\]?)?\s*\[?(DEBUG|INFO|WARNING|ERROR|CRITICAL)\]?\s+(?:\[([\w.]+)(?::\d+)?\]\s+)?(.*)$`)
+	// 172.18.0.1 - - [02/Jan/2025:15:04:05 +0000] "GET /home/ HTTP/1.1" 200 512 "-" "Mozilla/5.0"
+	nginxCombinedPattern = regexp.MustCompile(`^(\S+) - (\S+) \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) (\d+|-) "([^"]*)" "([^"]*)"`)
+	// 2025/01/02 15:04:05 [error] 29#29: *1 connect() failed
+	nginxErrorPattern = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} [\d:]+ \[(\w+)\] (.*)$`)
+	// 2025-01-02 15:04:05.123 UTC [1] LOG:  database system is ready to accept connections
+	postgresPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} [\d:.]+(?: \w+)? \[(\d+)\] (\w+):\s+(.*)$`)
+	// Level keywords in unstructured lines
+	levelKeywordPattern = regexp.MustCompile(`\b(DEBUG|INFO|WARN|WARNING|ERROR|CRITICAL|FATAL|PANIC)\b`)
+)
+
+// LogEntry is a custom type for storing a log line parsed into structured fields.
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Service string            `json:"service"`
+	Stream  string            `json:"stream"`
+	Format  string            `json:"format"`
+	Level   string            `json:"level,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Raw     string            `json:"raw"`
+}
+
+func (e *LogEntry) setField(key string, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	e.Fields[key] = value
+}
+
+// Normalize the log levels of the services (e.g., PostgreSQL's "LOG" and "FATAL" or nginx's "crit").
+func normalizeLogLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "debug", "debug1", "debug2", "debug3", "debug4", "debug5":
+		return "debug"
+	case "info", "log", "notice", "statement", "detail", "hint", "context":
+		return "info"
+	case "warn", "warning":
+		return "warning"
+	case "error", "err":
+		return "error"
+	case "critical", "crit", "fatal", "panic", "alert", "emerg":
+		return "critical"
+	}
+	return ""
+}
+
+// Rank of a normalized level for comparisons (-1 for unknown levels).
+func logLevelRank(level string) int {
+	for i, known := range logLevels {
+		if known == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// Derive a level from an HTTP status code for access logs.
+func statusLogLevel(status int) string {
+	switch {
+	case status >= 500:
+		return "error"
+	case status >= 400:
+		return "warning"
+	}
+	return "info"
+}
+
+// ParseLogLine parses a line from a service's logs into a LogEntry. The parser is chosen by the service's
+// container name, and lines that don't match the service's formats are kept as text with a level guessed from
+// keywords like "ERROR".
+func ParseLogLine(line LogLine) LogEntry {
+	entry := LogEntry{
+		Time:    line.Time,
+		Service: strings.TrimPrefix(line.Container, "ghostwriter_"),
+		Stream:  line.Stream,
+		Format:  LogFormatText,
+		Message: line.Text,
+		Raw:     line.Text,
+	}
+	var parsed bool
+	switch entry.Service {
+	case "django", "queue":
+		parsed = parseUvicornLine(&entry) || parseDjangoLine(&entry)
+	case "graphql":
+		parsed = parseHasuraLine(&entry)
+	case "nginx":
+		parsed = parseNginxLine(&entry)
+	case "postgres":
+		parsed = parsePostgresLine(&entry)
+	}
+	if !parsed {
+		if match := levelKeywordPattern.FindStringSubmatch(line.Text); match != nil {
+			entry.Level = normalizeLogLevel(match[1])
+		}
+	}
+	return entry
+}
+
+// Parse the access log and messages of uvicorn, which serves Django.
+func parseUvicornLine(entry *LogEntry) bool {
+	if match := uvicornAccessPattern.FindStringSubmatch(entry.Raw); match != nil {
+		entry.Format = LogFormatUvicorn
+		entry.Status, _ = strconv.Atoi(match[5])
+		entry.Level = statusLogLevel(entry.Status)
+		entry.Method = match[3]
+		entry.Path = match[4]
+		entry.Message = fmt.Sprintf("%s %s %d", entry.Method, entry.Path, entry.Status)
+		entry.setField("client", match[2])
+		return true
+	}
+	if match := uvicornPattern.FindStringSubmatch(entry.Raw); match != nil {
+		entry.Format = LogFormatUvicorn
+		entry.Level = normalizeLogLevel(match[1])
+		entry.Message = match[2]
+		return true
+	}
+	return false
+}
+
+// Parse the messages of Django's loggers, which start with the level and optionally the time and logger.
+func parseDjangoLine(entry *LogEntry) bool {
+	match := djangoPattern.FindStringSubmatch(entry.Raw)
+	if match == nil {
+		return false
+	}
+	entry.Format = LogFormatDjango
+	entry.Level = normalizeLogLevel(match[1])
+	entry.Message = match[3]
+	entry.setField("logger", match[2])
+	return true
+}
+
+// Parse Hasura's JSON logs. HTTP logs include the request's status, method, and URL in "detail.http_info".
+func parseHasuraLine(entry *LogEntry) bool {
+	var record struct {
+		Type   string          `json:"type"`
+		Level  string          `json:"level"`
+		Detail json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(entry.Raw), &record); err != nil || record.Type == "" {
+		return false
+	}
+	entry.Format = LogFormatHasura
+	entry.Level = normalizeLogLevel(record.Level)
+	entry.setField("type", record.Type)
+
+	var detail struct {
+		HTTPInfo struct {
+			Status int    `json:"status"`
+			Method string `json:"method"`
+			URL    string `json:"url"`
+			IP     string `json:"ip"`
+		} `json:"http_info"`
+		Operation struct {
+			RequestID string `json:"request_id"`
+			Error     *struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			} `json:"error"`
+		} `json:"operation"`
+		Kind string `json:"kind"`
+		Info any    `json:"info"`
+	}
+	if err := json.Unmarshal(record.Detail, &detail); err != nil {
+		// Some details are plain strings
+		var text string
+		if json.Unmarshal(record.Detail, &text) == nil {
+			entry.Message = text
+		}
+		return true
+	}
+	if detail.HTTPInfo.Status != 0 {
+		entry.Status = detail.HTTPInfo.Status
+		entry.Method = detail.HTTPInfo.Method
+		entry.Path = detail.HTTPInfo.URL
+		entry.setField("client", detail.HTTPInfo.IP)
+		entry.setField("request_id", detail.Operation.RequestID)
+		entry.Message = fmt.Sprintf("%s %s %d", entry.Method, entry.Path, entry.Status)
+		if detail.Operation.Error != nil {
+			entry.Message += ": " + detail.Operation.Error.Error
+			entry.setField("error_code", detail.Operation.Error.Code)
+		}
+		return true
+	}
+	switch info := detail.Info.(type) {
+	case string:
+		entry.Message = info
+	case nil:
+		entry.Message = string(record.Detail)
+	default:
+		data, _ := json.Marshal(info)
+		entry.Message = string(data)
+	}
+	if detail.Kind != "" {
+		entry.Message = detail.Kind + ": " + entry.Message
+	}
+	return true
+}
+
+// Parse nginx's combined access log format and error log.
+func parseNginxLine(entry *LogEntry) bool {
+	if match := nginxCombinedPattern.FindStringSubmatch(entry.Raw); match != nil {
+		entry.Format = LogFormatNginx
+		entry.Status, _ = strconv.Atoi(match[6])
+		entry.Level = statusLogLevel(entry.Status)
+		entry.Method = match[4]
+		entry.Path = match[5]
+		entry.Message = fmt.Sprintf("%s %s %d", entry.Method, entry.Path, entry.Status)
+		entry.setField("client", match[1])
+		entry.setField("user", match[2])
+		entry.setField("bytes", match[7])
+		entry.setField("referer", match[8])
+		entry.setField("user_agent", match[9])
+		return true
+	}
+	if match := nginxErrorPattern.FindStringSubmatch(entry.Raw); match != nil {
+		entry.Format = LogFormatNginx
+		entry.Level = normalizeLogLevel(match[1])
+		entry.Message = match[2]
+		return true
+	}
+	return false
+}
+
+// Parse PostgreSQL's default "log_line_prefix" ("%m [%p] ").
+func parsePostgresLine(entry *LogEntry) bool {
+	match := postgresPattern.FindStringSubmatch(entry.Raw)
+	if match == nil {
+		return false
+	}
+	level := normalizeLogLevel(match[2])
+	if level == "" {
+		return false
+	}
+	entry.Format = LogFormatPostgres
+	entry.Level = level
+	entry.Message = match[3]
+	entry.setField("pid", match[1])
+	entry.setField("severity", match[2])
+	return true
+}
+
+// StatusFilter is a custom type for matching HTTP status codes against an exact code (e.g., "404"), a class
+// (e.g., "5xx"), or a comparison (e.g., ">=400").
+type StatusFilter struct {
+	min int
+	max int
+}
+
+// ParseStatusFilter parses a status filter. An empty value matches every entry.
+func ParseStatusFilter(value string) (StatusFilter, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return StatusFilter{}, nil
+	}
+	if len(value) == 3 && strings.HasSuffix(value, "xx") && value[0] >= '1' && value[0] <= '5' {
+		class := int(value[0]-'0') * 100
+		return StatusFilter{min: class, max: class + 99}, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(value, op) {
+			continue
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(value[len(op):]))
+		if err != nil {
+			return StatusFilter{}, fmt.Errorf("invalid status filter %q", value)
+		}
+		switch op {
+		case ">=":
+			return StatusFilter{min: code, max: 999}, nil
+		case "<=":
+			return StatusFilter{min: 100, max: code}, nil
+		case ">":
+			return StatusFilter{min: code + 1, max: 999}, nil
+		default:
+			return StatusFilter{min: 100, max: code - 1}, nil
+		}
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 100 || code > 999 {
+		return StatusFilter{}, fmt.Errorf("invalid status filter %q (use a code like 404, a class like 5xx, or a comparison like >=400)", value)
+	}
+	return StatusFilter{min: code, max: code}, nil
+}
+
+// Match returns true if the status matches the filter. Entries without a status only match an empty filter.
+func (f StatusFilter) Match(status int) bool {
+	if f.min == 0 && f.max == 0 {
+		return true
+	}
+	return status >= f.min && status <= f.max
+}
+
+// LogQuery is a custom type for storing the filters of a log search.
+type LogQuery struct {
+	// Matched against the raw line
+	Pattern *regexp.Regexp
+	// Matched against the request path of access logs
+	Path *regexp.Regexp
+	// Minimum normalized level (e.g., "warning" matches warnings, errors, and critical messages)
+	Level  string
+	Status StatusFilter
+}
+
+// ValidateLogLevel checks a minimum level for a LogQuery and returns the normalized level.
+func ValidateLogLevel(level string) (string, error) {
+	if level == "" {
+		return "", nil
+	}
+	normalized := normalizeLogLevel(level)
+	if normalized == "" {
+		return "", fmt.Errorf("invalid log level %q (use one of %s)", level, strings.Join(logLevels, ", "))
+	}
+	return normalized, nil
+}
+
+// Match returns true if the entry matches every filter of the query.
+func (q LogQuery) Match(entry LogEntry) bool {
+	if q.Pattern != nil && !q.Pattern.MatchString(entry.Raw) {
+		return false
+	}
+	if q.Path != nil && (entry.Path == "" || !q.Path.MatchString(entry.Path)) {
+		return false
+	}
+	if q.Level != "" && logLevelRank(entry.Level) < logLevelRank(q.Level) {
+		return false
+	}
+	return q.Status.Match(entry.Status)
+}
+
+// SearchLogs searches the logs of the containers with the specified "name" labels ("services" parameter, or
+// "all") and passes each matching entry to the "handle" function in chronological order. The "opts" parameter
+// limits the lines and time window that are searched.
+func SearchLogs(ctx context.Context, services []string, opts LogOptions, query LogQuery, handle func(LogEntry)) error {
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := FindServiceContainers(cli, services)
+	if err != nil {
+		return err
+	}
+	// Filter the lines as they are read, so only the matches are held while the logs are sorted
+	opts.Filter = func(line LogLine) bool {
+		return query.Match(ParseLogLine(line))
+	}
+	return StreamLogs(ctx, cli, containers, opts, func(line LogLine) {
+		handle(ParseLogLine(line))
+	})
+}
+
+// FormatLogEntry formats an entry as one line of text with its time, service, and level.
+func FormatLogEntry(entry LogEntry) string {
+	level := strings.ToUpper(entry.Level)
+	if level == "" {
+		level = "-"
+	}
+	stamp := "-"
+	if !entry.Time.IsZero() {
+		stamp = entry.Time.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%s %-9s %-8s %s", stamp, entry.Service, level, entry.Raw)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLine(t *testing.T) {
+	entry := ParseLogLine(LogLine{Container: "ghostwriter_django", Stream: LogStdout, Text: `INFO:     172.18.0.6:51234 - "GET /status/ HTTP/1.1" 503 Service Unavailable`})
+	assert.Equal(t, LogFormatUvicorn, entry.Format)
+	assert.Equal(t, "django", entry.Service)
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "/status/", entry.Path)
+	assert.Equal(t, 503, entry.Status)
+	assert.Equal(t, "error", entry.Level)
+	assert.Equal(t, "172.18.0.6:51234", entry.Fields["client"])
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_django", Text: "[2025-01-02 15:04:05,123] WARNING [django.request:241] Not Found: /missing/"})
+	assert.Equal(t, LogFormatDjango, entry.Format)
+	assert.Equal(t, "warning", entry.Level)
+	assert.Equal(t, "django.request", entry.Fields["logger"])
+	assert.Equal(t, "Not Found: /missing/", entry.Message)
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_graphql", Text: `{"type":"http-log","timestamp":"2025-01-02T15:04:05.000+0000","level":"error","detail":{"operation":{"request_id":"abc","error":{"error":"field not found","code":"validation-failed"}},"http_info":{"status":400,"http_version":"HTTP/1.1","url":"/v1/graphql","ip":"172.18.0.5","method":"POST"}}}`})
+	assert.Equal(t, LogFormatHasura, entry.Format)
+	assert.Equal(t, "error", entry.Level)
+	assert.Equal(t, 400, entry.Status)
+	assert.Equal(t, "/v1/graphql", entry.Path)
+	assert.Equal(t, "POST /v1/graphql 400: field not found", entry.Message)
+	assert.Equal(t, "validation-failed", entry.Fields["error_code"])
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_graphql", Text: `{"type":"startup","timestamp":"2025-01-02T15:04:05.000+0000","level":"info","detail":{"kind":"server","info":"starting API server"}}`})
+	assert.Equal(t, "server: starting API server", entry.Message)
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_nginx", Text: `172.18.0.1 - - [02/Jan/2025:15:04:05 +0000] "GET /home/ HTTP/1.1" 404 512 "-" "Mozilla/5.0"`})
+	assert.Equal(t, LogFormatNginx, entry.Format)
+	assert.Equal(t, 404, entry.Status)
+	assert.Equal(t, "warning", entry.Level)
+	assert.Equal(t, "Mozilla/5.0", entry.Fields["user_agent"])
+	assert.NotContains(t, entry.Fields, "referer")
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_nginx", Text: "2025/01/02 15:04:05 [crit] 29#29: *1 connect() failed"})
+	assert.Equal(t, "critical", entry.Level)
+
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_postgres", Text: `2025-01-02 15:04:05.123 UTC [62] FATAL:  password authentication failed for user "postgres"`})
+	assert.Equal(t, LogFormatPostgres, entry.Format)
+	assert.Equal(t, "critical", entry.Level)
+	assert.Equal(t, "62", entry.Fields["pid"])
+	assert.Equal(t, `password authentication failed for user "postgres"`, entry.Message)
+
+	// Unknown formats keep the text and guess the level
+	entry = ParseLogLine(LogLine{Container: "ghostwriter_redis", Text: "1:M 02 Jan 2025 15:04:05.123 # ERROR writing the RDB file"})
+	assert.Equal(t, LogFormatText, entry.Format)
+	assert.Equal(t, "error", entry.Level)
+}
+
+func TestParseStatusFilter(t *testing.T) {
+	cases := map[string][]int{
+		"":      {0, 200, 500},
+		"404":   {404},
+		"5xx":   {500, 503, 599},
+		">=400": {400, 404, 500},
+		"<300":  {200, 204},
+	}
+	for value, matches := range cases {
+		filter, err := ParseStatusFilter(value)
+		assert.NoError(t, err, "Expected `ParseStatusFilter()` to accept %q", value)
+		for _, status := range matches {
+			assert.True(t, filter.Match(status), "Expected %q to match %d", value, status)
+		}
+	}
+	filter, _ := ParseStatusFilter("5xx")
+	assert.False(t, filter.Match(0), "Expected entries without a status not to match")
+	assert.False(t, filter.Match(499))
+
+	for _, value := range []string{"abc", "6xx", ">=x", "42"} {
+		_, err := ParseStatusFilter(value)
+		assert.Error(t, err, "Expected an error for %q", value)
+	}
+}
+
+func TestLogQueryMatch(t *testing.T) {
+	entry := ParseLogLine(LogLine{Container: "ghostwriter_nginx", Text: `172.18.0.1 - - [02/Jan/2025:15:04:05 +0000] "POST /api/login HTTP/1.1" 500 12 "-" "curl/8.0"`})
+	status, _ := ParseStatusFilter("5xx")
+	level, err := ValidateLogLevel("warn")
+	assert.NoError(t, err)
+	assert.Equal(t, "warning", level)
+
+	assert.True(t, LogQuery{Pattern: regexp.MustCompile("curl"), Path: regexp.MustCompile("^/api/"), Level: level, Status: status}.Match(entry))
+	assert.False(t, LogQuery{Pattern: regexp.MustCompile("wget")}.Match(entry))
+	assert.False(t, LogQuery{Path: regexp.MustCompile("^/home/")}.Match(entry))
+	assert.False(t, LogQuery{Level: "critical"}.Match(entry))
+
+	_, err = ValidateLogLevel("loud")
+	assert.Error(t, err, "Expected an error for an unknown level")
+}
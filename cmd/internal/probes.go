@@ -0,0 +1,467 @@
+package internal
+
+// Functions for probing Ghostwriter's backing services from inside their containers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+const (
+	// Percentage of PostgreSQL's "max_connections" in use that is reported as an issue
+	postgresConnectionsWarn = 80
+	// Percentage of Redis's "maxmemory" in use that is reported as an issue
+	redisMemoryWarn = 90
+	// Port the collab server listens on if the container does not expose one
+	collabDefaultPort = 8000
+	// Maximum number of inconsistent Hasura metadata objects listed in a report
+	hasuraInconsistenciesShown = 3
+)
+
+// ProbeResult is a custom type for storing the result of a probe against one service.
+type ProbeResult struct {
+	Service string
+	Probe   string
+	OK      bool
+	Latency time.Duration
+	Details string
+	Issues  HealthIssues
+}
+
+// ProbeResults is a collection of ProbeResult structs
+type ProbeResults []ProbeResult
+
+// Issues returns the healthcheck issues found by all probes.
+func (r ProbeResults) Issues() HealthIssues {
+	var issues HealthIssues
+	for _, result := range r {
+		issues = append(issues, result.Issues...)
+	}
+	return issues
+}
+
+// Add an issue to the probe's result and mark the probe as failed.
+func (r *ProbeResult) fail(message string) {
+	r.OK = false
+	r.Issues = append(r.Issues, HealthIssue{Type: "Probe", Service: r.Service, Message: message, Latency: r.Latency})
+}
+
+// Output of a command run inside a container.
+type execOutput struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Latency  time.Duration
+}
+
+// Get the combined output of the command for reports.
+func (o execOutput) message() string {
+	message := summarizeHealthOutput(o.Stdout + " " + o.Stderr)
+	if message == "" {
+		message = fmt.Sprintf("exit code %d", o.ExitCode)
+	}
+	return message
+}
+
+// Run a command ("cmd" parameter) inside the container with the "id" and wait for it to finish.
+// The latency covers the whole exec, including starting the process.
+func execInContainer(ctx context.Context, cli *client.Client, id string, env []string, cmd ...string) (execOutput, error) {
+	var output execOutput
+	start := time.Now()
+	exec, err := cli.ExecCreate(ctx, id, client.ExecCreateOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          env,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return output, err
+	}
+	attach, err := cli.ExecAttach(ctx, exec.ID, client.ExecAttachOptions{})
+	if err != nil {
+		return output, err
+	}
+	defer attach.Close()
+
+	// Closing the connection unblocks the copy if the context expires first
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	if _, err = stdcopy.StdCopy(&stdout, &stderr, attach.Reader); err != nil {
+		if ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		return output, err
+	}
+	output.Latency = time.Since(start)
+	output.Stdout = strings.TrimSpace(stdout.String())
+	output.Stderr = strings.TrimSpace(stderr.String())
+
+	inspect, err := cli.ExecInspect(ctx, exec.ID, client.ExecInspectOptions{})
+	if err != nil {
+		return output, err
+	}
+	output.ExitCode = inspect.ExitCode
+	return output, nil
+}
+
+// Get the timeout for each probe from the "healthcheck_timeout" value.
+func probeTimeout() time.Duration {
+	timeout, err := time.ParseDuration(ghostEnv.GetString("healthcheck_timeout"))
+	if err != nil || timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return timeout
+}
+
+// Parse the output of the connection count query ("<connections>|<max_connections>").
+func parsePostgresConnections(output string) (int, int, error) {
+	fields := strings.Split(strings.TrimSpace(output), "|")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected output: %q", output)
+	}
+	connections, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected connection count: %q", fields[0])
+	}
+	maxConnections, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected max_connections: %q", fields[1])
+	}
+	return connections, maxConnections, nil
+}
+
+// Evaluate the output of "pg_isready" ("ready" parameter) and the connection count query ("connections" parameter).
+func evaluatePostgresProbe(service string, ready execOutput, connections execOutput) ProbeResult {
+	result := ProbeResult{Service: service, Probe: "pg_isready", OK: true, Latency: ready.Latency}
+	if ready.ExitCode != 0 {
+		// Exit codes from "pg_isready": 1 rejecting connections, 2 no response, 3 no attempt was made
+		result.fail(fmt.Sprintf("PostgreSQL is not accepting connections: %s", ready.message()))
+		result.Details = "not ready"
+		return result
+	}
+	if connections.ExitCode != 0 {
+		result.fail(fmt.Sprintf("Failed to count PostgreSQL connections: %s", connections.message()))
+		result.Details = "accepting connections"
+		return result
+	}
+	count, maxConnections, err := parsePostgresConnections(connections.Stdout)
+	if err != nil {
+		result.fail(fmt.Sprintf("Failed to count PostgreSQL connections: %v", err))
+		result.Details = "accepting connections"
+		return result
+	}
+	result.Details = fmt.Sprintf("accepting connections (%d of %d connections in use)", count, maxConnections)
+	if maxConnections > 0 && count*100 >= maxConnections*postgresConnectionsWarn {
+		result.fail(fmt.Sprintf("PostgreSQL is using %d of %d connections", count, maxConnections))
+	}
+	return result
+}
+
+// Parse the "key:value" lines of the output of the Redis "INFO" command.
+func parseRedisInfo(output string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[key] = value
+		}
+	}
+	return info
+}
+
+// Evaluate the output of the Redis "PING" ("ping" parameter) and "INFO memory" ("memory" parameter) commands.
+func evaluateRedisProbe(service string, ping execOutput, memory execOutput) ProbeResult {
+	result := ProbeResult{Service: service, Probe: "PING", OK: true, Latency: ping.Latency}
+	if ping.ExitCode != 0 || ping.Stdout != "PONG" {
+		result.fail(fmt.Sprintf("Redis did not respond to PING: %s", ping.message()))
+		result.Details = "no response"
+		return result
+	}
+	result.Details = "PONG"
+	if memory.ExitCode != 0 {
+		result.fail(fmt.Sprintf("Failed to get Redis memory usage: %s", memory.message()))
+		return result
+	}
+	info := parseRedisInfo(memory.Stdout)
+	used, err := strconv.ParseInt(info["used_memory"], 10, 64)
+	if err != nil {
+		result.fail("Failed to get Redis memory usage: \"used_memory\" is missing from the output")
+		return result
+	}
+	maxMemory, _ := strconv.ParseInt(info["maxmemory"], 10, 64)
+	if maxMemory > 0 {
+		result.Details = fmt.Sprintf("PONG (%s of %s memory used)", info["used_memory_human"], info["maxmemory_human"])
+		if used*100 >= maxMemory*redisMemoryWarn {
+			result.fail(fmt.Sprintf("Redis is using %s of its %s memory limit", info["used_memory_human"], info["maxmemory_human"]))
+		}
+	} else {
+		result.Details = fmt.Sprintf("PONG (%s memory used)", info["used_memory_human"])
+	}
+	return result
+}
+
+// Python script run in the Django container to check Hasura over the Compose network. It prints the status and
+// body of "/healthz" and the response to the "get_inconsistent_metadata" metadata API call as JSON.
+const hasuraProbeScript = `
+import json, os, time, urllib.error, urllib.request
+base = os.environ["HASURA_PROBE_URL"]
+timeout = float(os.environ["HASURA_PROBE_TIMEOUT"])
+result = {}
+start = time.monotonic()
+try:
+    with urllib.request.urlopen(base + "/healthz", timeout=timeout) as response:
+        result["status"], result["body"] = response.status, response.read().decode()
+except urllib.error.HTTPError as e:
+    result["status"], result["body"] = e.code, e.read().decode()
+except Exception as e:
+    result["error"] = str(e)
+result["latency_ms"] = (time.monotonic() - start) * 1000
+if "error" not in result:
+    request = urllib.request.Request(
+        base + "/v1/metadata",
+        data=json.dumps({"type": "get_inconsistent_metadata", "args": {}}).encode(),
+        headers={"Content-Type": "application/json", "X-Hasura-Admin-Secret": os.environ["HASURA_PROBE_SECRET"]},
+    )
+    try:
+        with urllib.request.urlopen(request, timeout=timeout) as response:
+            result["metadata"] = json.load(response)
+    except Exception as e:
+        result["metadata_error"] = str(e)
+print(json.dumps(result))
+`
+
+// Output of the Hasura probe script.
+type hasuraProbeOutput struct {
+	Status        int     `json:"status"`
+	Body          string  `json:"body"`
+	Error         string  `json:"error"`
+	LatencyMs     float64 `json:"latency_ms"`
+	MetadataError string  `json:"metadata_error"`
+	Metadata      *struct {
+		IsConsistent        bool `json:"is_consistent"`
+		InconsistentObjects []struct {
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Reason string `json:"reason"`
+		} `json:"inconsistent_objects"`
+	} `json:"metadata"`
+}
+
+// Evaluate the output of the Hasura probe script ("probe" parameter).
+func evaluateHasuraProbe(service string, probe execOutput) ProbeResult {
+	result := ProbeResult{Service: service, Probe: "/healthz", OK: true, Latency: probe.Latency}
+	var output hasuraProbeOutput
+	if probe.ExitCode != 0 || json.Unmarshal([]byte(probe.Stdout), &output) != nil {
+		result.fail(fmt.Sprintf("Failed to run the Hasura probe in the Django container: %s", probe.message()))
+		result.Details = "probe failed"
+		return result
+	}
+	result.Latency = time.Duration(output.LatencyMs * float64(time.Millisecond))
+	if output.Error != "" {
+		result.fail(fmt.Sprintf("Hasura is not reachable: %s", output.Error))
+		result.Details = "unreachable"
+		return result
+	}
+	body := summarizeHealthOutput(output.Body)
+	result.Details = fmt.Sprintf("%d %s", output.Status, body)
+	if output.Status != 200 {
+		result.fail(fmt.Sprintf("Hasura's /healthz endpoint returned %d: %s", output.Status, body))
+		return result
+	}
+
+	switch {
+	case output.MetadataError != "":
+		result.fail(fmt.Sprintf("Failed to check Hasura's metadata: %s", output.MetadataError))
+	case output.Metadata == nil:
+		result.fail("Failed to check Hasura's metadata: the response was empty")
+	case !output.Metadata.IsConsistent:
+		objects := output.Metadata.InconsistentObjects
+		var descriptions []string
+		for i, object := range objects {
+			if i == hasuraInconsistenciesShown {
+				descriptions = append(descriptions, fmt.Sprintf("and %d more", len(objects)-i))
+				break
+			}
+			descriptions = append(descriptions, fmt.Sprintf("%s %s (%s)", object.Type, object.Name, object.Reason))
+		}
+		result.fail(fmt.Sprintf("Hasura has %d inconsistent metadata objects: %s", len(objects), strings.Join(descriptions, "; ")))
+		result.Details += ", inconsistent metadata"
+	default:
+		result.Details += ", metadata is consistent"
+	}
+	return result
+}
+
+// Node.js script run in the collab server container to check that it accepts WebSocket connections. It prints the
+// status line of the response to the upgrade request.
+const collabProbeScript = `
+const net = require("net");
+const port = Number(process.env.COLLAB_PROBE_PORT);
+const socket = net.connect(port, "127.0.0.1", () => socket.write(
+  "GET / HTTP/1.1\r\nHost: localhost:" + port + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+  "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+));
+socket.setTimeout(Number(process.env.COLLAB_PROBE_TIMEOUT), () => { console.log("timed out"); process.exit(2); });
+socket.once("data", (data) => { console.log(data.toString().split("\r\n")[0]); socket.destroy(); });
+socket.on("error", (err) => { console.log(err.message); process.exit(1); });
+`
+
+// Evaluate the output of the collab server probe script ("probe" parameter).
+func evaluateCollabProbe(service string, probe execOutput) ProbeResult {
+	result := ProbeResult{Service: service, Probe: "WebSocket", OK: true, Latency: probe.Latency, Details: probe.message()}
+	fields := strings.Fields(probe.Stdout)
+	if probe.ExitCode != 0 || len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		result.fail(fmt.Sprintf("The collab server did not respond to the WebSocket handshake: %s", probe.message()))
+		return result
+	}
+	if fields[1] != "101" {
+		result.fail(fmt.Sprintf("The collab server rejected the WebSocket handshake: %s", probe.Stdout))
+	}
+	return result
+}
+
+// Find the running container for the Compose service (e.g., "postgres").
+func findRunningContainer(healths ContainerHealths, service string) (ContainerHealth, bool) {
+	for _, health := range healths {
+		if sameComposeService(health.ComposeService, service) && health.State == string(container.StateRunning) {
+			return health, true
+		}
+	}
+	return ContainerHealth{}, false
+}
+
+// Run one probe command and turn errors from Docker into a failed result.
+func runProbe(cli *client.Client, health ContainerHealth, env []string, cmd ...string) (execOutput, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout())
+	defer cancel()
+	return execInContainer(ctx, cli, health.ID, env, cmd...)
+}
+
+// Create a failed result for a probe that could not run.
+func probeError(service string, probe string, err error) ProbeResult {
+	result := ProbeResult{Service: service, Probe: probe}
+	result.fail(fmt.Sprintf("Failed to run the probe: %v", err))
+	result.Details = "probe failed"
+	return result
+}
+
+// Probe PostgreSQL with "pg_isready" and count its connections.
+func probePostgres(cli *client.Client, health ContainerHealth) ProbeResult {
+	user := ghostEnv.GetString("postgres_user")
+	db := ghostEnv.GetString("postgres_db")
+	env := []string{"PGPASSWORD=" + ghostEnv.GetString("postgres_password")}
+	ready, err := runProbe(cli, health, env, "pg_isready", "-U", user, "-d", db)
+	if err != nil {
+		return probeError(health.Service, "pg_isready", err)
+	}
+	var connections execOutput
+	if ready.ExitCode == 0 {
+		connections, err = runProbe(
+			cli, health, env, "psql", "-U", user, "-d", db, "-tAc",
+			"SELECT count(*), current_setting('max_connections') FROM pg_stat_activity",
+		)
+		if err != nil {
+			return probeError(health.Service, "pg_isready", err)
+		}
+	}
+	return evaluatePostgresProbe(health.Service, ready, connections)
+}
+
+// Probe Redis with "PING" and check its memory usage.
+func probeRedis(cli *client.Client, health ContainerHealth) ProbeResult {
+	ping, err := runProbe(cli, health, nil, "redis-cli", "PING")
+	if err != nil {
+		return probeError(health.Service, "PING", err)
+	}
+	var memory execOutput
+	if ping.ExitCode == 0 {
+		memory, err = runProbe(cli, health, nil, "redis-cli", "INFO", "memory")
+		if err != nil {
+			return probeError(health.Service, "PING", err)
+		}
+	}
+	return evaluateRedisProbe(health.Service, ping, memory)
+}
+
+// Probe Hasura's "/healthz" endpoint and metadata from the Django container over the Compose network.
+func probeHasura(cli *client.Client, django ContainerHealth, service string) ProbeResult {
+	env := []string{
+		fmt.Sprintf(
+			"HASURA_PROBE_URL=http://%s:%d",
+			ghostEnv.GetString("hasura_graphql_server_hostname"), ghostEnv.GetInt("hasura_graphql_server_port"),
+		),
+		fmt.Sprintf("HASURA_PROBE_TIMEOUT=%g", probeTimeout().Seconds()),
+		"HASURA_PROBE_SECRET=" + ghostEnv.GetString("hasura_graphql_admin_secret"),
+	}
+	probe, err := runProbe(cli, django, env, "python", "-c", hasuraProbeScript)
+	if err != nil {
+		return probeError(service, "/healthz", err)
+	}
+	return evaluateHasuraProbe(service, probe)
+}
+
+// Probe the collab server with a WebSocket handshake from inside its container.
+func probeCollab(cli *client.Client, health ContainerHealth) ProbeResult {
+	port := collabDefaultPort
+	if len(health.Ports) > 0 {
+		port = int(health.Ports[0])
+	}
+	env := []string{
+		fmt.Sprintf("COLLAB_PROBE_PORT=%d", port),
+		fmt.Sprintf("COLLAB_PROBE_TIMEOUT=%d", probeTimeout().Milliseconds()),
+	}
+	probe, err := runProbe(cli, health, env, "node", "-e", collabProbeScript)
+	if err != nil {
+		return probeError(health.Service, "WebSocket", err)
+	}
+	return evaluateCollabProbe(health.Service, probe)
+}
+
+// ProbeServices probes PostgreSQL, Redis, Hasura, and the collab server in their running containers
+// ("healths" parameter from "InspectContainers()"). Services without a running container are skipped
+// because "ContainerIssues()" already reports them.
+func ProbeServices(healths ContainerHealths) (ProbeResults, error) {
+	var results ProbeResults
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return results, err
+	}
+	defer cli.Close()
+
+	if postgres, ok := findRunningContainer(healths, "postgres"); ok {
+		results = append(results, probePostgres(cli, postgres))
+	}
+	if redis, ok := findRunningContainer(healths, "redis"); ok {
+		results = append(results, probeRedis(cli, redis))
+	}
+	if graphql, ok := findRunningContainer(healths, "graphql_engine"); ok {
+		if django, ok := findRunningContainer(healths, "django"); ok {
+			results = append(results, probeHasura(cli, django, graphql.Service))
+		}
+	}
+	if collab, ok := findRunningContainer(healths, "collab-server"); ok {
+		results = append(results, probeCollab(cli, collab))
+	}
+	return results, nil
+}
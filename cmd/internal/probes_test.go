@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresProbe(t *testing.T) {
+	ready := execOutput{Stdout: "/var/run/postgresql:5432 - accepting connections", Latency: 40 * time.Millisecond}
+
+	result := evaluatePostgresProbe("POSTGRES", ready, execOutput{Stdout: "12|100"})
+	assert.True(t, result.OK, "Expected the probe to pass")
+	assert.Empty(t, result.Issues)
+	assert.Equal(t, "accepting connections (12 of 100 connections in use)", result.Details)
+	assert.Equal(t, 40*time.Millisecond, result.Latency)
+
+	result = evaluatePostgresProbe("POSTGRES", ready, execOutput{Stdout: "85|100"})
+	assert.False(t, result.OK, "Expected the probe to fail when most connections are in use")
+	if assert.Len(t, result.Issues, 1) {
+		assert.Equal(t, "PostgreSQL is using 85 of 100 connections", result.Issues[0].Message)
+		assert.Equal(t, 40*time.Millisecond, result.Issues[0].Latency)
+	}
+
+	result = evaluatePostgresProbe("POSTGRES", execOutput{Stdout: "/var/run/postgresql:5432 - no response", ExitCode: 2}, execOutput{})
+	assert.False(t, result.OK, "Expected the probe to fail when PostgreSQL is not ready")
+	assert.Len(t, result.Issues, 1)
+
+	_, _, err := parsePostgresConnections("psql: error")
+	assert.Error(t, err, "Expected an error for unexpected output")
+}
+
+func TestRedisProbe(t *testing.T) {
+	ping := execOutput{Stdout: "PONG", Latency: 5 * time.Millisecond}
+	memory := "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmaxmemory:0\r\nmaxmemory_human:0B\r\n"
+
+	result := evaluateRedisProbe("REDIS", ping, execOutput{Stdout: memory})
+	assert.True(t, result.OK, "Expected the probe to pass")
+	assert.Equal(t, "PONG (1.00M memory used)", result.Details)
+
+	limited := "used_memory:1900000\nused_memory_human:1.81M\nmaxmemory:2000000\nmaxmemory_human:1.91M\n"
+	result = evaluateRedisProbe("REDIS", ping, execOutput{Stdout: limited})
+	assert.False(t, result.OK, "Expected the probe to fail near the memory limit")
+	if assert.Len(t, result.Issues, 1) {
+		assert.Equal(t, "Redis is using 1.81M of its 1.91M memory limit", result.Issues[0].Message)
+	}
+
+	result = evaluateRedisProbe("REDIS", execOutput{Stderr: "Could not connect to Redis at 127.0.0.1:6379: Connection refused", ExitCode: 1}, execOutput{})
+	assert.False(t, result.OK, "Expected the probe to fail without a PONG")
+	if assert.Len(t, result.Issues, 1) {
+		assert.Equal(t, "Redis did not respond to PING: Could not connect to Redis at 127.0.0.1:6379: Connection refused", result.Issues[0].Message)
+	}
+}
+
+func TestHasuraProbe(t *testing.T) {
+	result := evaluateHasuraProbe("GRAPHQL", execOutput{Stdout: `{"status": 200, "body": "OK", "latency_ms": 12.5, "metadata": {"is_consistent": true, "inconsistent_objects": []}}`})
+	assert.True(t, result.OK, "Expected the probe to pass")
+	assert.Equal(t, 12500*time.Microsecond, result.Latency)
+	assert.Equal(t, "200 OK, metadata is consistent", result.Details)
+
+	inconsistent := `{"status": 200, "body": "WARN: inconsistent objects in schema", "latency_ms": 3, "metadata": {"is_consistent": false,
+		"inconsistent_objects": [{"type": "table", "name": "public.reporting_report", "reason": "no such table"}]}}`
+	result = evaluateHasuraProbe("GRAPHQL", execOutput{Stdout: inconsistent})
+	assert.False(t, result.OK, "Expected the probe to fail with inconsistent metadata")
+	if assert.Len(t, result.Issues, 1) {
+		assert.Equal(t, "Hasura has 1 inconsistent metadata objects: table public.reporting_report (no such table)", result.Issues[0].Message)
+	}
+
+	result = evaluateHasuraProbe("GRAPHQL", execOutput{Stdout: `{"error": "<urlopen error [Errno 111] Connection refused>", "latency_ms": 1}`})
+	assert.False(t, result.OK, "Expected the probe to fail when Hasura is unreachable")
+	assert.Equal(t, "unreachable", result.Details)
+
+	result = evaluateHasuraProbe("GRAPHQL", execOutput{Stderr: "python: not found", ExitCode: 127})
+	assert.False(t, result.OK, "Expected the probe to fail when the script fails")
+}
+
+func TestCollabProbe(t *testing.T) {
+	result := evaluateCollabProbe("SERVER", execOutput{Stdout: "HTTP/1.1 101 Switching Protocols", Latency: time.Millisecond})
+	assert.True(t, result.OK, "Expected the probe to pass")
+	assert.Empty(t, result.Issues)
+
+	result = evaluateCollabProbe("SERVER", execOutput{Stdout: "HTTP/1.1 400 Bad Request"})
+	assert.False(t, result.OK, "Expected the probe to fail when the upgrade is rejected")
+
+	result = evaluateCollabProbe("SERVER", execOutput{Stdout: "connect ECONNREFUSED 127.0.0.1:8000", ExitCode: 1})
+	assert.False(t, result.OK, "Expected the probe to fail when the connection is refused")
+	if assert.Len(t, result.Issues, 1) {
+		assert.Equal(t, "The collab server did not respond to the WebSocket handshake: connect ECONNREFUSED 127.0.0.1:8000", result.Issues[0].Message)
+	}
+}
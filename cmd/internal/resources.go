@@ -0,0 +1,272 @@
+package internal
+
+// Functions for checking the disk space and memory of the host running Ghostwriter's containers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// DiskUsage is a custom type for storing the usage of the filesystem that holds a path in a container.
+type DiskUsage struct {
+	Name      string
+	Path      string
+	Total     int64
+	Used      int64
+	Available int64
+}
+
+// UsedPercent returns the percentage of the filesystem in use like "df" (used space compared to the space
+// available to unprivileged users).
+func (d DiskUsage) UsedPercent() float64 {
+	if d.Used+d.Available <= 0 {
+		return 0
+	}
+	return float64(d.Used) * 100 / float64(d.Used+d.Available)
+}
+
+// MemoryUsage is a custom type for storing the host's memory from "/proc/meminfo".
+type MemoryUsage struct {
+	Total     int64
+	Available int64
+}
+
+// HostResources is a custom type for storing the disk and memory usage of the host running the containers.
+type HostResources struct {
+	DockerRootDir string
+	Disks         []DiskUsage
+	Memory        MemoryUsage
+}
+
+// FormatBytes formats a number of bytes with binary units (e.g., "1.5 GiB").
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// Parse the output of "df -Pk" into the usage of each path. The paths are matched to the lines in order
+// because "df" prints one line per argument.
+func parseDF(output string, paths []string) ([]DiskUsage, error) {
+	var usages []DiskUsage
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("unexpected output from df: %q", output)
+	}
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("unexpected line from df: %q", line)
+		}
+		var values [3]int64
+		for j := range values {
+			value, err := strconv.ParseInt(fields[j+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected line from df: %q", line)
+			}
+			values[j] = value * 1024
+		}
+		path := fields[len(fields)-1]
+		if i < len(paths) {
+			path = paths[i]
+		}
+		usages = append(usages, DiskUsage{Path: path, Total: values[0], Used: values[1], Available: values[2]})
+	}
+	return usages, nil
+}
+
+// Parse the total and available memory from "/proc/meminfo".
+func parseMeminfo(output string) (MemoryUsage, error) {
+	var memory MemoryUsage
+	values := make(map[string]int64)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		number, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[key] = number * 1024
+	}
+	total, ok := values["MemTotal"]
+	if !ok {
+		return memory, fmt.Errorf("MemTotal is missing from /proc/meminfo")
+	}
+	memory.Total = total
+	if available, ok := values["MemAvailable"]; ok {
+		memory.Available = available
+	} else {
+		// Kernels before 3.14 don't report "MemAvailable"
+		memory.Available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return memory, nil
+}
+
+// Get the disk usage threshold (percent) from the "healthcheck_disk_usage_max" value.
+func diskUsageMax() float64 {
+	threshold := ghostEnv.GetFloat64("healthcheck_disk_usage_max")
+	if threshold <= 0 {
+		threshold = 90
+	}
+	return threshold
+}
+
+// Get the minimum available memory (MB) from the "healthcheck_mem_min" value.
+func memoryMin() int64 {
+	threshold := ghostEnv.GetInt64("healthcheck_mem_min")
+	if threshold <= 0 {
+		threshold = 100
+	}
+	return threshold
+}
+
+// Issues returns the healthcheck issues for filesystems above the "healthcheck_disk_usage_max" percentage
+// and available memory below the "healthcheck_mem_min" megabytes, the same thresholds Django's health checks use.
+func (r HostResources) Issues() HealthIssues {
+	var issues HealthIssues
+	maxUsage := diskUsageMax()
+	for _, disk := range r.Disks {
+		if disk.UsedPercent() >= maxUsage {
+			issues = append(issues, HealthIssue{
+				Type:    "Resources",
+				Service: "HOST",
+				Message: fmt.Sprintf(
+					"%s is %.0f%% full (%s available), above the %.0f%% limit",
+					disk.Name, disk.UsedPercent(), FormatBytes(disk.Available), maxUsage,
+				),
+			})
+		}
+	}
+	minMemory := memoryMin()
+	if r.Memory.Total > 0 && r.Memory.Available < minMemory*1024*1024 {
+		issues = append(issues, HealthIssue{
+			Type:    "Resources",
+			Service: "HOST",
+			Message: fmt.Sprintf(
+				"Only %s of %s memory is available, below the %d MB minimum",
+				FormatBytes(r.Memory.Available), FormatBytes(r.Memory.Total), minMemory,
+			),
+		})
+	}
+	return issues
+}
+
+// Find a running container for checking the host's resources, preferring PostgreSQL.
+func resourceContainer(healths ContainerHealths) (ContainerHealth, bool) {
+	if postgres, ok := findRunningContainer(healths, "postgres"); ok {
+		return postgres, true
+	}
+	for _, health := range healths {
+		if health.State == string(container.StateRunning) {
+			return health, true
+		}
+	}
+	return ContainerHealth{}, false
+}
+
+// Run "df" in the container for the paths.
+func containerDiskUsage(cli *client.Client, health ContainerHealth, paths []string) ([]DiskUsage, error) {
+	output, err := runProbe(cli, health, nil, append([]string{"df", "-Pk"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	if output.ExitCode != 0 {
+		return nil, fmt.Errorf("df failed in %s: %s", health.Name, output.message())
+	}
+	return parseDF(output.Stdout, paths)
+}
+
+// CheckHostResources checks the disk usage of the Docker data root and every Ghostwriter volume and the host's
+// available memory. Docker Desktop and remote daemons run containers on another machine, so the checks run inside
+// the running containers ("healths" parameter from "InspectContainers()"). The root filesystem of a container
+// is on the Docker data root, so its free space is the daemon's free space.
+func CheckHostResources(healths ContainerHealths) (HostResources, error) {
+	var resources HostResources
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return resources, err
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(context.Background(), client.InfoOptions{})
+	if err != nil {
+		return resources, err
+	}
+	resources.DockerRootDir = info.Info.DockerRootDir
+
+	root, ok := resourceContainer(healths)
+	if !ok {
+		return resources, fmt.Errorf("no Ghostwriter containers are running")
+	}
+	rootUsage, err := containerDiskUsage(cli, root, []string{"/"})
+	if err != nil {
+		return resources, err
+	}
+	rootUsage[0].Name = "Docker data root"
+	if resources.DockerRootDir != "" {
+		rootUsage[0].Name += " (" + resources.DockerRootDir + ")"
+	}
+	resources.Disks = append(resources.Disks, rootUsage[0])
+
+	// Check each volume once in the first running container that mounts it
+	seen := make(map[string]bool)
+	var volumes []DiskUsage
+	for _, health := range healths {
+		if health.State != string(container.StateRunning) {
+			continue
+		}
+		var mounts []VolumeMount
+		for _, volume := range health.Volumes {
+			if !seen[volume.Name] {
+				seen[volume.Name] = true
+				mounts = append(mounts, volume)
+			}
+		}
+		if len(mounts) == 0 {
+			continue
+		}
+		var paths []string
+		for _, volume := range mounts {
+			paths = append(paths, volume.Destination)
+		}
+		usages, err := containerDiskUsage(cli, health, paths)
+		if err != nil {
+			return resources, err
+		}
+		for i, usage := range usages {
+			usage.Name = "Volume " + mounts[i].Name
+			volumes = append(volumes, usage)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	resources.Disks = append(resources.Disks, volumes...)
+
+	meminfo, err := runProbe(cli, root, nil, "cat", "/proc/meminfo")
+	if err != nil {
+		return resources, err
+	}
+	if meminfo.ExitCode != 0 {
+		return resources, fmt.Errorf("failed to read /proc/meminfo in %s: %s", root.Name, meminfo.message())
+	}
+	resources.Memory, err = parseMeminfo(meminfo.Stdout)
+	return resources, err
+}
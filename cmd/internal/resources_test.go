@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDF(t *testing.T) {
+	output := `Filesystem     1024-blocks      Used Available Capacity Mounted on
+overlay          102687672  92418904   5009504      95% /
+/dev/sda1        102687672  92418904   5009504      95% /var/lib/postgresql/data
+`
+	usages, err := parseDF(output, []string{"/", "/var/lib/postgresql/data"})
+	assert.NoError(t, err, "Expected `parseDF()` to return no error")
+	if assert.Len(t, usages, 2) {
+		assert.Equal(t, "/var/lib/postgresql/data", usages[1].Path)
+		assert.Equal(t, int64(102687672*1024), usages[0].Total)
+		assert.Equal(t, int64(5009504*1024), usages[0].Available)
+		assert.InDelta(t, 94.86, usages[0].UsedPercent(), 0.01)
+	}
+
+	_, err = parseDF("df: /missing: No such file or directory", []string{"/missing"})
+	assert.Error(t, err, "Expected an error for unexpected output")
+}
+
+func TestParseMeminfo(t *testing.T) {
+	memory, err := parseMeminfo("MemTotal:        8048576 kB\nMemFree:          204800 kB\nMemAvailable:    1024000 kB\n")
+	assert.NoError(t, err, "Expected `parseMeminfo()` to return no error")
+	assert.Equal(t, int64(8048576*1024), memory.Total)
+	assert.Equal(t, int64(1024000*1024), memory.Available)
+
+	// Older kernels without "MemAvailable"
+	memory, err = parseMeminfo("MemTotal: 1000 kB\nMemFree: 100 kB\nBuffers: 10 kB\nCached: 50 kB\n")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(160*1024), memory.Available)
+
+	_, err = parseMeminfo("")
+	assert.Error(t, err, "Expected an error without MemTotal")
+}
+
+func TestHostResourceIssues(t *testing.T) {
+	ParseGhostwriterEnvironmentVariables()
+	defer ParseGhostwriterEnvironmentVariables()
+	gib := int64(1024 * 1024 * 1024)
+	resources := HostResources{
+		Disks: []DiskUsage{
+			{Name: "Docker data root (/var/lib/docker)", Total: 100 * gib, Used: 95 * gib, Available: 5 * gib},
+			{Name: "Volume ghostwriter_production_data", Total: 100 * gib, Used: 50 * gib, Available: 50 * gib},
+		},
+		Memory: MemoryUsage{Total: 8 * gib, Available: 64 * 1024 * 1024},
+	}
+	issues := resources.Issues()
+	if assert.Len(t, issues, 2) {
+		assert.Equal(t, "Docker data root (/var/lib/docker) is 95% full (5.0 GiB available), above the 90% limit", issues[0].Message)
+		assert.Equal(t, "Only 64.0 MiB of 8.0 GiB memory is available, below the 100 MB minimum", issues[1].Message)
+	}
+
+	// The thresholds come from the environment
+	ghostEnv.Set("healthcheck_disk_usage_max", 96)
+	ghostEnv.Set("healthcheck_mem_min", 50)
+	assert.Empty(t, resources.Issues())
+
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+}
@@ -0,0 +1,178 @@
+package internal
+
+// Functions for mapping configuration values to the Ghostwriter services that consume them
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/moby/moby/client"
+)
+
+// Services that consume specific environment variables and don't follow the prefix rules below
+var serviceKeyOverrides = map[string][]string{
+	"django_port":                  {"django", "nginx"},
+	"hasura_graphql_action_secret": {"graphql_engine", "django", "queue"},
+	"hasura_graphql_admin_secret":  {"graphql_engine", "django", "queue"},
+	"hasura_graphql_server_host":   {"django", "queue"},
+	"hasura_graphql_server_port":   {"graphql_engine", "django", "queue"},
+	"postgres_conn_max_age":        {"django", "queue"},
+	"use_docker":                   {"django", "queue"},
+	"ipythondir":                   {"django", "queue"},
+	// Only read by the CLI
+	"healthcheck_cert_expiry_days": {},
+}
+
+// Prefixes of environment variables mapped to the services that consume them
+var serviceKeyPrefixes = []struct {
+	prefix   string
+	services []string
+}{
+	{"django_", []string{"django", "queue"}},
+	{"hasura_", []string{"graphql_engine"}},
+	{"postgres_", []string{"postgres", "django", "queue", "graphql_engine"}},
+	{"redis_", []string{"redis", "django", "queue"}},
+	{"nginx_", []string{"nginx"}},
+	{"healthcheck_", []string{"django"}},
+}
+
+// ServicesForKey returns the Compose services that consume the environment variable ("key" parameter).
+func ServicesForKey(key string) []string {
+	key = strings.ToLower(key)
+	if services, ok := serviceKeyOverrides[key]; ok {
+		return services
+	}
+	for _, p := range serviceKeyPrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.services
+		}
+	}
+	return nil
+}
+
+// ServicesForKeys returns the sorted and de-duplicated list of Compose services that consume
+// any of the environment variables ("keys" parameter).
+func ServicesForKeys(keys []string) []string {
+	var services []string
+	for _, key := range keys {
+		for _, service := range ServicesForKey(key) {
+			if !Contains(services, service) {
+				services = append(services, service)
+			}
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// PendingChange is a custom type for storing a configuration value that differs between the
+// ".env" file and a running container.
+type PendingChange struct {
+	Service    string
+	Key        string
+	Running    string
+	Configured string
+}
+
+// PendingChanges is a collection of PendingChange structs
+type PendingChanges []PendingChange
+
+// Len returns the length of a PendingChanges struct
+func (c PendingChanges) Len() int {
+	return len(c)
+}
+
+// Less determines if one PendingChange is less than another PendingChange
+func (c PendingChanges) Less(i, j int) bool {
+	if c[i].Service != c[j].Service {
+		return c[i].Service < c[j].Service
+	}
+	return c[i].Key < c[j].Key
+}
+
+// Swap exchanges the position of two PendingChange values in a PendingChanges struct
+func (c PendingChanges) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// Get the IDs of the active instance's running Ghostwriter containers keyed by their Compose service names.
+func getRunningServiceContainers(ctx context.Context, cli *client.Client) (map[string]string, error) {
+	services := make(map[string]string)
+	containers, err := cli.ContainerList(ctx, client.ContainerListOptions{All: false})
+	if err != nil {
+		return services, err
+	}
+	project := activeComposeProject()
+	for _, container := range containers.Items {
+		if project.owns(container.Labels) {
+			services[container.Labels[composeServiceLabel]] = container.ID
+		}
+	}
+	return services, nil
+}
+
+// GetRunningServices returns the sorted Compose service names of the active instance's running Ghostwriter containers.
+func GetRunningServices() ([]string, error) {
+	var names []string
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return names, err
+	}
+	defer cli.Close()
+	services, err := getRunningServiceContainers(context.Background(), cli)
+	if err != nil {
+		return names, err
+	}
+	for service := range services {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetPendingChanges compares the environment of every running Ghostwriter container with the ".env" file
+// and returns the values that changed since the containers started.
+func GetPendingChanges() (PendingChanges, error) {
+	var pending PendingChanges
+
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return pending, err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	services, err := getRunningServiceContainers(ctx, cli)
+	if err != nil {
+		return pending, err
+	}
+
+	configured := make(map[string]string)
+	for _, setting := range GetConfigAll() {
+		configured[setting.Key] = setting.Val
+	}
+
+	for service, id := range services {
+		inspect, err := cli.ContainerInspect(ctx, id, client.ContainerInspectOptions{})
+		if err != nil {
+			return pending, err
+		}
+		if inspect.Container.Config == nil {
+			continue
+		}
+		for _, variable := range inspect.Container.Config.Env {
+			key, running, found := strings.Cut(variable, "=")
+			if !found {
+				continue
+			}
+			if val, ok := configured[strings.ToUpper(key)]; ok && val != running {
+				pending = append(pending, PendingChange{service, strings.ToUpper(key), running, val})
+			}
+		}
+	}
+
+	sort.Sort(pending)
+
+	return pending, nil
+}
@@ -0,0 +1,156 @@
+package internal
+
+// Functions for collecting the resource usage of Ghostwriter's containers from Docker's stats API
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// ContainerStats is a custom type for storing the resource usage of a container, calculated like "docker stats".
+type ContainerStats struct {
+	CPUPercent    float64
+	MemoryUsage   uint64
+	MemoryLimit   uint64
+	MemoryPercent float64
+	NetworkRx     uint64
+	NetworkTx     uint64
+	BlockRead     uint64
+	BlockWrite    uint64
+	PIDs          uint64
+}
+
+// RunningContainer is a custom type for storing a running container with its health and, optionally, its
+// resource usage.
+type RunningContainer struct {
+	Container
+	Health       string
+	RestartCount int
+	Stats        *ContainerStats
+	StatsError   string
+}
+
+// Calculate the CPU usage as a percentage of one CPU, so a container using two CPUs fully is at 200%.
+func cpuPercent(stats container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// Calculate the memory in use without the page cache, which the kernel can reclaim. Docker reports the cache as
+// "total_inactive_file" with cgroup v1 and "inactive_file" with cgroup v2.
+func memoryUsage(stats container.MemoryStats) uint64 {
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if cache, ok := stats.Stats[key]; ok && cache < stats.Usage {
+			return stats.Usage - cache
+		}
+	}
+	return stats.Usage
+}
+
+// Sum the bytes read and written by the container's block devices.
+func blockIO(stats container.BlkioStats) (uint64, uint64) {
+	var read, write uint64
+	for _, entry := range stats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
+
+// Calculate the resource usage from a sample of Docker's stats API.
+func calculateStats(stats container.StatsResponse) ContainerStats {
+	result := ContainerStats{
+		CPUPercent:  cpuPercent(stats),
+		MemoryUsage: memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+		PIDs:        stats.PidsStats.Current,
+	}
+	if result.MemoryLimit > 0 {
+		result.MemoryPercent = float64(result.MemoryUsage) / float64(result.MemoryLimit) * 100
+	}
+	for _, network := range stats.Networks {
+		result.NetworkRx += network.RxBytes
+		result.NetworkTx += network.TxBytes
+	}
+	result.BlockRead, result.BlockWrite = blockIO(stats.BlkioStats)
+	return result
+}
+
+// Get the resource usage of a container. Docker takes two samples a second apart to calculate the CPU usage.
+func getContainerStats(ctx context.Context, cli *client.Client, id string) (ContainerStats, error) {
+	result, err := cli.ContainerStats(ctx, id, client.ContainerStatsOptions{IncludePreviousSample: true})
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	defer result.Body.Close()
+	var stats container.StatsResponse
+	if err := json.NewDecoder(result.Body).Decode(&stats); err != nil {
+		return ContainerStats{}, fmt.Errorf("failed to read the stats: %w", err)
+	}
+	return calculateStats(stats), nil
+}
+
+// DescribeRunning returns the running Ghostwriter containers with their health state and restart count. With
+// "withStats", the resource usage of the containers is collected in parallel. A container whose stats can't be
+// collected is reported with the error instead of failing the list.
+func DescribeRunning(withStats bool) ([]RunningContainer, error) {
+	var running []RunningContainer
+	healths, err := InspectContainers()
+	if err != nil {
+		return running, err
+	}
+	byID := make(map[string]ContainerHealth)
+	for _, health := range healths {
+		byID[health.ID] = health
+	}
+	containers, err := GetRunning()
+	if err != nil {
+		return running, err
+	}
+	for _, item := range containers {
+		health := byID[item.ID]
+		running = append(running, RunningContainer{Container: item, Health: health.Health, RestartCount: health.RestartCount})
+	}
+	if !withStats || len(running) == 0 {
+		return running, nil
+	}
+
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return running, err
+	}
+	defer cli.Close()
+	var wait sync.WaitGroup
+	for i := range running {
+		wait.Add(1)
+		go func(item *RunningContainer) {
+			defer wait.Done()
+			stats, err := getContainerStats(context.Background(), cli, item.ID)
+			if err != nil {
+				item.StatsError = err.Error()
+				return
+			}
+			item.Stats = &stats
+		}(&running[i])
+	}
+	wait.Wait()
+	return running, nil
+}
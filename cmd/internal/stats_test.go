@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateStats(t *testing.T) {
+	var stats container.StatsResponse
+	stats.CPUStats.CPUUsage.TotalUsage = 3_000_000
+	stats.CPUStats.SystemUsage = 20_000_000
+	stats.CPUStats.OnlineCPUs = 2
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1_000_000
+	stats.PreCPUStats.SystemUsage = 10_000_000
+	stats.MemoryStats.Usage = 300
+	stats.MemoryStats.Limit = 1000
+	stats.MemoryStats.Stats = map[string]uint64{"inactive_file": 100}
+	stats.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: 10, TxBytes: 20},
+		"eth1": {RxBytes: 5, TxBytes: 5},
+	}
+	stats.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{
+		{Op: "Read", Value: 40},
+		{Op: "write", Value: 60},
+		{Op: "Total", Value: 100},
+	}
+	stats.PidsStats.Current = 7
+
+	result := calculateStats(stats)
+	assert.InDelta(t, 40.0, result.CPUPercent, 0.001, "Expected the CPU delta to be scaled by the online CPUs")
+	assert.Equal(t, uint64(200), result.MemoryUsage, "Expected the page cache to be subtracted from the memory usage")
+	assert.Equal(t, uint64(1000), result.MemoryLimit)
+	assert.InDelta(t, 20.0, result.MemoryPercent, 0.001)
+	assert.Equal(t, uint64(15), result.NetworkRx)
+	assert.Equal(t, uint64(25), result.NetworkTx)
+	assert.Equal(t, uint64(40), result.BlockRead)
+	assert.Equal(t, uint64(60), result.BlockWrite)
+	assert.Equal(t, uint64(7), result.PIDs)
+
+	// The first sample has no previous sample to compare with
+	assert.Equal(t, 0.0, cpuPercent(container.StatsResponse{}))
+}
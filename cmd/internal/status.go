@@ -0,0 +1,236 @@
+package internal
+
+// Functions for checking Ghostwriter's "/status/" endpoint
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Default timeout for requests to the "/status/" endpoint
+const statusTimeout = 10 * time.Second
+
+// StatusCheckOptions is a custom type for storing the options for checking Ghostwriter's "/status/" endpoint.
+type StatusCheckOptions struct {
+	URL     string
+	CAFile  string
+	Timeout time.Duration
+}
+
+// DefaultStatusURL returns the URL of the local "/status/" endpoint. Production checks go through Nginx on the
+// "nginx_port" and development checks go to Django on the "django_port".
+func DefaultStatusURL(dev bool) string {
+	if dev {
+		return "http://" + net.JoinHostPort("localhost", ghostEnv.GetString("django_port")) + "/status/"
+	}
+	return "https://" + GetNginxAddress() + "/status/"
+}
+
+// DefaultStatusCheckOptions returns the options for checking the local "/status/" endpoint.
+func DefaultStatusCheckOptions(dev bool) StatusCheckOptions {
+	return StatusCheckOptions{URL: DefaultStatusURL(dev), Timeout: statusTimeout}
+}
+
+// IsLocalURL returns true if the URL ("rawURL" parameter) points to this host.
+func IsLocalURL(rawURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := target.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Read the certificates in a PEM or DER file.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// Build the TLS configuration for the "/status/" request. Certificates are verified against the system's roots
+// and the certificates in the "caFile". Without a "caFile", checks of this host also accept the certificate
+// installed for Nginx, so self-signed certificates work without disabling verification.
+func statusTLSConfig(target *url.URL, caFile string) (*tls.Config, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if caFile != "" {
+		certs, err := readCertificates(caFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+		return &tls.Config{RootCAs: roots}, nil
+	}
+	if !IsLocalURL(target.String()) {
+		return &tls.Config{RootCAs: roots}, nil
+	}
+
+	certPath, _ := certificatePaths()
+	pinned, err := readCertificates(certPath)
+	if err != nil {
+		return &tls.Config{RootCAs: roots}, nil
+	}
+	return &tls.Config{
+		// Verification is done in "VerifyConnection" instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("the server did not present a certificate")
+			}
+			leaf := state.PeerCertificates[0]
+			if bytes.Equal(leaf.Raw, pinned[0].Raw) {
+				return nil
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: target.Hostname()})
+			return err
+		},
+	}, nil
+}
+
+// Parse the URL of the "/status/" endpoint.
+func parseStatusURL(value string) (*url.URL, error) {
+	target, err := url.Parse(value)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid URL for the status endpoint: %s", value)
+	}
+	return target, nil
+}
+
+// ValidateStatusCheckOptions checks that the URL is valid and the CA file can be read, so long-running checks can
+// reject invalid options before they start.
+func ValidateStatusCheckOptions(opts StatusCheckOptions) error {
+	target, err := parseStatusURL(opts.URL)
+	if err != nil {
+		return err
+	}
+	if _, err := statusTLSConfig(target, opts.CAFile); err != nil {
+		return fmt.Errorf("failed to load the CA file: %v", err)
+	}
+	return nil
+}
+
+// CheckGhostwriterHealth fetches the latest health reports from Ghostwriter's status API endpoint. An endpoint
+// that is unreachable, fails TLS verification, or returns an unexpected response is reported as an issue of the
+// "Status" type. Errors are only returned for invalid options.
+func CheckGhostwriterHealth(opts StatusCheckOptions) (HealthIssues, error) {
+	var issues HealthIssues
+
+	target, err := parseStatusURL(opts.URL)
+	if err != nil {
+		return issues, err
+	}
+	service := "django"
+	if target.Scheme == "https" {
+		service = "nginx"
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = statusTimeout
+	}
+
+	tlsConfig, err := statusTLSConfig(target, opts.CAFile)
+	if err != nil {
+		return issues, fmt.Errorf("failed to load the CA file: %v", err)
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}
+	client := http.Client{Timeout: timeout, Transport: transport}
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return issues, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		var verifyErr *tls.CertificateVerificationError
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		var invalidErr x509.CertificateInvalidError
+		message := fmt.Sprintf("Could not reach %s: %v", target.Host, err)
+		if errors.As(err, &verifyErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+			message = fmt.Sprintf("Could not verify the TLS certificate of %s (use `--ca-file` for a private CA): %v", target.Host, err)
+		}
+		issues = append(issues, HealthIssue{Type: "Status", Service: service, Message: message})
+		return issues, nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		issues = append(issues, HealthIssue{
+			Type:    "Status",
+			Service: service,
+			Message: fmt.Sprintf("Non-OK HTTP status suggests an issue with the Django or Nginx services (Code %d)", res.StatusCode),
+		})
+		return issues, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		issues = append(issues, HealthIssue{Type: "Status", Service: service, Message: fmt.Sprintf("Could not read the response: %v", err)})
+		return issues, nil
+	}
+
+	var results map[string]interface{}
+	if err := json.Unmarshal(body, &results); err != nil {
+		issues = append(issues, HealthIssue{Type: "Status", Service: service, Message: fmt.Sprintf("The response is not valid JSON: %v", err)})
+		return issues, nil
+	}
+
+	for key, result := range results {
+		if result != "working" {
+			issues = append(issues, HealthIssue{Type: "Service", Service: key, Message: fmt.Sprint(result)})
+		}
+	}
+
+	return issues, nil
+}
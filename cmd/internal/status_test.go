@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Handler for a "/status/" endpoint that reports Redis as down.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/status/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"cache": "working", "database": "working", "redis": "Connection refused"}`))
+}
+
+func TestDefaultStatusURL(t *testing.T) {
+	ParseGhostwriterEnvironmentVariables()
+	ghostEnv.Set("nginx_port", "8443")
+	ghostEnv.Set("django_port", "8001")
+	defer ParseGhostwriterEnvironmentVariables()
+	assert.Equal(t, "https://localhost:8443/status/", DefaultStatusURL(false))
+	assert.Equal(t, "http://localhost:8001/status/", DefaultStatusURL(true))
+
+	assert.True(t, IsLocalURL("https://127.0.0.1:443/status/"))
+	assert.True(t, IsLocalURL("http://localhost:8000/status/"))
+	assert.True(t, IsLocalURL("https://[::1]/status/"))
+	assert.False(t, IsLocalURL("https://ghostwriter.example.com/status/"))
+}
+
+func TestCheckGhostwriterHealth(t *testing.T) {
+	ParseGhostwriterEnvironmentVariables()
+
+	// Plain HTTP with one failing service
+	server := httptest.NewServer(http.HandlerFunc(statusHandler))
+	defer server.Close()
+	issues, err := CheckGhostwriterHealth(StatusCheckOptions{URL: server.URL + "/status/"})
+	assert.NoError(t, err, "Expected `CheckGhostwriterHealth()` to return no error")
+	assert.Equal(t, HealthIssues{{Type: "Service", Service: "redis", Message: "Connection refused"}}, issues)
+
+	// Non-OK responses are issues
+	issues, err = CheckGhostwriterHealth(StatusCheckOptions{URL: server.URL + "/missing/"})
+	assert.NoError(t, err)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "Status", issues[0].Type)
+		assert.Contains(t, issues[0].Message, "Code 404")
+	}
+
+	// An unreachable server is an issue instead of a crash
+	closed := httptest.NewServer(http.HandlerFunc(statusHandler))
+	closedURL := closed.URL + "/status/"
+	closed.Close()
+	issues, err = CheckGhostwriterHealth(StatusCheckOptions{URL: closedURL})
+	assert.NoError(t, err)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "django", issues[0].Service)
+		assert.Contains(t, issues[0].Message, "Could not reach")
+	}
+
+	// Invalid options are errors
+	_, err = CheckGhostwriterHealth(StatusCheckOptions{URL: "ftp://example.com/status/"})
+	assert.Error(t, err, "Expected an error for an unsupported scheme")
+	_, err = CheckGhostwriterHealth(StatusCheckOptions{URL: server.URL, CAFile: filepath.Join(t.TempDir(), "missing.crt")})
+	assert.Error(t, err, "Expected an error for a missing CA file")
+}
+
+func TestCheckGhostwriterHealthTLS(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+
+	// The test server's certificate is not trusted without the CA file
+	server := httptest.NewTLSServer(http.HandlerFunc(statusHandler))
+	defer server.Close()
+	remoteURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/status/"
+	issues, err := CheckGhostwriterHealth(StatusCheckOptions{URL: remoteURL, CAFile: writeServerCA(t, server)})
+	assert.NoError(t, err)
+	assert.Len(t, issues, 1, "Expected only the failing service with the CA file")
+
+	otherCA := writeCertImportFile(t, "other-ca.pem", "root.pem")
+	issues, err = CheckGhostwriterHealth(StatusCheckOptions{URL: remoteURL, CAFile: otherCA})
+	assert.NoError(t, err)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "nginx", issues[0].Service)
+		assert.Contains(t, issues[0].Message, "Could not verify the TLS certificate")
+	}
+
+	// The certificate installed for Nginx is trusted for checks of this host
+	ensureSSLDir()
+	certOpts := DefaultCertOptions()
+	certOpts.Force = true
+	assert.NoError(t, generateCertificates(certOpts), "Expected `generateCertificates()` to return no error")
+	certPath, keyPath := certificatePaths()
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	assert.NoError(t, err, "Expected to load the generated certificate")
+	local := httptest.NewUnstartedServer(http.HandlerFunc(statusHandler))
+	local.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	local.StartTLS()
+	defer local.Close()
+	issues, err = CheckGhostwriterHealth(StatusCheckOptions{URL: local.URL + "/status/"})
+	assert.NoError(t, err)
+	assert.Len(t, issues, 1, "Expected only the failing service for the local certificate")
+}
+
+// Write the test server's certificate to a PEM file for the "CAFile" option.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "server-ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestValidateStatusCheckOptions(t *testing.T) {
+	assert.NoError(t, ValidateStatusCheckOptions(StatusCheckOptions{URL: "https://ghostwriter.example.com/status/"}))
+	assert.Error(t, ValidateStatusCheckOptions(StatusCheckOptions{URL: "ghostwriter.example.com"}), "Expected an error for a URL without a scheme")
+	err := ValidateStatusCheckOptions(StatusCheckOptions{URL: "https://ghostwriter.example.com/status/", CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to load the CA file")
+}
@@ -0,0 +1,487 @@
+package internal
+
+// Functions for collecting a support bundle with the information needed to troubleshoot an installation
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GhostManager/Ghostwriter_CLI/cmd/config"
+	"github.com/moby/moby/client"
+)
+
+// Replacement for redacted secrets
+const redactedSecret = "[REDACTED]"
+
+// Secrets shorter than this are not replaced in the other files because they would match unrelated text
+const minRedactedSecretLength = 6
+
+// Hosts that are the same for every installation and are never redacted
+var publicBundleHosts = []string{
+	"localhost", "127.0.0.1", "::1", "0.0.0.0", "*", "django", "nginx", "postgres", "redis", "graphql_engine",
+	"collab-server", "host.docker.internal", "ghostwriter.local",
+}
+
+// Settings with hosts and domains that are redacted with the "RedactHosts" option
+var hostSettings = []string{
+	"django_allowed_hosts", "django_csrf_trusted_origins", "django_mailgun_domain",
+	"django_social_account_domain_allowlist", "django_superuser_email",
+}
+
+var ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// Candidates for IPv6 addresses, which are validated with "net.ParseIP()" so times like "15:04:05" are kept
+var ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]*(?::[0-9A-Fa-f]*){2,7}`)
+
+// SupportBundleOptions is a custom type for storing the options for creating a support bundle.
+type SupportBundleOptions struct {
+	// Path of the ".tar.gz" file to create
+	Output string
+	// Number of lines of logs to include for each service
+	Lines string
+	// Replace the hostnames, domains, and IP addresses of the installation
+	RedactHosts bool
+}
+
+// BundleFile is a custom type for storing a file in the support bundle's manifest.
+type BundleFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BundleManifest is a custom type for storing the contents of a support bundle ("manifest.json").
+type BundleManifest struct {
+	Created     time.Time    `json:"created"`
+	CLIVersion  string       `json:"cli_version"`
+	Instance    string       `json:"instance"`
+	Mode        string       `json:"mode"`
+	RedactHosts bool         `json:"redact_hosts"`
+	Files       []BundleFile `json:"files"`
+}
+
+// Errors returns the number of files that could not be collected.
+func (m BundleManifest) Errors() int {
+	count := 0
+	for _, file := range m.Files {
+		if file.Error != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Redactor is a custom type for removing secrets and, optionally, hosts from the files in a support bundle.
+type Redactor struct {
+	replacer *strings.Replacer
+	ips      map[string]string
+}
+
+// NewRedactor creates a Redactor that replaces the values of the secrets in the configuration ("settings"
+// parameter), including the escaped values Docker Compose writes with "$$" for "$". With "redactHosts", the hosts
+// from the allowed hosts, trusted origins, and email settings, the names of the server and Docker daemon
+// ("machineHosts" parameter), and every IP address outside of the loopback range are replaced with placeholders
+// that are consistent across the files.
+func NewRedactor(settings Configurations, redactHosts bool, machineHosts ...string) *Redactor {
+	replacements := make(map[string]string)
+	for _, setting := range settings {
+		if IsSecretKey(setting.Key) && len(setting.Val) >= minRedactedSecretLength {
+			replacements[setting.Val] = redactedSecret
+			replacements[strings.ReplaceAll(setting.Val, "$", "$$")] = redactedSecret
+		}
+	}
+
+	redactor := &Redactor{}
+	if redactHosts {
+		redactor.ips = make(map[string]string)
+		var hosts []string
+		for _, setting := range settings {
+			if Contains(hostSettings, strings.ToLower(setting.Key)) {
+				hosts = append(hosts, settingHosts(setting.Val)...)
+			}
+		}
+		for _, host := range machineHosts {
+			// Names are matched as written and in lowercase, like the hosts from the settings
+			hosts = append(hosts, host, strings.ToLower(host))
+		}
+		sort.Strings(hosts)
+		count := 0
+		for _, host := range hosts {
+			if _, ok := replacements[host]; ok || host == "" || Contains(publicBundleHosts, host) {
+				continue
+			}
+			if net.ParseIP(host) != nil {
+				// IP addresses are handled with the pattern
+				continue
+			}
+			count++
+			replacements[host] = fmt.Sprintf("host-%d.redacted", count)
+		}
+	}
+
+	// Replace longer values first, so a domain doesn't break the replacement of its subdomains
+	var keys []string
+	for key := range replacements {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	var pairs []string
+	for _, key := range keys {
+		pairs = append(pairs, key, replacements[key])
+	}
+	redactor.replacer = strings.NewReplacer(pairs...)
+	return redactor
+}
+
+// Get the hosts from a setting with space-separated hosts, origins, domains, or email addresses.
+func settingHosts(value string) []string {
+	var hosts []string
+	for _, item := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		item = strings.ToLower(item)
+		if strings.Contains(item, "://") {
+			if parsed, err := url.Parse(item); err == nil {
+				item = parsed.Hostname()
+			}
+		}
+		if _, domain, ok := strings.Cut(item, "@"); ok {
+			item = domain
+		}
+		item = strings.TrimPrefix(strings.TrimPrefix(item, "*"), ".")
+		if item != "" {
+			hosts = append(hosts, item)
+		}
+	}
+	return hosts
+}
+
+// Replace an IP address with its placeholder. Matches that aren't IP addresses and loopback and unspecified
+// addresses are kept.
+func (r *Redactor) redactIP(match string) string {
+	ip := net.ParseIP(match)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return match
+	}
+	key := ip.String()
+	if placeholder, ok := r.ips[key]; ok {
+		return placeholder
+	}
+	placeholder := fmt.Sprintf("ip-%d.redacted", len(r.ips)+1)
+	r.ips[key] = placeholder
+	return placeholder
+}
+
+// Redact the secrets and hosts in the text.
+func (r *Redactor) Redact(text string) string {
+	text = r.replacer.Replace(text)
+	if r.ips == nil {
+		return text
+	}
+	text = ipv4Pattern.ReplaceAllStringFunc(text, r.redactIP)
+	return ipv6Pattern.ReplaceAllStringFunc(text, r.redactIP)
+}
+
+// Redact the values of the secrets in the "environment" sections of the output of "docker compose config" by
+// their names, so values that Docker Compose changed (e.g., by escaping "$" as "$$") are covered too.
+func redactComposeEnvironment(config string) string {
+	lines := strings.Split(config, "\n")
+	envIndent, secretIndent := -1, -1
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		// Lines indented under a secret continue its value (e.g., a block scalar)
+		if secretIndent >= 0 && indent > secretIndent {
+			lines[i] = line[:indent] + redactedSecret
+			continue
+		}
+		secretIndent = -1
+		if envIndent >= 0 && indent <= envIndent {
+			envIndent = -1
+		}
+		if trimmed == "environment:" {
+			envIndent = indent
+			continue
+		}
+		if envIndent < 0 {
+			continue
+		}
+		entry, separator := trimmed, ": "
+		if strings.HasPrefix(entry, "- ") {
+			entry, separator = strings.TrimPrefix(entry, "- "), "="
+		}
+		key, val, ok := strings.Cut(entry, separator)
+		if !ok || !IsSecretKey(strings.Trim(key, `"'`)) {
+			continue
+		}
+		if val != "" {
+			lines[i] = line[:len(line)-len(val)] + redactedSecret
+		}
+		secretIndent = indent
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Get the names of the server and the Docker daemon, which are redacted with the "RedactHosts" option.
+func bundleMachineHosts() []string {
+	var hosts []string
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	if name, err := runBundleCmd("info", "--format", "{{.Name}}"); err == nil {
+		hosts = append(hosts, strings.TrimSpace(name))
+	}
+	return hosts
+}
+
+// Build the contents of the ".env" file with the values of the secrets removed.
+func redactedEnvFile(settings Configurations) string {
+	var builder strings.Builder
+	for _, setting := range settings {
+		val := setting.Val
+		if IsSecretKey(setting.Key) && val != "" {
+			val = redactedSecret
+		}
+		fmt.Fprintf(&builder, "%s=%s\n", setting.Key, val)
+	}
+	return builder.String()
+}
+
+// bundleEntry is a custom type for storing a file collected for the support bundle.
+type bundleEntry struct {
+	name        string
+	description string
+	content     string
+	err         error
+}
+
+// Run a "docker" command in the directory with the Docker Compose files and return its combined output.
+func runBundleCmd(args ...string) (string, error) {
+	command := exec.Command(dockerCmd, args...)
+	command.Dir = GetCwdFromExe()
+	command.Env = commandEnvironment()
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("`%s %s` failed: %w", dockerCmd, strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+// Collect the CLI, Ghostwriter, and OS versions.
+func bundleVersions() (string, error) {
+	var builder strings.Builder
+	cliVersion := config.Version
+	if config.BuildDate != "" {
+		cliVersion += " (" + config.BuildDate + ")"
+	}
+	fmt.Fprintf(&builder, "Ghostwriter CLI: %s\n", cliVersion)
+	ghostwriterVersion, err := GetLocalGhostwriterVersion()
+	if err != nil {
+		ghostwriterVersion = err.Error()
+	}
+	fmt.Fprintf(&builder, "Ghostwriter: %s\n", ghostwriterVersion)
+	fmt.Fprintf(&builder, "OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	return builder.String(), nil
+}
+
+// Collect the results of the healthcheck as JSON. Failed checks are recorded in the document instead of
+// failing the whole file.
+func bundleHealthcheck(dev bool) (string, error) {
+	report := struct {
+		Containers ContainerHealths `json:"containers"`
+		Probes     ProbeResults     `json:"probes"`
+		Resources  *HostResources   `json:"resources,omitempty"`
+		Issues     HealthIssues     `json:"issues"`
+		Errors     []string         `json:"errors,omitempty"`
+	}{}
+
+	healths, err := InspectContainers()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("containers: %s", err))
+	} else {
+		report.Containers = healths
+		report.Issues = append(report.Issues, ContainerIssues(healths, dev)...)
+		probes, err := ProbeServices(healths)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("probes: %s", err))
+		}
+		report.Probes = probes
+		report.Issues = append(report.Issues, probes.Issues()...)
+		resources, err := CheckHostResources(healths)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("resources: %s", err))
+		} else {
+			report.Resources = &resources
+			report.Issues = append(report.Issues, resources.Issues()...)
+		}
+	}
+	statusIssues, err := CheckGhostwriterHealth(DefaultStatusCheckOptions(dev))
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("status: %s", err))
+	}
+	report.Issues = append(report.Issues, statusIssues...)
+	if !dev {
+		report.Issues = append(report.Issues, CheckCertificateHealth()...)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	return string(data) + "\n", err
+}
+
+// Collect the logs of every container with "FetchLogs()".
+func bundleLogs(lines string) []bundleEntry {
+	var entries []bundleEntry
+	cli, err := client.New(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return append(entries, bundleEntry{name: "logs", description: "Container logs", err: err})
+	}
+	defer cli.Close()
+	containers, err := FindLogContainers(cli, "all")
+	if err != nil {
+		return append(entries, bundleEntry{name: "logs", description: "Container logs", err: err})
+	}
+	for _, container := range containers {
+		logs, err := FetchLogs(container.Name, lines)
+		entries = append(entries, bundleEntry{
+			name:        path.Join("logs", container.Name+".log"),
+			description: fmt.Sprintf("Last %s lines of logs for %s", lines, container.Name),
+			content:     strings.Join(logs, ""),
+			err:         err,
+		})
+	}
+	return entries
+}
+
+// Collect every file for the support bundle.
+func collectSupportBundle(opts SupportBundleOptions) []bundleEntry {
+	yaml := ModeComposeFile()
+	dev := GetMode() != ModeProduction
+	var entries []bundleEntry
+	add := func(name string, description string, content string, err error) {
+		entries = append(entries, bundleEntry{name: name, description: description, content: content, err: err})
+	}
+
+	versions, err := bundleVersions()
+	add("versions.txt", "Versions of the CLI, Ghostwriter, and the OS", versions, err)
+	info, err := runBundleCmd("info")
+	add("docker/info.txt", "Output of `docker info`", info, err)
+	composeVersion, err := runBundleCmd("compose", "version")
+	add("docker/compose-version.txt", "Output of `docker compose version`", composeVersion, err)
+	composeConfig, err := runBundleCmd(ComposeArgs("-f", yaml, "config")...)
+	add("docker/compose-config.yml", fmt.Sprintf("Output of `docker compose -f %s config`", yaml), redactComposeEnvironment(composeConfig), err)
+	containers, err := runBundleCmd(ComposeArgs("-f", yaml, "ps", "--all")...)
+	add("docker/containers.txt", "Output of `docker compose ps --all`", containers, err)
+	health, err := bundleHealthcheck(dev)
+	add("healthcheck.json", "Results of the healthcheck", health, err)
+	entries = append(entries, bundleLogs(opts.Lines)...)
+	add("env.txt", "The configuration with every secret redacted", redactedEnvFile(GetConfigAll()), nil)
+	return entries
+}
+
+// Write the files and the manifest to a gzipped tarball. Every file is redacted before it is written, and the
+// manifest records the size and hash of the redacted contents.
+func writeSupportBundle(w io.Writer, root string, entries []bundleEntry, manifest BundleManifest, redactor *Redactor) (BundleManifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	writeFile := func(name string, content []byte) error {
+		header := &tar.Header{
+			Name:    path.Join(root, name),
+			Mode:    0600,
+			Size:    int64(len(content)),
+			ModTime: manifest.Created,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+
+	for _, entry := range entries {
+		file := BundleFile{Name: entry.name, Description: entry.description}
+		if entry.err != nil {
+			file.Error = redactor.Redact(entry.err.Error())
+		}
+		if entry.content != "" {
+			content := []byte(redactor.Redact(entry.content))
+			sum := sha256.Sum256(content)
+			file.Size = len(content)
+			file.SHA256 = hex.EncodeToString(sum[:])
+			if err := writeFile(entry.name, content); err != nil {
+				return manifest, err
+			}
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeFile("manifest.json", append(data, '\n')); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+// CreateSupportBundle collects the versions, Docker and Docker Compose information, running containers,
+// healthcheck results, recent logs of every service, and the redacted configuration into a ".tar.gz" file with a
+// "manifest.json" describing its contents. Files that can't be collected are listed in the manifest with the error.
+func CreateSupportBundle(opts SupportBundleOptions) (BundleManifest, error) {
+	manifest := BundleManifest{
+		Created:     time.Now().UTC().Truncate(time.Second),
+		CLIVersion:  config.Version,
+		Instance:    activeInstance.Name,
+		Mode:        GetMode(),
+		RedactHosts: opts.RedactHosts,
+	}
+	var machineHosts []string
+	if opts.RedactHosts {
+		machineHosts = bundleMachineHosts()
+	}
+	redactor := NewRedactor(GetConfigAll(), opts.RedactHosts, machineHosts...)
+	entries := collectSupportBundle(opts)
+
+	file, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return manifest, err
+	}
+	root := strings.TrimSuffix(strings.TrimSuffix(path.Base(opts.Output), ".gz"), ".tar")
+	manifest, err = writeSupportBundle(file, root, entries, manifest, redactor)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return manifest, err
+}
+
+// DefaultSupportBundleName returns the default file name for a support bundle created at the given time.
+func DefaultSupportBundleName(now time.Time) string {
+	return fmt.Sprintf("ghostwriter-support-%s.tar.gz", now.Format("20060102-150405"))
+}
@@ -0,0 +1,316 @@
+package internal
+
+// Functions for exporting, comparing, and importing the configuration between environments
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GhostManager/Ghostwriter_CLI/cmd/config"
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// Options for handling secrets in exported configurations
+	SecretsInclude = "include"
+	SecretsExclude = "exclude"
+	SecretsEncrypt = "encrypt"
+
+	// Prefix for encrypted values in exported configurations
+	encryptedPrefix = "enc:"
+	// PBKDF2 iterations used to derive the encryption key from the passphrase
+	exportKeyIterations = 600000
+)
+
+// ConfigDocument is a custom type for storing a portable copy of the configuration.
+type ConfigDocument struct {
+	Version  string            `json:"version" yaml:"version"`
+	Exported string            `json:"exported" yaml:"exported"`
+	Secrets  string            `json:"secrets" yaml:"secrets"`
+	Salt     string            `json:"salt,omitempty" yaml:"salt,omitempty"`
+	Settings map[string]string `json:"settings" yaml:"settings"`
+}
+
+// ConfigChange is a custom type for storing the difference of a single value between two configurations.
+type ConfigChange struct {
+	Key      string
+	Current  string
+	Incoming string
+	Kind     string
+}
+
+// ConfigChanges is a collection of ConfigChange structs
+type ConfigChanges []ConfigChange
+
+// Len returns the length of a ConfigChanges struct
+func (c ConfigChanges) Len() int {
+	return len(c)
+}
+
+// Less determines if one ConfigChange is less than another ConfigChange
+func (c ConfigChanges) Less(i, j int) bool {
+	return c[i].Key < c[j].Key
+}
+
+// Swap exchanges the position of two ConfigChange values in a ConfigChanges struct
+func (c ConfigChanges) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// Kinds of differences reported by "DiffConfig()"
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "missing"
+	ChangeModified  = "changed"
+	ChangeEncrypted = "encrypted"
+)
+
+// IsSecretKey determines if the environment variable ("key" parameter) holds a password or other secret.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"password", "secret", "api_key"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSecret replaces the value ("val" parameter) of a secret environment variable ("key" parameter)
+// for display. Non-secret values are returned unchanged.
+func MaskSecret(key string, val string) string {
+	if !IsSecretKey(key) || val == "" {
+		return val
+	}
+	return "********"
+}
+
+// Derive the AES-256 key for encrypting secrets from the "passphrase" and "salt".
+func deriveExportKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, exportKeyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt a single value with AES-GCM and return it with the "enc:" prefix.
+func encryptValue(aead cipher.AEAD, val string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(val), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt a single value produced by "encryptValue()".
+func decryptValue(aead cipher.AEAD, val string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(val, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("could not decrypt value (is the passphrase correct?)")
+	}
+	return string(plain), nil
+}
+
+// ExportConfig builds a portable copy of the current configuration. The "secrets" parameter controls
+// whether secrets are included as-is, excluded, or encrypted with the "passphrase".
+func ExportConfig(secrets string, passphrase string) (ConfigDocument, error) {
+	doc := ConfigDocument{
+		Version:  config.Version,
+		Exported: time.Now().UTC().Format(time.RFC3339),
+		Secrets:  secrets,
+		Settings: make(map[string]string),
+	}
+
+	var aead cipher.AEAD
+	switch secrets {
+	case SecretsInclude, SecretsExclude:
+	case SecretsEncrypt:
+		if passphrase == "" {
+			return doc, errors.New("a passphrase is required to encrypt secrets")
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return doc, err
+		}
+		doc.Salt = base64.StdEncoding.EncodeToString(salt)
+		var err error
+		aead, err = deriveExportKey(passphrase, salt)
+		if err != nil {
+			return doc, err
+		}
+	default:
+		return doc, fmt.Errorf("unknown secrets option `%s` (use %s, %s, or %s)", secrets, SecretsInclude, SecretsExclude, SecretsEncrypt)
+	}
+
+	for _, setting := range GetConfigAll() {
+		// The mode belongs to each environment and isn't moved between them
+		if isModeKey(setting.Key) {
+			continue
+		}
+		val := setting.Val
+		if IsSecretKey(setting.Key) {
+			if secrets == SecretsExclude {
+				continue
+			}
+			if secrets == SecretsEncrypt {
+				var err error
+				val, err = encryptValue(aead, val)
+				if err != nil {
+					return doc, err
+				}
+			}
+		}
+		doc.Settings[setting.Key] = val
+	}
+
+	return doc, nil
+}
+
+// MarshalConfigDocument encodes the document as YAML or JSON based on the "format" parameter.
+func MarshalConfigDocument(doc ConfigDocument, format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return yaml.Marshal(doc)
+	case "json":
+		return json.MarshalIndent(doc, "", "  ")
+	}
+	return nil, fmt.Errorf("unknown format `%s` (use yaml or json)", format)
+}
+
+// ParseConfigDocument decodes a document exported by "ExportConfig()". The format is determined by the
+// file extension in "name" and defaults to YAML (which also accepts JSON). Encrypted secrets are decrypted
+// with the "passphrase" if one is provided.
+func ParseConfigDocument(name string, data []byte, passphrase string) (ConfigDocument, error) {
+	var doc ConfigDocument
+	var err error
+	if strings.ToLower(filepath.Ext(name)) == ".json" {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return doc, fmt.Errorf("could not parse %s: %v", name, err)
+	}
+	if len(doc.Settings) == 0 {
+		return doc, fmt.Errorf("%s does not contain any settings", name)
+	}
+
+	// Normalize keys to match the output of ``GetConfigAll()``
+	settings := make(map[string]string, len(doc.Settings))
+	for key, val := range doc.Settings {
+		settings[strings.ToUpper(key)] = val
+	}
+	doc.Settings = settings
+
+	if doc.Secrets == SecretsEncrypt && passphrase != "" {
+		salt, err := base64.StdEncoding.DecodeString(doc.Salt)
+		if err != nil {
+			return doc, fmt.Errorf("invalid salt in %s: %v", name, err)
+		}
+		aead, err := deriveExportKey(passphrase, salt)
+		if err != nil {
+			return doc, err
+		}
+		for key, val := range doc.Settings {
+			if strings.HasPrefix(val, encryptedPrefix) {
+				plain, err := decryptValue(aead, val)
+				if err != nil {
+					return doc, fmt.Errorf("%s: %v", key, err)
+				}
+				doc.Settings[key] = plain
+			}
+		}
+		doc.Secrets = SecretsInclude
+	}
+
+	return doc, nil
+}
+
+// DiffConfig compares the document against the current configuration. Secrets excluded from the document
+// are not reported as missing, and secrets that are still encrypted are reported without comparing them.
+func DiffConfig(doc ConfigDocument) ConfigChanges {
+	var changes ConfigChanges
+
+	current := make(map[string]string)
+	for _, setting := range GetConfigAll() {
+		if !isModeKey(setting.Key) {
+			current[setting.Key] = setting.Val
+		}
+	}
+
+	for key, incoming := range doc.Settings {
+		// Exports from older versions include the mode's values
+		if isModeKey(key) {
+			continue
+		}
+		local, ok := current[key]
+		switch {
+		case strings.HasPrefix(incoming, encryptedPrefix):
+			changes = append(changes, ConfigChange{key, local, incoming, ChangeEncrypted})
+		case !ok:
+			changes = append(changes, ConfigChange{key, "", incoming, ChangeAdded})
+		case local != incoming:
+			changes = append(changes, ConfigChange{key, local, incoming, ChangeModified})
+		}
+	}
+	for key, local := range current {
+		if _, ok := doc.Settings[key]; !ok {
+			if doc.Secrets == SecretsExclude && IsSecretKey(key) {
+				continue
+			}
+			changes = append(changes, ConfigChange{key, local, "", ChangeRemoved})
+		}
+	}
+
+	sort.Sort(changes)
+
+	return changes
+}
+
+// ImportConfig applies the "changes" to the configuration and writes the ".env" file. Values missing from the
+// imported document and encrypted values are never applied. Returns the keys that were updated.
+func ImportConfig(changes ConfigChanges) []string {
+	var updated []string
+	for _, change := range changes {
+		if change.Kind != ChangeAdded && change.Kind != ChangeModified {
+			continue
+		}
+		updated = append(updated, change.Key)
+	}
+	if len(updated) == 0 {
+		return updated
+	}
+	before := snapshotConfig(updated...)
+	for _, change := range changes {
+		if Contains(updated, change.Key) {
+			setConfigValue(strings.ToLower(change.Key), change.Incoming)
+		}
+	}
+	WriteGhostwriterEnvironmentVariables()
+	recordConfigChange("import", before)
+	return updated
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSecretKey(t *testing.T) {
+	assert.True(t, IsSecretKey("POSTGRES_PASSWORD"), "Expected `POSTGRES_PASSWORD` to be a secret")
+	assert.True(t, IsSecretKey("django_secret_key"), "Expected `django_secret_key` to be a secret")
+	assert.True(t, IsSecretKey("django_mailgun_api_key"), "Expected `django_mailgun_api_key` to be a secret")
+	assert.False(t, IsSecretKey("django_date_format"), "Expected `django_date_format` to not be a secret")
+	assert.Equal(t, "********", MaskSecret("postgres_password", "hunter2"))
+	assert.Equal(t, "d M Y", MaskSecret("django_date_format", "d M Y"))
+}
+
+func TestServicesForKeys(t *testing.T) {
+	assert.Equal(t, []string{"django", "queue"}, ServicesForKey("DJANGO_DATE_FORMAT"))
+	assert.Equal(t, []string{"graphql_engine"}, ServicesForKey("hasura_graphql_log_level"))
+	assert.Nil(t, ServicesForKey("unknown_setting"))
+	assert.Equal(
+		t,
+		[]string{"django", "graphql_engine", "nginx", "queue"},
+		ServicesForKeys([]string{"django_date_format", "hasura_graphql_log_level", "nginx_port"}),
+	)
+}
+
+func TestConfigTransfer(t *testing.T) {
+	defer quietTests()()
+	ParseGhostwriterEnvironmentVariables()
+
+	// Secrets are left out of the export when excluded
+	doc, err := ExportConfig(SecretsExclude, "")
+	assert.NoError(t, err, "Expected `ExportConfig()` to return no error")
+	_, ok := doc.Settings["POSTGRES_PASSWORD"]
+	assert.False(t, ok, "Expected `POSTGRES_PASSWORD` to be excluded")
+	assert.Equal(t, ghostEnv.GetString("django_date_format"), doc.Settings["DJANGO_DATE_FORMAT"])
+	assert.Empty(t, DiffConfig(doc), "Expected no differences between the export and the current configuration")
+
+	// Encrypted secrets require a passphrase and round trip through YAML
+	_, err = ExportConfig(SecretsEncrypt, "")
+	assert.Error(t, err, "Expected an error when encrypting without a passphrase")
+	doc, err = ExportConfig(SecretsEncrypt, "correct horse")
+	assert.NoError(t, err, "Expected `ExportConfig()` to return no error")
+	assert.True(t, strings.HasPrefix(doc.Settings["POSTGRES_PASSWORD"], encryptedPrefix), "Expected `POSTGRES_PASSWORD` to be encrypted")
+	data, err := MarshalConfigDocument(doc, "yaml")
+	assert.NoError(t, err, "Expected `MarshalConfigDocument()` to return no error")
+
+	_, err = ParseConfigDocument("export.yml", data, "wrong")
+	assert.Error(t, err, "Expected an error when decrypting with the wrong passphrase")
+	locked, err := ParseConfigDocument("export.yml", data, "")
+	assert.NoError(t, err, "Expected `ParseConfigDocument()` to return no error without a passphrase")
+	assert.Equal(t, ChangeEncrypted, DiffConfig(locked)[0].Kind, "Expected encrypted values to be reported without comparing them")
+	unlocked, err := ParseConfigDocument("export.yml", data, "correct horse")
+	assert.NoError(t, err, "Expected `ParseConfigDocument()` to return no error")
+	assert.Equal(t, ghostEnv.GetString("postgres_password"), unlocked.Settings["POSTGRES_PASSWORD"])
+
+	// Changed and added values are imported, missing values are left alone
+	unlocked.Settings["DJANGO_DATE_FORMAT"] = "Y-m-d"
+	unlocked.Settings["DJANGO_NEW_SETTING"] = "value"
+	delete(unlocked.Settings, "DJANGO_ADMIN_URL")
+	changes := DiffConfig(unlocked)
+	assert.Equal(
+		t,
+		ConfigChanges{
+			{"DJANGO_ADMIN_URL", "admin/", "", ChangeRemoved},
+			{"DJANGO_DATE_FORMAT", ghostEnv.GetString("django_date_format"), "Y-m-d", ChangeModified},
+			{"DJANGO_NEW_SETTING", "", "value", ChangeAdded},
+		},
+		changes,
+	)
+	updated := ImportConfig(changes)
+	assert.Equal(t, []string{"DJANGO_DATE_FORMAT", "DJANGO_NEW_SETTING"}, updated)
+	assert.Equal(t, "Y-m-d", ghostEnv.GetString("django_date_format"))
+	assert.Equal(t, "admin/", ghostEnv.GetString("django_admin_url"))
+
+	// JSON documents are detected by their extension
+	data, err = MarshalConfigDocument(doc, "json")
+	assert.NoError(t, err, "Expected `MarshalConfigDocument()` to return no error")
+	_, err = ParseConfigDocument("export.json", data, "")
+	assert.NoError(t, err, "Expected `ParseConfigDocument()` to parse JSON")
+}
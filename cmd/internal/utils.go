@@ -5,9 +5,7 @@ package internal
 
 import (
 	"bufio"
-	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,16 +13,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
 
-// HealthIssue is a custom type for storing healthcheck output.
+// HealthIssue is a custom type for storing healthcheck output. "Latency" is set for issues found by
+// service probes.
 type HealthIssue struct {
 	Type    string
 	Service string
 	Message string
+	Latency time.Duration
 }
 
 type HealthIssues []HealthIssue
@@ -84,11 +83,19 @@ func CheckPath(cmd string) bool {
 // RunBasicCmd executes a given command ("name") with a list of arguments ("args")
 // and return a "string" with the output.
 func RunBasicCmd(name string, args []string) (string, error) {
-	out, err := exec.Command(name, args...).Output()
+	command := exec.Command(name, args...)
+	command.Env = commandEnvironment()
+	out, err := command.Output()
 	output := string(out[:])
 	return output, err
 }
 
+// Build the environment for commands run by the CLI. The values derived from the environment mode are
+// added so "docker compose" can use them in place of the values that are no longer in the ".env" file.
+func commandEnvironment() []string {
+	return append(os.Environ(), ModeEnvironment()...)
+}
+
 // RunRawCmd executes a given command ("name") with a list of arguments ("args")
 // Does not convert docker to docker compose like `RunCmd` does.
 func RunRawCmd(name string, args ...string) error {
@@ -103,6 +110,7 @@ func RunRawCmd(name string, args ...string) error {
 	exePath := filepath.Dir(exe)
 	command := exec.Command(path, args...)
 	command.Dir = exePath
+	command.Env = commandEnvironment()
 	command.Stdin = os.Stdin
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
@@ -123,8 +131,9 @@ func RunRawCmd(name string, args ...string) error {
 func RunCmd(name string, args []string) error {
 	// Prepend ``compose`` to the args for docker/podman commands
 	// dockerCmd will only be "docker" or "podman" (never "docker-compose")
+	// The active instance's project name and environment file are added as global ``compose`` flags
 	if name == "docker" || name == "podman" {
-		args = append([]string{"compose"}, args...)
+		args = ComposeArgs(args...)
 	}
 	return RunRawCmd(name, args...)
 }
@@ -253,61 +262,6 @@ func quietTests() func() {
 	}
 }
 
-// CheckGhostwriterHealth fetches the latest health reports from Ghostwriter's status API endpoint.
-func CheckGhostwriterHealth(dev bool) (HealthIssues, error) {
-	var issues HealthIssues
-
-	protocol := "https"
-	port := "443"
-	if dev {
-		protocol = "http"
-		port = "8000"
-	}
-
-	baseUrl := protocol + "://localhost:" + port + "/status/"
-	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	client := http.Client{Timeout: time.Second * 2, Transport: transport}
-
-	req, err := http.NewRequest(http.MethodGet, baseUrl, nil)
-	if err != nil {
-		return issues, err
-	}
-
-	req.Header.Set("Accept", "application/json")
-
-	res, getErr := client.Do(req)
-
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return issues, errors.New("Non-OK HTTP status suggests an issue with the Django or Nginx services (Code " + strconv.Itoa(res.StatusCode) + ")")
-	}
-	if getErr != nil {
-		return issues, getErr
-	}
-
-	body, readErr := io.ReadAll(res.Body)
-	if readErr != nil {
-		return issues, readErr
-	}
-
-	var results map[string]interface{}
-	jsonErr := json.Unmarshal(body, &results)
-	if jsonErr != nil {
-		return issues, jsonErr
-	}
-
-	for key := range results {
-		if results[key] != "working" {
-			issues = append(issues, HealthIssue{"Service", key, results[key].(string)})
-		}
-	}
-
-	return issues, nil
-}
-
 // AskForConfirmation asks the user for confirmation. A user must type in "yes" or "no" and
 // then press enter. It has fuzzy matching, so "y", "Y", "yes", "YES", and "Yes" all count as
 // confirmations. If the input is not recognized, it will ask again. The function does not return
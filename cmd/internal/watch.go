@@ -0,0 +1,320 @@
+package internal
+
+// Functions for watching Ghostwriter's health and posting alerts to webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of webhook payloads
+const (
+	WebhookJSON  = "json"
+	WebhookSlack = "slack"
+	WebhookTeams = "teams"
+)
+
+// Statuses of alerts
+const (
+	AlertProblem   = "problem"
+	AlertRecovered = "recovered"
+)
+
+// Counts and percentages change between checks, so they are ignored when comparing issues
+var issueNumbersRegex = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+
+// Maximum number of undelivered alerts kept for each webhook, so an unreachable webhook doesn't grow without limit
+const maxPendingAlerts = 100
+
+// Webhook is a custom type for storing a URL that receives alerts and the format of its payloads.
+type Webhook struct {
+	Format string
+	URL    string
+}
+
+// ParseWebhook parses a webhook from a URL that is optionally prefixed with the payload format
+// (e.g., "slack=https://hooks.slack.com/services/..."). URLs without a prefix receive generic JSON.
+func ParseWebhook(value string) (Webhook, error) {
+	webhook := Webhook{Format: WebhookJSON, URL: value}
+	for _, format := range []string{WebhookJSON, WebhookSlack, WebhookTeams} {
+		if strings.HasPrefix(value, format+"=") {
+			webhook.Format = format
+			webhook.URL = strings.TrimPrefix(value, format+"=")
+			break
+		}
+	}
+	if !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+		return webhook, fmt.Errorf("webhook URL `%s` must start with http:// or https://", webhook.URL)
+	}
+	return webhook, nil
+}
+
+// Describe the webhook in messages by its position ("index" parameter) and host. The rest of the URL is left out
+// because Slack and Teams webhook URLs contain the token that authorizes the requests.
+func (w Webhook) describe(index int) string {
+	parsed, err := url.Parse(w.URL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Sprintf("webhook %d", index+1)
+	}
+	return fmt.Sprintf("webhook %d (%s://%s)", index+1, parsed.Scheme, parsed.Host)
+}
+
+// HealthAlert is a custom type for storing a change in the state of a healthcheck issue.
+type HealthAlert struct {
+	Status string
+	Issue  HealthIssue
+	Since  time.Time
+	Time   time.Time
+}
+
+// HealthMonitor is a custom type for tracking healthcheck issues between checks. Issues are only
+// alerted when they appear and when they are resolved.
+type HealthMonitor struct {
+	Webhooks []Webhook
+	Client   *http.Client
+	// Issues seen in the last check by key with the time each issue was first seen
+	active map[string]HealthAlert
+	// Alerts that failed to post by webhook URL, which are sent again with the next alerts
+	pending map[string][]HealthAlert
+}
+
+// NewHealthMonitor creates a HealthMonitor that posts alerts to the "webhooks".
+func NewHealthMonitor(webhooks []Webhook) *HealthMonitor {
+	return &HealthMonitor{
+		Webhooks: webhooks,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		active:   make(map[string]HealthAlert),
+		pending:  make(map[string][]HealthAlert),
+	}
+}
+
+// Identify an issue between checks. Numbers in the message (e.g., failure counts and days until expiry)
+// are ignored so an issue that gets worse is not alerted again.
+func issueKey(issue HealthIssue) string {
+	return issue.Type + "|" + issue.Service + "|" + issueNumbersRegex.ReplaceAllString(issue.Message, "#")
+}
+
+// Update compares the "issues" from the latest check with the previous check and returns the alerts for new
+// and resolved issues. The first call alerts every issue.
+func (m *HealthMonitor) Update(issues HealthIssues, now time.Time) []HealthAlert {
+	var alerts []HealthAlert
+	current := make(map[string]HealthAlert)
+	for _, issue := range issues {
+		key := issueKey(issue)
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if previous, ok := m.active[key]; ok {
+			// Keep the latest message but the original time
+			current[key] = HealthAlert{Status: AlertProblem, Issue: issue, Since: previous.Since, Time: now}
+			continue
+		}
+		alert := HealthAlert{Status: AlertProblem, Issue: issue, Since: now, Time: now}
+		current[key] = alert
+		alerts = append(alerts, alert)
+	}
+	for key, previous := range m.active {
+		if _, ok := current[key]; !ok {
+			alerts = append(alerts, HealthAlert{Status: AlertRecovered, Issue: previous.Issue, Since: previous.Since, Time: now})
+		}
+	}
+	m.active = current
+
+	// Problems first, then recoveries, each sorted by service
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if alerts[i].Status != alerts[j].Status {
+			return alerts[i].Status == AlertProblem
+		}
+		return alerts[i].Issue.Service < alerts[j].Issue.Service
+	})
+	return alerts
+}
+
+// Describe the alert on one line.
+func (a HealthAlert) String() string {
+	if a.Status == AlertRecovered {
+		return fmt.Sprintf("RECOVERED [%s] %s: %s (after %s)", a.Issue.Service, a.Issue.Type, a.Issue.Message, a.Time.Sub(a.Since).Round(time.Second))
+	}
+	return fmt.Sprintf("PROBLEM [%s] %s: %s", a.Issue.Service, a.Issue.Type, a.Issue.Message)
+}
+
+// Get the name of the host and instance included in alerts.
+func alertSource() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown host"
+	}
+	if instance := GetActiveInstance(); !instance.IsDefault() {
+		return fmt.Sprintf("%s (instance %s)", host, instance.Name)
+	}
+	return host
+}
+
+// Build the title of a webhook message for the "alerts".
+func alertTitle(alerts []HealthAlert) string {
+	var problems, recovered int
+	for _, alert := range alerts {
+		if alert.Status == AlertProblem {
+			problems++
+		} else {
+			recovered++
+		}
+	}
+	var parts []string
+	if problems > 0 {
+		parts = append(parts, fmt.Sprintf("%d new issues", problems))
+	}
+	if recovered > 0 {
+		parts = append(parts, fmt.Sprintf("%d resolved issues", recovered))
+	}
+	return fmt.Sprintf("Ghostwriter healthcheck on %s: %s", alertSource(), strings.Join(parts, " and "))
+}
+
+// Build the payload for the webhook's format.
+func webhookPayload(format string, alerts []HealthAlert) ([]byte, error) {
+	title := alertTitle(alerts)
+	switch format {
+	case WebhookSlack:
+		lines := []string{"*" + title + "*"}
+		for _, alert := range alerts {
+			emoji := ":red_circle:"
+			if alert.Status == AlertRecovered {
+				emoji = ":large_green_circle:"
+			}
+			lines = append(lines, emoji+" "+alert.String())
+		}
+		return json.Marshal(map[string]interface{}{"text": strings.Join(lines, "\n")})
+	case WebhookTeams:
+		// Adaptive Card accepted by Teams incoming webhooks and workflows
+		body := []map[string]interface{}{
+			{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "wrap": true},
+		}
+		for _, alert := range alerts {
+			color := "Attention"
+			if alert.Status == AlertRecovered {
+				color = "Good"
+			}
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": alert.String(), "color": color, "wrap": true})
+		}
+		return json.Marshal(map[string]interface{}{
+			"type": "message",
+			"attachments": []map[string]interface{}{{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			}},
+		})
+	}
+
+	type jsonAlert struct {
+		Status    string    `json:"status"`
+		Type      string    `json:"type"`
+		Service   string    `json:"service"`
+		Message   string    `json:"message"`
+		LatencyMs int64     `json:"latency_ms,omitempty"`
+		Since     time.Time `json:"since"`
+		Time      time.Time `json:"time"`
+	}
+	payload := struct {
+		Title  string      `json:"title"`
+		Source string      `json:"source"`
+		Alerts []jsonAlert `json:"alerts"`
+	}{Title: title, Source: alertSource()}
+	for _, alert := range alerts {
+		payload.Alerts = append(payload.Alerts, jsonAlert{
+			Status:    alert.Status,
+			Type:      alert.Issue.Type,
+			Service:   alert.Issue.Service,
+			Message:   alert.Issue.Message,
+			LatencyMs: alert.Issue.Latency.Milliseconds(),
+			Since:     alert.Since,
+			Time:      alert.Time,
+		})
+	}
+	return json.Marshal(payload)
+}
+
+// Post the "alerts" to the webhook and return an error if it did not accept them.
+func (m *HealthMonitor) post(webhook Webhook, alerts []HealthAlert) error {
+	payload, err := webhookPayload(webhook.Format, alerts)
+	if err != nil {
+		return err
+	}
+	res, err := m.Client.Post(webhook.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// The "*url.Error" repeats the whole URL, so only keep the underlying error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("HTTP status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Notify posts the "alerts" to every webhook. Every webhook is tried even if one fails, and the errors
+// are returned together. Alerts a webhook did not accept stay pending and are posted to it again with the
+// alerts of the next call.
+func (m *HealthMonitor) Notify(alerts []HealthAlert) error {
+	if m.pending == nil {
+		m.pending = make(map[string][]HealthAlert)
+	}
+	var failures []string
+	for i, webhook := range m.Webhooks {
+		unsent := append(m.pending[webhook.URL], alerts...)
+		if len(unsent) == 0 {
+			continue
+		}
+		if err := m.post(webhook, unsent); err != nil {
+			if len(unsent) > maxPendingAlerts {
+				unsent = unsent[len(unsent)-maxPendingAlerts:]
+			}
+			m.pending[webhook.URL] = unsent
+			failures = append(failures, fmt.Sprintf("%s: %v", webhook.describe(i), err))
+			continue
+		}
+		delete(m.pending, webhook.URL)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to post alerts to %d webhooks (retrying with the next check): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Watch runs the "check" function every "interval" until the context is cancelled. It posts the alerts for every
+// change in the issues and passes them to the "report" function with any error from the webhooks.
+func (m *HealthMonitor) Watch(ctx context.Context, interval time.Duration, check func() HealthIssues, report func([]HealthAlert, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		alerts := m.Update(check(), time.Now())
+		report(alerts, m.Notify(alerts))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Both cases may be ready at once, so stop before checking again
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Local HTTP sink that records the payloads posted to each path.
+type webhookSink struct {
+	sync.Mutex
+	payloads map[string][]map[string]interface{}
+}
+
+func newWebhookSink(t *testing.T) (*webhookSink, *httptest.Server) {
+	sink := &webhookSink{payloads: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &payload), "Expected the webhook payload to be JSON")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		sink.Lock()
+		sink.payloads[r.URL.Path] = append(sink.payloads[r.URL.Path], payload)
+		flaky := r.URL.Path == "/flaky" && len(sink.payloads[r.URL.Path]) == 1
+		sink.Unlock()
+		if r.URL.Path == "/broken" || flaky {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return sink, server
+}
+
+func (s *webhookSink) count(path string) int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.payloads[path])
+}
+
+func TestParseWebhook(t *testing.T) {
+	webhook, err := ParseWebhook("https://alerts.example.com/hook")
+	assert.NoError(t, err)
+	assert.Equal(t, Webhook{Format: WebhookJSON, URL: "https://alerts.example.com/hook"}, webhook)
+
+	webhook, err = ParseWebhook("slack=https://hooks.slack.com/services/T000/B000/XXX")
+	assert.NoError(t, err)
+	assert.Equal(t, WebhookSlack, webhook.Format)
+	assert.Equal(t, "https://hooks.slack.com/services/T000/B000/XXX", webhook.URL)
+
+	webhook, err = ParseWebhook("teams=https://example.webhook.office.com/webhookb2/abc")
+	assert.NoError(t, err)
+	assert.Equal(t, WebhookTeams, webhook.Format)
+
+	_, err = ParseWebhook("discord=https://example.com")
+	assert.Error(t, err, "Expected an error for an unknown format")
+}
+
+func TestHealthMonitorUpdate(t *testing.T) {
+	monitor := NewHealthMonitor(nil)
+	start := time.Now()
+	failing := HealthIssue{Type: "Health", Service: "GRAPHQL", Message: "Health check is failing (3 consecutive failures)"}
+	stopped := HealthIssue{Type: "Container", Service: "QUEUE", Message: "Container is exited with exit code 1"}
+
+	alerts := monitor.Update(HealthIssues{failing}, start)
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, AlertProblem, alerts[0].Status)
+	}
+
+	// The same issue with a different count is not alerted again
+	failing.Message = "Health check is failing (4 consecutive failures)"
+	assert.Empty(t, monitor.Update(HealthIssues{failing, failing}, start.Add(time.Minute)))
+
+	// A new issue is alerted and the resolved issue recovers
+	alerts = monitor.Update(HealthIssues{stopped}, start.Add(2*time.Minute))
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, AlertProblem, alerts[0].Status)
+		assert.Equal(t, "QUEUE", alerts[0].Issue.Service)
+		assert.Equal(t, AlertRecovered, alerts[1].Status)
+		assert.Equal(t, "Health check is failing (4 consecutive failures)", alerts[1].Issue.Message)
+		assert.Equal(t, start, alerts[1].Since)
+		assert.Equal(t, "RECOVERED [GRAPHQL] Health: Health check is failing (4 consecutive failures) (after 2m0s)", alerts[1].String())
+	}
+
+	assert.Empty(t, monitor.Update(HealthIssues{stopped}, start.Add(3*time.Minute)))
+}
+
+func TestHealthMonitorNotify(t *testing.T) {
+	sink, server := newWebhookSink(t)
+	monitor := NewHealthMonitor([]Webhook{
+		{Format: WebhookJSON, URL: server.URL + "/json"},
+		{Format: WebhookSlack, URL: server.URL + "/slack"},
+		{Format: WebhookTeams, URL: server.URL + "/teams"},
+	})
+	issue := HealthIssue{Type: "Probe", Service: "REDIS", Message: "Redis did not respond to PING", Latency: 1500 * time.Millisecond}
+	alerts := monitor.Update(HealthIssues{issue}, time.Now())
+	assert.NoError(t, monitor.Notify(alerts), "Expected `Notify()` to return no error")
+
+	generic := sink.payloads["/json"][0]
+	sent := generic["alerts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "problem", sent["status"])
+	assert.Equal(t, "REDIS", sent["service"])
+	assert.Equal(t, float64(1500), sent["latency_ms"])
+	assert.Contains(t, generic["title"], "1 new issues")
+
+	assert.Contains(t, sink.payloads["/slack"][0]["text"], ":red_circle: PROBLEM [REDIS] Probe: Redis did not respond to PING")
+
+	card := sink.payloads["/teams"][0]["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", card["contentType"])
+	body := card["content"].(map[string]interface{})["body"].([]interface{})
+	assert.Len(t, body, 2)
+
+	// Nothing is posted without changes
+	assert.NoError(t, monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now())))
+	assert.Equal(t, 1, sink.count("/json"))
+
+	// Recoveries are posted and failing webhooks are reported without stopping the others
+	monitor.Webhooks = append(monitor.Webhooks, Webhook{Format: WebhookJSON, URL: server.URL + "/broken"})
+	alerts = monitor.Update(nil, time.Now())
+	err := monitor.Notify(alerts)
+	assert.ErrorContains(t, err, "HTTP status 500")
+	assert.ErrorContains(t, err, "webhook 4 (http://127.0.0.1:")
+	assert.NotContains(t, err.Error(), "/broken", "Expected the webhook's path to be left out of the error")
+	assert.Equal(t, 2, sink.count("/json"))
+	assert.Equal(t, "recovered", sink.payloads["/json"][1]["alerts"].([]interface{})[0].(map[string]interface{})["status"])
+}
+
+func TestHealthMonitorNotifyUnreachable(t *testing.T) {
+	// Webhook URLs contain secret tokens, so they are not repeated in errors about unreachable webhooks
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	monitor := NewHealthMonitor([]Webhook{{Format: WebhookSlack, URL: server.URL + "/services/T000/B000/secret-token"}})
+	issue := HealthIssue{Type: "Container", Service: "DJANGO", Message: "Container is restarting"}
+	err := monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now()))
+	assert.ErrorContains(t, err, "webhook 1 (http://127.0.0.1:")
+	assert.NotContains(t, err.Error(), "secret-token")
+}
+
+func TestHealthMonitorNotifyRetry(t *testing.T) {
+	sink, server := newWebhookSink(t)
+	// The flaky webhook returns a 500 error for the first request only
+	monitor := NewHealthMonitor([]Webhook{
+		{Format: WebhookJSON, URL: server.URL + "/json"},
+		{Format: WebhookJSON, URL: server.URL + "/flaky"},
+	})
+	issue := HealthIssue{Type: "Container", Service: "DJANGO", Message: "Container is restarting"}
+	assert.ErrorContains(t, monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now())), "HTTP status 500")
+	assert.Equal(t, 1, sink.count("/json"))
+	assert.Equal(t, 1, sink.count("/flaky"))
+
+	// The undelivered alert is posted again on the next check without new alerts, but only to the failed webhook
+	assert.NoError(t, monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now())))
+	assert.Equal(t, 1, sink.count("/json"))
+	if assert.Equal(t, 2, sink.count("/flaky")) {
+		retried := sink.payloads["/flaky"][1]["alerts"].([]interface{})
+		assert.Len(t, retried, 1)
+		assert.Equal(t, "problem", retried[0].(map[string]interface{})["status"])
+	}
+
+	// Delivered alerts are not posted again
+	assert.NoError(t, monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now())))
+	assert.Equal(t, 2, sink.count("/flaky"))
+
+	// A new alert is posted along with the undelivered alerts
+	monitor.Webhooks = append(monitor.Webhooks, Webhook{Format: WebhookJSON, URL: server.URL + "/broken"})
+	assert.Error(t, monitor.Notify(monitor.Update(nil, time.Now())))
+	assert.Error(t, monitor.Notify(monitor.Update(HealthIssues{issue}, time.Now())))
+	assert.Len(t, sink.payloads["/broken"][1]["alerts"], 2)
+}
+
+func TestHealthMonitorWatch(t *testing.T) {
+	sink, server := newWebhookSink(t)
+	monitor := NewHealthMonitor([]Webhook{{Format: WebhookJSON, URL: server.URL + "/json"}})
+
+	// Fail twice and then recover
+	checks := []HealthIssues{
+		{{Type: "Container", Service: "DJANGO", Message: "Container is restarting"}},
+		{{Type: "Container", Service: "DJANGO", Message: "Container is restarting"}},
+		nil,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var reported []HealthAlert
+	run := 0
+	check := func() HealthIssues {
+		issues := checks[run]
+		run++
+		if run == len(checks) {
+			cancel()
+		}
+		return issues
+	}
+	monitor.Watch(ctx, time.Millisecond, check, func(alerts []HealthAlert, err error) {
+		assert.NoError(t, err)
+		reported = append(reported, alerts...)
+	})
+
+	assert.Equal(t, len(checks), run)
+	if assert.Len(t, reported, 2) {
+		assert.Equal(t, AlertProblem, reported[0].Status)
+		assert.Equal(t, AlertRecovered, reported[1].Status)
+	}
+	assert.Equal(t, 2, sink.count("/json"))
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
@@ -9,36 +15,109 @@ import (
 
 // logsCmd represents the logs command
 var logsCmd = &cobra.Command{
-	Use:   "logs <container>",
+	Use:   "logs <service>...",
 	Short: "Fetch logs for Ghostwriter services",
-	Long: `Fetch logs for Ghostwriter services. Provide "all" or a container name.
+	Long: `Fetch logs for Ghostwriter services. Provide "all" or one or more service names.
 
 Valid names are:
 
 * collab
 * django
-* frontend (dev only)
+* frontend (development only)
 * graphql
-* nginx
+* nginx (production only)
 * postgres
 * queue
-* redis`,
-	Args: cobra.ExactArgs(1),
-	Run:  readLogs,
+* redis
+
+Each line is prefixed with the name of its container. Logs from several
+containers are merged in chronological order, and lines the containers
+wrote to stderr are printed to stderr.
+
+The --since and --until flags accept a timestamp (e.g., 2025-01-02T15:04:05)
+or a time relative to now (e.g., 42m or 2h).`,
+	Example: `  ghostwriter-cli logs django
+  ghostwriter-cli logs django queue --since 10m
+  ghostwriter-cli logs all --follow
+  ghostwriter-cli logs nginx --since 1h --timestamps
+  ghostwriter-cli logs postgres --since 2025-01-02T15:00:00 --until 2025-01-02T16:00:00 --lines all`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeLogServices,
+	Run:               readLogs,
 }
 
 func init() {
 	rootCmd.AddCommand(logsCmd)
 
-	logsCmd.Flags().StringP("lines", "l", "500", "Number of lines to display")
+	logsCmd.Flags().StringP("lines", "l", "500", "Number of lines to display from the end of the logs (or \"all\")")
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep streaming new log lines until stopped with Ctrl+C")
+	logsCmd.Flags().String("since", "", "Show logs since a timestamp or relative time (e.g., 42m)")
+	logsCmd.Flags().String("until", "", "Show logs before a timestamp or relative time (e.g., 42m)")
+	logsCmd.Flags().BoolP("timestamps", "t", false, "Show the timestamp of each line")
+	logsCmd.Flags().Bool("no-color", false, "Disable the colored container prefixes")
+}
+
+// Color the prefixes only when printing to a terminal and "NO_COLOR" is not set.
+func useLogColor(cmd *cobra.Command) bool {
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Determine if the logs are for the development environment from the recorded mode or the "--dev" flag.
+func logsDevMode() bool {
+	if mode := docker.GetMode(); mode != "" {
+		return mode == docker.ModeDevelopment
+	}
+	return dev
+}
+
+// Complete the names of the services that are not in the arguments yet.
+func completeLogServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if docker.Contains(args, "all") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	if len(args) == 0 {
+		names = append(names, "all")
+	}
+	for _, service := range docker.LogServices(logsDevMode()) {
+		if !docker.Contains(args, service) {
+			names = append(names, service)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
 }
 
 func readLogs(cmd *cobra.Command, args []string) {
+	// Check Docker first, so the services are read from the Compose file with the right command (e.g., Podman)
 	docker.EvaluateDockerComposeStatus()
-	lines := cmd.Flag("lines").Value.String()
-	fmt.Printf("[+] Fetching up to %s lines of logs for `%s`...\n", lines, args[0])
-	logs := docker.FetchLogs(args[0], lines)
-	for _, entry := range logs {
-		fmt.Print(entry)
+	services, err := docker.ValidateLogServices(args, logsDevMode())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	opts := docker.LogOptions{Color: useLogColor(cmd)}
+	opts.Tail, _ = cmd.Flags().GetString("lines")
+	opts.Follow, _ = cmd.Flags().GetBool("follow")
+	opts.Since, _ = cmd.Flags().GetString("since")
+	opts.Until, _ = cmd.Flags().GetString("until")
+	opts.Timestamps, _ = cmd.Flags().GetBool("timestamps")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.Follow {
+		fmt.Fprintf(os.Stderr, "[+] Following logs for %s (press Ctrl+C to stop)...\n", strings.Join(services, ", "))
+	} else {
+		fmt.Fprintf(os.Stderr, "[+] Fetching up to %s lines of logs for %s...\n", opts.Tail, strings.Join(services, ", "))
+	}
+	if err := docker.PrintLogs(ctx, services, opts); err != nil {
+		log.Fatalf("Failed to fetch logs: %v", err)
 	}
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var logExportOpts docker.LogExportOptions
+var logExportMaxSize int64
+
+// logsExportCmd represents the logs export command
+var logsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the logs of every service to rotated files",
+	Long: `Export the logs of every Ghostwriter service to its own file in a directory
+(e.g., "django.log") so the logs survive when containers are recreated by
+"containers build" or "containers up".
+
+Each line is written with its timestamp and stream (stdout or stderr). A file is
+compressed to "<service>-<time>.log.gz" once it reaches the --max-size, and only the
+newest --max-files compressed files are kept for each service.
+
+The time of the last line exported from each container is recorded in ".positions.json"
+in the directory, so running the export again (e.g., from cron) only adds new lines.
+The --since and --until flags only apply to containers that have not been exported to
+the directory yet.
+
+Use the --daemon flag to keep tailing every container until stopped with Ctrl+C. The
+containers are checked every --interval, so containers recreated by an upgrade are
+picked up automatically. Run it as a service (e.g., with systemd) to keep an audit
+trail of the logs across upgrades.`,
+	Example: `  ghostwriter-cli logs export --dir /var/log/ghostwriter
+  ghostwriter-cli logs export --dir /var/log/ghostwriter --daemon --max-size 50 --max-files 20`,
+	Args: cobra.NoArgs,
+	Run:  exportLogs,
+}
+
+func init() {
+	logsCmd.AddCommand(logsExportCmd)
+
+	logsExportCmd.Flags().StringVar(&logExportOpts.Dir, "dir", "", "Directory for the log files")
+	logsExportCmd.Flags().Int64Var(&logExportMaxSize, "max-size", 100, "Size in megabytes at which a log file is rotated")
+	logsExportCmd.Flags().IntVar(&logExportOpts.MaxFiles, "max-files", 10, "Number of rotated files to keep for each service (0 keeps all of them)")
+	logsExportCmd.Flags().StringVar(&logExportOpts.Since, "since", "", "Export logs since a timestamp or relative time (e.g., 42m)")
+	logsExportCmd.Flags().StringVar(&logExportOpts.Until, "until", "", "Export logs before a timestamp or relative time (e.g., 42m)")
+	logsExportCmd.Flags().BoolVar(&logExportOpts.Daemon, "daemon", false, "Keep tailing the containers until stopped")
+	logsExportCmd.Flags().DurationVar(&logExportOpts.Interval, "interval", 10*time.Second, "Time between checks for new or recreated containers with `--daemon`")
+}
+
+func exportLogs(cmd *cobra.Command, args []string) {
+	if logExportOpts.Dir == "" {
+		log.Fatalln("The `--dir` flag is required")
+	}
+	if logExportMaxSize <= 0 {
+		log.Fatalln("The `--max-size` must be at least 1 MB")
+	}
+	if logExportOpts.MaxFiles < 0 {
+		log.Fatalln("The `--max-files` cannot be negative")
+	}
+	if logExportOpts.Daemon && logExportOpts.Until != "" {
+		log.Fatalln("The `--until` flag can't be used with `--daemon`")
+	}
+	if logExportOpts.Interval < time.Second {
+		log.Fatalln("The `--interval` must be at least one second")
+	}
+	logExportOpts.MaxSize = logExportMaxSize * 1024 * 1024
+
+	docker.EvaluateDockerComposeStatus()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if logExportOpts.Daemon {
+		fmt.Printf("[+] Tailing the logs of every Ghostwriter container to %s (press Ctrl+C to stop)...\n", logExportOpts.Dir)
+	} else {
+		fmt.Printf("[+] Exporting the logs of every Ghostwriter container to %s...\n", logExportOpts.Dir)
+	}
+	lines, err := docker.ExportLogs(ctx, logExportOpts, func(message string) {
+		fmt.Printf("[*] %s\n", message)
+	})
+	if err != nil {
+		log.Fatalf("Failed to export logs: %v", err)
+	}
+	fmt.Printf("[+] Exported %d new log lines to %s\n", lines, logExportOpts.Dir)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+
+	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var searchServices []string
+var searchFormat string
+var searchIgnoreCase bool
+
+// logsSearchCmd represents the logs search command
+var logsSearchCmd = &cobra.Command{
+	Use:   "search <pattern>",
+	Short: "Search the logs of Ghostwriter services",
+	Long: `Search the logs of Ghostwriter services for lines matching a regular expression.
+Use "" as the pattern to match every line and filter only by the structured fields.
+
+Lines are parsed into structured fields for the known formats:
+
+* Django (uvicorn access logs and Django's loggers)
+* Hasura's JSON logs (graphql)
+* Nginx's combined access log and error log
+* PostgreSQL
+
+The --status, --path, and --level flags filter by these fields. Levels are normalized
+to debug, info, warning, error, and critical, and --level shows that level and above.
+Access logs have the "warning" level for 4xx responses and "error" for 5xx responses.
+
+Results are printed as text or as JSON with one object per line (--format json).`,
+	Example: `  ghostwriter-cli logs search "Traceback" --service django --since 2h
+  ghostwriter-cli logs search "" --service nginx --status 5xx
+  ghostwriter-cli logs search "" --level error --format json
+  ghostwriter-cli logs search "graphql" --path "^/v1/" --status ">=400" -i`,
+	Args: cobra.ExactArgs(1),
+	Run:  searchLogs,
+}
+
+func init() {
+	logsCmd.AddCommand(logsSearchCmd)
+
+	logsSearchCmd.Flags().StringSliceVarP(&searchServices, "service", "s", []string{"all"}, "Services to search (repeat or separate with commas)")
+	logsSearchCmd.Flags().StringP("lines", "l", "all", "Number of lines to search from the end of each service's logs")
+	logsSearchCmd.Flags().String("since", "", "Search logs since a timestamp or relative time (e.g., 42m)")
+	logsSearchCmd.Flags().String("until", "", "Search logs before a timestamp or relative time (e.g., 42m)")
+	logsSearchCmd.Flags().String("status", "", "Only show requests with an HTTP status (e.g., 404, 5xx, or >=400)")
+	logsSearchCmd.Flags().String("path", "", "Only show requests with a path matching a regular expression")
+	logsSearchCmd.Flags().String("level", "", "Only show entries with this level or above (debug, info, warning, error, or critical)")
+	logsSearchCmd.Flags().BoolVarP(&searchIgnoreCase, "ignore-case", "i", false, "Match the pattern without regard to case")
+	logsSearchCmd.Flags().StringVar(&searchFormat, "format", "text", "Output format (text or json)")
+	logsSearchCmd.RegisterFlagCompletionFunc("service", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return append([]string{"all"}, docker.LogServices(logsDevMode())...), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func searchLogs(cmd *cobra.Command, args []string) {
+	if searchFormat != "text" && searchFormat != "json" {
+		log.Fatalf("Unsupported output format %q (use text or json)", searchFormat)
+	}
+
+	var query docker.LogQuery
+	var err error
+	pattern := args[0]
+	if searchIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	if query.Pattern, err = regexp.Compile(pattern); err != nil {
+		log.Fatalf("Invalid pattern: %v", err)
+	}
+	if path, _ := cmd.Flags().GetString("path"); path != "" {
+		if query.Path, err = regexp.Compile(path); err != nil {
+			log.Fatalf("Invalid path pattern: %v", err)
+		}
+	}
+	status, _ := cmd.Flags().GetString("status")
+	if query.Status, err = docker.ParseStatusFilter(status); err != nil {
+		log.Fatalf("%v", err)
+	}
+	level, _ := cmd.Flags().GetString("level")
+	if query.Level, err = docker.ValidateLogLevel(level); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Check Docker first, so the services are read from the Compose file with the right command (e.g., Podman)
+	docker.EvaluateDockerComposeStatus()
+	services, err := docker.ValidateLogServices(searchServices, logsDevMode())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var opts docker.LogOptions
+	opts.Tail, _ = cmd.Flags().GetString("lines")
+	opts.Since, _ = cmd.Flags().GetString("since")
+	opts.Until, _ = cmd.Flags().GetString("until")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	matches := 0
+	encoder := json.NewEncoder(os.Stdout)
+	err = docker.SearchLogs(ctx, services, opts, query, func(entry docker.LogEntry) {
+		matches++
+		if searchFormat == "json" {
+			encoder.Encode(entry)
+			return
+		}
+		fmt.Println(docker.FormatLogEntry(entry))
+	})
+	if err != nil {
+		log.Fatalf("Failed to search logs: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Found %d matching log entries\n", matches)
+}
@@ -27,10 +27,10 @@ func migrateTotp(cmd *cobra.Command, args []string) {
 
 	docker.EvaluateDockerComposeStatus()
 	if dev {
-		docker.SetDevMode()
+		selectMode()
 		yamlFile = "local.yml"
 	} else {
-		docker.SetProductionMode()
+		selectMode()
 		yamlFile = "production.yml"
 	}
 	reader := bufio.NewReader(os.Stdin)
@@ -32,11 +32,11 @@ func pgUpgrade(cmd *cobra.Command, args []string) {
 	yaml := ""
 	interfix := ""
 	if dev {
-		docker.SetDevMode()
+		selectMode()
 		yaml = "local.yml"
 		interfix = "local"
 	} else {
-		docker.SetProductionMode()
+		selectMode()
 		yaml = "production.yml"
 		interfix = "production"
 	}
@@ -62,9 +62,12 @@ func pgUpgrade(cmd *cobra.Command, args []string) {
 	}
 	fmt.Printf("Upgrading PostgreSQL data from %d to %d\n", dataVersion, serverVersion)
 
+	// Name the temporary container after the instance's project so upgrades of different instances can't collide
+	upgradeContainer := docker.GetActiveInstance().Project + "_postgres_upgrade"
+
 	fmt.Println("[+] Starting old Postgres database")
 	err := docker.RunRawCmd("docker", "run", "-d", "--rm",
-		"--name", "ghostwriter_postgres_upgrade",
+		"--name", upgradeContainer,
 		"--volume", fmt.Sprintf("%s:/var/lib/postgresql/data/", volumeName),
 		"--network", networkName,
 		fmt.Sprintf("postgres:%d", dataVersion),
@@ -81,11 +84,11 @@ func pgUpgrade(cmd *cobra.Command, args []string) {
 	err = docker.RunCmd("docker", []string{"-f", yaml, "run", "-T", "--rm",
 		"postgres",
 		"bash", "-o", "pipefail", "-euc",
-		`source /usr/local/bin/_sourced/constants.sh; PGPASSWORD="${POSTGRES_PASSWORD}" pg_dump -h ghostwriter_postgres_upgrade -U "${POSTGRES_USER}" "${POSTGRES_DB}" | gzip > "${BACKUP_DIR_PATH}/_ghostwriter_postgres_upgrade.sql.gz"`,
+		fmt.Sprintf(`source /usr/local/bin/_sourced/constants.sh; PGPASSWORD="${POSTGRES_PASSWORD}" pg_dump -h %s -U "${POSTGRES_USER}" "${POSTGRES_DB}" | gzip > "${BACKUP_DIR_PATH}/_ghostwriter_postgres_upgrade.sql.gz"`, upgradeContainer),
 	})
 	if err != nil {
 		fmt.Println("[+] Stopping old Postgres server")
-		stopErr := docker.RunRawCmd("docker", "stop", "ghostwriter_postgres_upgrade")
+		stopErr := docker.RunRawCmd("docker", "stop", upgradeContainer)
 		if stopErr != nil {
 			log.Printf("Could not stop old postgres server: %v\n", err)
 		}
@@ -93,7 +96,7 @@ func pgUpgrade(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Println("[+] Stopping old Postgres server")
-	err = docker.RunRawCmd("docker", "stop", "ghostwriter_postgres_upgrade")
+	err = docker.RunRawCmd("docker", "stop", upgradeContainer)
 	if err != nil {
 		log.Fatalf("Could not stop old postgres server: %v\n", err)
 	}
@@ -138,7 +141,7 @@ func getVolumenAndNetworkName(path string, interfix string) (string, string) {
 		log.Fatalf("Could not parse network path. This is a bug.")
 	}
 
-	config, err := docker.RunBasicCmd("docker", []string{"compose", "-f", path, "config"})
+	config, err := docker.RunBasicCmd("docker", docker.ComposeArgs("-f", path, "config"))
 	if err != nil {
 		log.Fatalf("Could not get docker config: %s\n", err)
 	}
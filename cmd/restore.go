@@ -48,7 +48,7 @@ func restoreDatabase(cmd *cobra.Command, args []string) {
 		c := internal.AskForConfirmation(confirmMsg)
 		if c {
 			if dev {
-				internal.SetDevMode()
+				selectMode()
 				fmt.Printf("[+] Restoring the `%s` database backup file in the development environment...\n", args[0])
 				internal.RunDockerComposeRestore("local.yml", args[0])
 				if mediaBackupFile != "" {
@@ -59,7 +59,7 @@ func restoreDatabase(cmd *cobra.Command, args []string) {
 					internal.RunDockerComposeMediaRestore("local.yml", mediaBackupFile)
 				}
 			} else {
-				internal.SetProductionMode()
+				selectMode()
 				fmt.Printf("[+] Restoring the `%s` database backup file in the production environment...\n", args[0])
 				internal.RunDockerComposeRestore("production.yml", args[0])
 				if mediaBackupFile != "" {
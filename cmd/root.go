@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"fmt"
 	env "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
+	"log"
 	"os"
 )
 
 // Vars for global flags
 var dev bool
+var instance string
+var switchMode bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -27,9 +31,31 @@ func Execute() {
 }
 
 func init() {
-	// Create or parse the Docker ``.env`` file
-	env.ParseGhostwriterEnvironmentVariables()
+	// The environment depends on the "--instance" flag, so it's loaded after flags are parsed
+	cobra.OnInitialize(initEnvironment)
 
 	// Persistent flags defined here are global for the CLI
 	rootCmd.PersistentFlags().BoolVar(&dev, "dev", false, `Target the development environment for "install" and "containers" commands.`)
+	rootCmd.PersistentFlags().BoolVar(&switchMode, "switch-mode", false, `Allow switching the recorded environment mode to the one targeted by the "--dev" flag.`)
+	rootCmd.PersistentFlags().StringVar(&instance, "instance", env.DefaultInstanceName, `Target a named Ghostwriter instance (see the "instances" command).`)
+}
+
+// Select the target instance and then create or parse its Docker ".env" file.
+func initEnvironment() {
+	if err := env.SetActiveInstance(instance); err != nil {
+		log.Fatalln(err)
+	}
+	env.ParseGhostwriterEnvironmentVariables()
+}
+
+// Check the mode targeted by the "--dev" flag against the mode recorded in the configuration.
+// Commands stop here if they target the other mode without the "--switch-mode" flag.
+func selectMode() {
+	switched, err := env.SelectMode(dev, switchMode)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if switched {
+		fmt.Printf("[+] Switched the environment mode to %s\n", env.GetMode())
+	}
 }
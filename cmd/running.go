@@ -1,15 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
 	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
 	"github.com/spf13/cobra"
 )
 
+var runningStats bool
+var runningWatch bool
+var runningInterval time.Duration
+
 // runningCmd represents the running command
 var runningCmd = &cobra.Command{
 	Use:   "running",
@@ -17,16 +26,54 @@ var runningCmd = &cobra.Command{
 	Long: `Print a list of running Ghostwriter services.
 
 If containers are found, the results will include information similar
-the information provided by the "docker containers ls" command.`,
+the information provided by the "docker containers ls" command along with
+the health state, restart count, and Docker Compose project of each container.
+
+Use the "--stats" flag to include the CPU, memory, network, and block I/O usage
+of each container from Docker's stats API (like "docker stats"). CPU usage is
+a percentage of one CPU, so a container using two CPUs fully is at 200%.
+
+Use the "--watch" flag to refresh the list every "--interval" like "top" until
+stopped with Ctrl+C.`,
+	Example: `  ghostwriter-cli running --stats
+  ghostwriter-cli running --stats --watch --interval 5s`,
 	Run: displayRunning,
 }
 
 func init() {
 	rootCmd.AddCommand(runningCmd)
+
+	runningCmd.Flags().BoolVar(&runningStats, "stats", false, "Include the CPU, memory, network, and block I/O usage of each container")
+	runningCmd.Flags().BoolVar(&runningWatch, "watch", false, "Refresh the list until stopped with Ctrl+C")
+	runningCmd.Flags().DurationVar(&runningInterval, "interval", 2*time.Second, "Time between refreshes with `--watch`")
 }
 
-func displayRunning(cmd *cobra.Command, args []string) {
-	docker.EvaluateDockerComposeStatus()
+// Format the published and exposed ports of a container.
+func formatPorts(container docker.Container) string {
+	var ports []string
+	for _, port := range container.Ports {
+		var portString string
+
+		if port.IP.IsValid() {
+			portString = fmt.Sprintf("%s:", port.IP)
+		} else {
+			portString = ""
+		}
+		if port.PrivatePort != 0 {
+			portString += fmt.Sprintf("%d", port.PrivatePort)
+		}
+		if port.PublicPort != 0 {
+			portString += fmt.Sprintf(":%d » %d/%s", port.PrivatePort, port.PublicPort, port.Type)
+		} else {
+			portString += fmt.Sprintf("/%s", port.Type)
+		}
+		ports = append(ports, portString)
+	}
+	return strings.Join(ports, ", ")
+}
+
+// Print the table of running containers.
+func printRunning(containers []docker.RunningContainer) {
 	// initialize tabwriter
 	writer := new(tabwriter.Writer)
 	// Set minwidth, tabwidth, padding, padchar, and flags
@@ -34,36 +81,87 @@ func displayRunning(cmd *cobra.Command, args []string) {
 
 	defer writer.Flush()
 
-	fmt.Println("[+] Collecting list of running Ghostwriter containers...")
-
-	containers := docker.GetRunning()
 	fmt.Printf("[+] Found %d running Ghostwriter containers\n", len(containers))
+	if len(containers) == 0 {
+		return
+	}
 
-	if len(containers) > 0 {
-		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "Name", "Container ID", "Image", "Status", "Ports")
-		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
-		for _, container := range containers {
-			var ports []string
-			for _, port := range container.Ports {
-				var portString string
-
-				if port.IP.IsValid() {
-					portString = fmt.Sprintf("%s:", port.IP)
-				} else {
-					portString = ""
-				}
-				if port.PrivatePort != 0 {
-					portString += fmt.Sprintf("%d", port.PrivatePort)
-				}
-				if port.PublicPort != 0 {
-					portString += fmt.Sprintf(":%d » %d/%s", port.PrivatePort, port.PublicPort, port.Type)
-				} else {
-					portString += fmt.Sprintf("/%s", port.Type)
-				}
-				ports = append(ports, portString)
-			}
-			fmt.Fprintf(writer, "\n %s\t%s\t%s\t%v\t%s", container.Name, container.ID, container.Image, container.Status, strings.Join(ports, ", "))
+	if runningStats {
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "Name", "Status", "Health", "Restarts", "Project", "CPU %", "Memory", "Mem %", "Net I/O", "Block I/O")
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+	} else {
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "Name", "Container ID", "Image", "Status", "Health", "Restarts", "Project", "Ports")
+		fmt.Fprintf(writer, "\n %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––", "––––––––––––")
+	}
+	for _, container := range containers {
+		health := container.Health
+		if health == "" {
+			health = "–"
+		}
+		if !runningStats {
+			fmt.Fprintf(
+				writer, "\n %s\t%s\t%s\t%v\t%s\t%d\t%s\t%s",
+				container.Name, container.ID, container.Image, container.Status, health, container.RestartCount, container.Project, formatPorts(container.Container),
+			)
+			continue
+		}
+		if container.Stats == nil {
+			fmt.Fprintf(
+				writer, "\n %s\t%s\t%s\t%d\t%s\t%s",
+				container.Name, container.Status, health, container.RestartCount, container.Project, "Failed to get stats: "+container.StatsError,
+			)
+			continue
+		}
+		stats := container.Stats
+		fmt.Fprintf(
+			writer, "\n %s\t%s\t%s\t%d\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s",
+			container.Name, container.Status, health, container.RestartCount, container.Project,
+			stats.CPUPercent,
+			docker.FormatBytes(int64(stats.MemoryUsage)), docker.FormatBytes(int64(stats.MemoryLimit)), stats.MemoryPercent,
+			docker.FormatBytes(int64(stats.NetworkRx)), docker.FormatBytes(int64(stats.NetworkTx)),
+			docker.FormatBytes(int64(stats.BlockRead)), docker.FormatBytes(int64(stats.BlockWrite)),
+		)
+	}
+	fmt.Fprintln(writer, "")
+}
+
+func displayRunning(cmd *cobra.Command, args []string) {
+	if runningWatch && runningInterval < time.Second {
+		log.Fatalln("The `--interval` must be at least one second")
+	}
+	docker.EvaluateDockerComposeStatus()
+
+	if !runningWatch {
+		fmt.Println("[+] Collecting list of running Ghostwriter containers...")
+		containers, err := docker.DescribeRunning(runningStats)
+		if err != nil {
+			log.Fatalf("Failed to get container information from Docker: %v", err)
+		}
+		printRunning(containers)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(runningInterval)
+	defer ticker.Stop()
+	for {
+		containers, err := docker.DescribeRunning(runningStats)
+		if ctx.Err() != nil {
+			return
+		}
+		// Clear the screen and move the cursor to the top like "top"
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("[+] Ghostwriter containers at %s (refreshing every %s, press Ctrl+C to stop)\n", time.Now().Format("15:04:05"), runningInterval)
+		if err != nil {
+			fmt.Printf("[!] Failed to get container information from Docker: %s\n", err)
+		} else {
+			printRunning(containers)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		fmt.Fprintln(writer, "")
 	}
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	docker "github.com/GhostManager/Ghostwriter_CLI/cmd/internal"
+	"github.com/spf13/cobra"
+)
+
+var bundleOpts docker.SupportBundleOptions
+
+// supportBundleCmd represents the support-bundle command
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect troubleshooting information into a single tarball",
+	Long: `Collect the information needed to troubleshoot Ghostwriter into a single ".tar.gz"
+file that can be attached to an issue. The bundle contains:
+
+* The versions of the CLI, Ghostwriter, and the OS
+* The output of "docker info", "docker compose version", "docker compose config",
+  and "docker compose ps"
+* The results of the healthcheck as JSON
+* The recent logs of every service
+* The configuration with every secret redacted
+* A "manifest.json" describing every file and any that could not be collected
+
+The values of secrets are also removed from every other file in the bundle. Use the
+"--redact-hosts" flag before sharing the bundle outside of your team to replace the
+hostnames (including the names of the server and Docker daemon), domains, and IPv4
+and IPv6 addresses of the installation with placeholders.`,
+	Example: `  ghostwriter-cli support-bundle
+  ghostwriter-cli support-bundle --redact-hosts --lines 2000 --output ghostwriter-support.tar.gz`,
+	Args: cobra.NoArgs,
+	Run:  createSupportBundle,
+}
+
+func init() {
+	rootCmd.AddCommand(supportBundleCmd)
+
+	supportBundleCmd.Flags().StringVarP(&bundleOpts.Output, "output", "o", "", "File to write the bundle to (default: ghostwriter-support-<time>.tar.gz)")
+	supportBundleCmd.Flags().StringVarP(&bundleOpts.Lines, "lines", "l", "1000", "Number of lines of logs to include for each service")
+	supportBundleCmd.Flags().BoolVar(&bundleOpts.RedactHosts, "redact-hosts", false, "Replace hostnames, domains, and IP addresses with placeholders")
+}
+
+func createSupportBundle(cmd *cobra.Command, args []string) {
+	docker.EvaluateDockerComposeStatus()
+	if bundleOpts.Output == "" {
+		bundleOpts.Output = docker.DefaultSupportBundleName(time.Now())
+	}
+
+	fmt.Println("[+] Collecting versions, Docker information, healthcheck results, and logs...")
+	manifest, err := docker.CreateSupportBundle(bundleOpts)
+	if err != nil {
+		log.Fatalf("Failed to create the support bundle: %v", err)
+	}
+	for _, file := range manifest.Files {
+		if file.Error != "" {
+			fmt.Printf("[!] Could not collect %s: %s\n", file.Name, file.Error)
+		}
+	}
+	fmt.Printf("[+] Wrote the support bundle to %s (%d of %d files collected)\n", bundleOpts.Output, len(manifest.Files)-manifest.Errors(), len(manifest.Files))
+	if bundleOpts.RedactHosts {
+		fmt.Println("[*] Secrets, hostnames, and IP addresses were redacted, but please review the bundle before sharing it")
+	} else {
+		fmt.Println("[*] Secrets were redacted, but the bundle includes hostnames and IP addresses (use `--redact-hosts` to remove them)")
+	}
+}
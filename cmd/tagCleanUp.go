@@ -32,11 +32,11 @@ func tagCleanUp(cmd *cobra.Command, args []string) {
 	docker.EvaluateDockerComposeStatus()
 	if dev {
 		fmt.Println("[+] Executing tag cleanup in the development environment...")
-		docker.SetDevMode()
+		selectMode()
 		yamlFile = "local.yml"
 	} else {
 		fmt.Println("[+] Executing tag cleanup in the production environment...")
-		docker.SetProductionMode()
+		selectMode()
 		yamlFile = "production.yml"
 	}
 	docker.RunManagementCmd(yamlFile, "deduplicate_tags")
@@ -32,11 +32,11 @@ func uninstallGhostwriter(cmd *cobra.Command, args []string) {
 	docker.EvaluateDockerComposeStatus()
 	if dev {
 		fmt.Println("[+] Starting Ghostwriter development environment removal")
-		docker.SetDevMode()
+		selectMode()
 		docker.RunDockerComposeUninstall("local.yml")
 	} else {
 		fmt.Println("[+] Starting Ghostwriter production environment removal")
-		docker.SetProductionMode()
+		selectMode()
 		docker.RunDockerComposeUninstall("production.yml")
 	}
 
@@ -12,6 +12,9 @@ require (
 	github.com/spf13/cobra v1.4.0
 	github.com/spf13/viper v1.10.1
 	github.com/stretchr/testify v1.10.0
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/crypto v0.43.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
 
 require (